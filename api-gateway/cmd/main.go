@@ -2,10 +2,12 @@ package main
 
 import (
 	"context"
+	"errors"
 	"log/slog"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/gofiber/fiber/v3"
 	"github.com/gofiber/fiber/v3/middleware/cors"
@@ -14,12 +16,14 @@ import (
 
 	"movie-discovery-api-gateway/internal/config"
 	"movie-discovery-api-gateway/internal/handler"
+	"movie-discovery-api-gateway/internal/metrics"
 	"movie-discovery-api-gateway/internal/middleware"
 	"movie-discovery-api-gateway/internal/proxy"
+	"movie-discovery-api-gateway/internal/requestid"
 )
 
 func main() {
-	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	slog.SetDefault(slog.New(requestid.NewLogHandler(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))))
 
 	cfg, err := config.Load()
 	if err != nil {
@@ -48,36 +52,83 @@ func main() {
 
 	// Global middleware
 	app.Use(fiberRecover.New())
-	app.Use(logger.New())
+	app.Use(requestid.Middleware())
+	app.Use(metrics.Middleware())
+	app.Use(logger.New(logger.Config{Format: requestid.LogFormat}))
 	app.Use(cors.New())
 
+	// RFC 7807 error bodies for clients that ask for them
+	app.Use(middleware.ProblemDetails())
+
 	// Rate limiting
 	rateLimiter := middleware.NewRateLimiter(rdb, cfg.RateLimitMax, cfg.RateLimitWindowSeconds)
 	app.Use(rateLimiter.Handler())
 
-	// Authentication (mock)
-	app.Use(middleware.AuthMiddleware())
+	// Authentication (mock, or JWT with AUTH_MODE=jwt)
+	var verifier *middleware.JWTVerifier
+	if cfg.AuthMode == config.AuthModeJWT {
+		verifier, err = middleware.NewJWTVerifier(cfg.JWTSecret, cfg.JWTJWKSURL)
+		if err != nil {
+			slog.Error("failed to configure JWT auth", "error", err)
+			os.Exit(1)
+		}
+	}
+	app.Use(middleware.AuthMiddleware(verifier, cfg.PublicPathPrefixes))
+
+	// Prometheus metrics (public, bypasses auth)
+	app.Get(metrics.Path, metrics.Handler())
 
 	// Swagger (public, bypasses auth)
 	if swaggerYAML != nil {
 		handler.RegisterSwagger(app, swaggerYAML)
 	}
 
-	// Health check (gateway itself)
-	app.Get("/health", func(c fiber.Ctx) error {
-		return c.JSON(fiber.Map{
-			"status":  "ok",
-			"service": "api-gateway",
-		})
-	})
+	services := map[string]string{
+		"movie-service":           cfg.MovieServiceURL,
+		"user-preference-service": cfg.UserPreferenceServiceURL,
+		"recommendation-service":  cfg.RecommendationServiceURL,
+	}
+
+	// Health check (gateway itself, plus downstream services when enabled)
+	health := handler.NewHealthHandler(services, cfg.HealthCriticalServices, cfg.HealthCheckDownstream).WithRedis(rdb)
+	app.Get("/health", health.Health)
+	// Kubernetes-style probes: liveness never checks dependencies,
+	// readiness always does
+	app.Get("/healthz", health.Live)
+	app.Get("/readyz", health.Ready)
 
 	// Service proxy
-	svcProxy := proxy.NewServiceProxy()
+	svcProxy := proxy.NewServiceProxy(proxy.Limits{
+		MaxInFlight:         cfg.ProxyMaxInFlight,
+		QueueTimeout:        time.Duration(cfg.ProxyQueueTimeoutMs) * time.Millisecond,
+		MaxResponseBytes:    cfg.ProxyMaxResponseBytes,
+		MaxRetries:          cfg.ProxyMaxRetries,
+		RetryBackoff:        time.Duration(cfg.ProxyRetryBackoffMs) * time.Millisecond,
+		BreakerThreshold:    cfg.ProxyBreakerThreshold,
+		BreakerOpenDuration: time.Duration(cfg.ProxyBreakerOpenMs) * time.Millisecond,
+		ResponseTimeout:     time.Duration(cfg.ProxyResponseTimeoutMs) * time.Millisecond,
+	}, cfg.InternalAPIToken)
+
+	// Route: Cross-user aggregates -> User Preference Service
+	// (registered before the movie wildcard so it is not captured by it)
+	app.All("/api/v1/movies/popular-among-users", svcProxy.ForwardTo(cfg.UserPreferenceServiceURL, ""))
 
 	// Route: Movies -> Movie Service
 	app.All("/api/v1/movies/*", svcProxy.ForwardTo(cfg.MovieServiceURL, ""))
 	app.All("/api/v1/movies", svcProxy.ForwardTo(cfg.MovieServiceURL, ""))
 
+	// Route: Genres -> Movie Service
+	app.All("/api/v1/genres/*", svcProxy.ForwardTo(cfg.MovieServiceURL, ""))
+	app.All("/api/v1/genres", svcProxy.ForwardTo(cfg.MovieServiceURL, ""))
+
+	// Route: Admin overview (aggregated by the gateway itself). It calls
+	// the services with the internal token, so it needs the admin token
+	overview := handler.NewOverviewHandler(services, cfg.InternalAPIToken, svcProxy)
+	app.Get("/api/v1/admin/overview", middleware.RequireAdminToken(cfg.AdminAPIToken), overview.Overview)
+
+	// Route: Admin user diagnostics -> Recommendation Service
+	app.All("/api/v1/admin/users/*", svcProxy.ForwardTo(cfg.RecommendationServiceURL, ""))
+
 	// Route: Admin sync -> Movie Service
 	app.All("/api/v1/admin/*", svcProxy.ForwardTo(cfg.MovieServiceURL, ""))
 	app.All("/api/v1/admin/sync", svcProxy.ForwardTo(cfg.MovieServiceURL, ""))
@@ -86,10 +137,16 @@ func main() {
 	app.All("/api/v1/users/:id/preferences", svcProxy.ForwardTo(cfg.UserPreferenceServiceURL, ""))
 	app.All("/api/v1/users/:id/interactions", svcProxy.ForwardTo(cfg.UserPreferenceServiceURL, ""))
 	app.All("/api/v1/users/:id/recommendations", svcProxy.ForwardTo(cfg.RecommendationServiceURL, ""))
+	app.All("/api/v1/users/:id/recommendations/history", svcProxy.ForwardTo(cfg.RecommendationServiceURL, ""))
+	app.All("/api/v1/users/:id/recommendations/:movieId/explain", svcProxy.ForwardTo(cfg.RecommendationServiceURL, ""))
+	app.Delete("/api/v1/users/:id/interactions/all", middleware.RequireOwner(), svcProxy.ForwardTo(cfg.UserPreferenceServiceURL, ""))
+	app.Delete("/api/v1/users/:id", middleware.RequireOwner(), svcProxy.ForwardTo(cfg.UserPreferenceServiceURL, ""))
+	app.Patch("/api/v1/users/:id", middleware.RequireOwner(), svcProxy.ForwardTo(cfg.UserPreferenceServiceURL, ""))
 	app.All("/api/v1/users/*", svcProxy.ForwardTo(cfg.UserPreferenceServiceURL, ""))
 	app.All("/api/v1/users", svcProxy.ForwardTo(cfg.UserPreferenceServiceURL, ""))
 
 	// Route: Rules -> Recommendation Service
+	app.All("/api/v1/rules/*", svcProxy.ForwardTo(cfg.RecommendationServiceURL, ""))
 	app.All("/api/v1/rules", svcProxy.ForwardTo(cfg.RecommendationServiceURL, ""))
 
 	// Graceful shutdown
@@ -106,8 +163,14 @@ func main() {
 	<-ctx.Done()
 	slog.Info("shutting down api-gateway...")
 
-	// Shutdown HTTP server first (stop accepting new requests)
-	if err := app.Shutdown(); err != nil {
+	// Stop accepting new requests and drain in-flight ones, bounded by
+	// the shutdown timeout
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := app.ShutdownWithContext(shutdownCtx); errors.Is(err, context.DeadlineExceeded) {
+		slog.Warn("shutdown deadline reached with requests still in flight",
+			"timeout", cfg.ShutdownTimeout, "open_connections", app.Server().GetOpenConnectionsCount())
+	} else if err != nil {
 		slog.Error("error shutting down HTTP server", "error", err)
 	}
 	slog.Info("HTTP server stopped")
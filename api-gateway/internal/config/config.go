@@ -1,8 +1,11 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -15,8 +18,53 @@ type Config struct {
 	RecommendationServiceURL string
 	RateLimitMax             int
 	RateLimitWindowSeconds   int
+	// InternalAPIToken is sent to the services' /internal routes and on
+	// every proxied request.
+	InternalAPIToken string
+	// AdminAPIToken is required (X-Admin-Token) on routes the gateway
+	// serves itself with internal credentials, such as the admin overview.
+	AdminAPIToken string
+	// ProxyMaxInFlight caps concurrent proxied requests per service
+	// (0 = unlimited); ProxyQueueTimeoutMs is how long a request over the
+	// cap waits for a slot before a 503 (0 = fail fast).
+	ProxyMaxInFlight    int
+	ProxyQueueTimeoutMs int
+	// ProxyMaxResponseBytes caps a proxied response body.
+	ProxyMaxResponseBytes int64
+	// ProxyResponseTimeoutMs bounds the wait for a service's response
+	// headers.
+	ProxyResponseTimeoutMs int
+	// ProxyMaxRetries is how often a GET/HEAD is retried on a connection
+	// error or 502/503/504, ProxyRetryBackoffMs the base wait in between.
+	ProxyMaxRetries     int
+	ProxyRetryBackoffMs int
+	// ProxyBreakerThreshold consecutive failures open a service's circuit
+	// for ProxyBreakerOpenMs (0 threshold = no breaker).
+	ProxyBreakerThreshold int
+	ProxyBreakerOpenMs    int
+	// AuthMode is "mock" (any Bearer token) or "jwt" (validated against
+	// JWTSecret for HS256 and/or JWTJWKSURL for RS256).
+	AuthMode   string
+	JWTSecret  string
+	JWTJWKSURL string
+	// HealthCheckDownstream makes /health probe every service;
+	// HealthCriticalServices are the ones whose outage turns it into a 503.
+	HealthCheckDownstream  bool
+	HealthCriticalServices []string
+	// PublicPathPrefixes are the request path prefixes that bypass
+	// authentication.
+	PublicPathPrefixes []string
+	// ShutdownTimeout bounds graceful shutdown, including draining
+	// in-flight requests.
+	ShutdownTimeout time.Duration
 }
 
+// Accepted AuthMode values.
+const (
+	AuthModeMock = "mock"
+	AuthModeJWT  = "jwt"
+)
+
 type RedisConfig struct {
 	Addr     string
 	Password string
@@ -29,6 +77,36 @@ func Load() (*Config, error) {
 	redisDB, _ := strconv.Atoi(getEnv("REDIS_DB", "3"))
 	rateLimitMax, _ := strconv.Atoi(getEnv("RATE_LIMIT_MAX", "100"))
 	rateLimitWindow, _ := strconv.Atoi(getEnv("RATE_LIMIT_WINDOW_SECONDS", "60"))
+	proxyMaxInFlight, _ := strconv.Atoi(getEnv("PROXY_MAX_IN_FLIGHT", "100"))
+	proxyQueueTimeout, _ := strconv.Atoi(getEnv("PROXY_QUEUE_TIMEOUT_MS", "0"))
+	proxyMaxResponse, _ := strconv.ParseInt(getEnv("PROXY_MAX_RESPONSE_BYTES", "52428800"), 10, 64)
+	proxyResponseTimeout, _ := strconv.Atoi(getEnv("PROXY_RESPONSE_TIMEOUT_MS", "120000"))
+	proxyMaxRetries, _ := strconv.Atoi(getEnv("PROXY_MAX_RETRIES", "2"))
+	proxyRetryBackoff, _ := strconv.Atoi(getEnv("PROXY_RETRY_BACKOFF_MS", "200"))
+	proxyBreakerThreshold, _ := strconv.Atoi(getEnv("PROXY_BREAKER_THRESHOLD", "5"))
+	proxyBreakerOpen, _ := strconv.Atoi(getEnv("PROXY_BREAKER_OPEN_MS", "10000"))
+
+	healthCheckDownstream, _ := strconv.ParseBool(getEnv("HEALTH_CHECK_DOWNSTREAM", "false"))
+	var healthCritical []string
+	for _, name := range strings.Split(getEnv("HEALTH_CRITICAL_SERVICES", "movie-service,user-preference-service"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			healthCritical = append(healthCritical, name)
+		}
+	}
+
+	var publicPrefixes []string
+	for _, prefix := range strings.Split(getEnv("PUBLIC_PATH_PREFIXES", "/health,/readyz,/swagger,/metrics"), ",") {
+		if prefix = strings.TrimSpace(prefix); prefix != "" {
+			publicPrefixes = append(publicPrefixes, prefix)
+		}
+	}
+
+	shutdownTimeout, _ := strconv.Atoi(getEnv("SHUTDOWN_TIMEOUT_SECONDS", "15"))
+
+	authMode := getEnv("AUTH_MODE", AuthModeMock)
+	if authMode != AuthModeMock && authMode != AuthModeJWT {
+		return nil, fmt.Errorf("invalid AUTH_MODE %q, must be one of: mock, jwt", authMode)
+	}
 
 	return &Config{
 		Redis: RedisConfig{
@@ -37,11 +115,28 @@ func Load() (*Config, error) {
 			DB:       redisDB,
 		},
 		Port:                     getEnv("SERVER_PORT", "8080"),
+		ShutdownTimeout:          time.Duration(shutdownTimeout) * time.Second,
 		MovieServiceURL:          getEnv("MOVIE_SERVICE_URL", "http://localhost:8081"),
 		UserPreferenceServiceURL: getEnv("USER_PREFERENCE_SERVICE_URL", "http://localhost:8082"),
 		RecommendationServiceURL: getEnv("RECOMMENDATION_SERVICE_URL", "http://localhost:8083"),
 		RateLimitMax:             rateLimitMax,
 		RateLimitWindowSeconds:   rateLimitWindow,
+		InternalAPIToken:         getEnv("INTERNAL_API_TOKEN", ""),
+		AdminAPIToken:            getEnv("ADMIN_API_TOKEN", ""),
+		ProxyMaxInFlight:         proxyMaxInFlight,
+		ProxyQueueTimeoutMs:      proxyQueueTimeout,
+		ProxyMaxResponseBytes:    proxyMaxResponse,
+		ProxyResponseTimeoutMs:   proxyResponseTimeout,
+		ProxyMaxRetries:          proxyMaxRetries,
+		ProxyRetryBackoffMs:      proxyRetryBackoff,
+		ProxyBreakerThreshold:    proxyBreakerThreshold,
+		ProxyBreakerOpenMs:       proxyBreakerOpen,
+		AuthMode:                 authMode,
+		JWTSecret:                getEnv("JWT_SECRET", ""),
+		JWTJWKSURL:               getEnv("JWT_JWKS_URL", ""),
+		HealthCheckDownstream:    healthCheckDownstream,
+		HealthCriticalServices:   healthCritical,
+		PublicPathPrefixes:       publicPrefixes,
 	}, nil
 }
 
@@ -0,0 +1,220 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// healthProbeTimeout bounds each downstream /api/v1/health call.
+	healthProbeTimeout = 2 * time.Second
+	// healthCacheTTL is how long a probe result is reused, so a burst of
+	// load-balancer checks costs one round of downstream calls.
+	healthCacheTTL = 2 * time.Second
+)
+
+// Health states. A dependency is ok or down; the gateway overall is ok or,
+// when any dependency is down, degraded.
+const (
+	HealthOK       = "ok"
+	HealthDegraded = "degraded"
+	HealthDown     = "down"
+)
+
+// DependencyHealth is one downstream service's probe result.
+type DependencyHealth struct {
+	Status    string `json:"status"`
+	Critical  bool   `json:"critical"`
+	LatencyMs int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+}
+
+// HealthReport is the body of GET /health with downstream probing on.
+type HealthReport struct {
+	Status       string                       `json:"status"`
+	Service      string                       `json:"service"`
+	Dependencies map[string]*DependencyHealth `json:"dependencies,omitempty"`
+	CheckedAt    string                       `json:"checked_at,omitempty"`
+
+	// criticalDown is set when a critical dependency is down.
+	criticalDown bool
+}
+
+// HealthHandler serves the gateway health check, optionally probing every
+// downstream service.
+type HealthHandler struct {
+	services map[string]string
+	critical map[string]bool
+	probe    bool
+	client   *http.Client
+	rdb      *redis.Client
+
+	mu        sync.Mutex
+	cached    *HealthReport
+	checkedAt time.Time
+}
+
+// NewHealthHandler creates a HealthHandler for the given services, keyed by
+// name with their base URLs as values. When probe is false the gateway only
+// reports on itself. critical names the services whose outage makes the
+// gateway answer 503; any other unhealthy service only marks it degraded.
+func NewHealthHandler(services map[string]string, critical []string, probe bool) *HealthHandler {
+	trimmed := make(map[string]string, len(services))
+	for name, baseURL := range services {
+		trimmed[name] = strings.TrimRight(baseURL, "/")
+	}
+	crit := make(map[string]bool, len(critical))
+	for _, name := range critical {
+		crit[strings.TrimSpace(name)] = true
+	}
+	return &HealthHandler{
+		services: trimmed,
+		critical: crit,
+		probe:    probe,
+		client:   &http.Client{Timeout: healthProbeTimeout},
+	}
+}
+
+// WithRedis adds the rate limiter's Redis to the probed dependencies. It is
+// never critical, since rate limiting fails open without it.
+func (h *HealthHandler) WithRedis(rdb *redis.Client) *HealthHandler {
+	h.rdb = rdb
+	return h
+}
+
+// Live godoc
+// GET /healthz
+// Liveness probe: 200 whenever the gateway process is serving requests,
+// regardless of its dependencies.
+func (h *HealthHandler) Live(c fiber.Ctx) error {
+	return c.JSON(HealthReport{Status: HealthOK, Service: "api-gateway"})
+}
+
+// Ready godoc
+// GET /readyz
+// Readiness probe: always probes the dependencies, whether or not /health
+// does, and returns 503 when a critical one is down.
+func (h *HealthHandler) Ready(c fiber.Ctx) error {
+	report := h.report()
+	if report.criticalDown {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(report)
+	}
+	return c.JSON(report)
+}
+
+// Health godoc
+// GET /health
+// Returns 200 when the gateway and every critical dependency are up, and
+// 503 when a critical dependency is down.
+func (h *HealthHandler) Health(c fiber.Ctx) error {
+	if !h.probe {
+		return c.JSON(HealthReport{Status: HealthOK, Service: "api-gateway"})
+	}
+
+	report := h.report()
+	if report.criticalDown {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(report)
+	}
+	return c.JSON(report)
+}
+
+// report returns the cached probe result, refreshing it when stale. The
+// lock is held while probing so concurrent checks share one round of calls;
+// the probes are not tied to any one caller's request context for the same
+// reason.
+func (h *HealthHandler) report() *HealthReport {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.cached != nil && time.Since(h.checkedAt) < healthCacheTTL {
+		return h.cached
+	}
+
+	deps := make(map[string]*DependencyHealth, len(h.services))
+	var wg sync.WaitGroup
+	for name, baseURL := range h.services {
+		dep := &DependencyHealth{Critical: h.critical[name]}
+		deps[name] = dep
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			err := h.check(context.Background(), baseURL+"/api/v1/health")
+			dep.LatencyMs = time.Since(start).Milliseconds()
+			if err != nil {
+				dep.Status = HealthDown
+				dep.Error = err.Error()
+				return
+			}
+			dep.Status = HealthOK
+		}()
+	}
+	if h.rdb != nil {
+		dep := &DependencyHealth{}
+		deps["redis"] = dep
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), healthProbeTimeout)
+			defer cancel()
+			start := time.Now()
+			err := h.rdb.Ping(ctx).Err()
+			dep.LatencyMs = time.Since(start).Milliseconds()
+			if err != nil {
+				dep.Status = HealthDown
+				dep.Error = err.Error()
+				return
+			}
+			dep.Status = HealthOK
+		}()
+	}
+	wg.Wait()
+
+	report := &HealthReport{
+		Status:       HealthOK,
+		Service:      "api-gateway",
+		Dependencies: deps,
+		CheckedAt:    time.Now().UTC().Format(time.RFC3339),
+	}
+	for _, dep := range deps {
+		if dep.Status != HealthOK {
+			report.Status = HealthDegraded
+			report.criticalDown = report.criticalDown || dep.Critical
+		}
+	}
+
+	h.cached = report
+	h.checkedAt = time.Now()
+	return h.cached
+}
+
+// check GETs url and succeeds on a 200.
+func (h *HealthHandler) check(ctx context.Context, url string) error {
+	ctx, cancel := context.WithTimeout(ctx, healthProbeTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
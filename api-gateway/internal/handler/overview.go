@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+
+	"movie-discovery-api-gateway/internal/proxy"
+)
+
+// overviewTimeout bounds each downstream call made for the admin overview.
+const overviewTimeout = 3 * time.Second
+
+// ServiceStatus is one service's section of the admin overview. Readiness
+// and Stats are passed through from the service as-is.
+type ServiceStatus struct {
+	Readiness      json.RawMessage `json:"readiness,omitempty"`
+	ReadinessError string          `json:"readiness_error,omitempty"`
+	Stats          json.RawMessage `json:"stats,omitempty"`
+	StatsError     string          `json:"stats_error,omitempty"`
+}
+
+// OverviewHandler aggregates readiness and stats from every service.
+type OverviewHandler struct {
+	services      map[string]string
+	internalToken string
+	client        *http.Client
+	proxy         *proxy.ServiceProxy
+}
+
+// NewOverviewHandler creates an OverviewHandler for the given services,
+// keyed by name with their base URLs as values. The proxy's per-upstream
+// in-flight counts are included in the overview.
+func NewOverviewHandler(services map[string]string, internalToken string, svcProxy *proxy.ServiceProxy) *OverviewHandler {
+	trimmed := make(map[string]string, len(services))
+	for name, baseURL := range services {
+		trimmed[name] = strings.TrimRight(baseURL, "/")
+	}
+	return &OverviewHandler{
+		services:      trimmed,
+		internalToken: internalToken,
+		client:        &http.Client{Timeout: overviewTimeout},
+		proxy:         svcProxy,
+	}
+}
+
+// Overview godoc
+// GET /api/v1/admin/overview
+// Fans out to every service concurrently. A service that cannot be reached
+// is reported with an error rather than failing the whole response.
+func (h *OverviewHandler) Overview(c fiber.Ctx) error {
+	ctx := c.Context()
+	results := make(map[string]*ServiceStatus, len(h.services))
+
+	var wg sync.WaitGroup
+	for name, baseURL := range h.services {
+		status := &ServiceStatus{}
+		results[name] = status
+
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			// Not-ready services answer 503 with a useful body
+			body, err := h.fetch(ctx, baseURL+"/api/v1/health/ready", http.StatusOK, http.StatusServiceUnavailable)
+			if err != nil {
+				status.ReadinessError = err.Error()
+				return
+			}
+			status.Readiness = body
+		}()
+		go func() {
+			defer wg.Done()
+			body, err := h.fetch(ctx, baseURL+"/internal/stats", http.StatusOK)
+			if err != nil {
+				status.StatsError = err.Error()
+				return
+			}
+			status.Stats = body
+		}()
+	}
+	wg.Wait()
+
+	return c.JSON(fiber.Map{
+		"services":     results,
+		"proxy":        h.proxy.Stats(),
+		"generated_at": time.Now().UTC().Format(time.RFC3339),
+	})
+}
+
+// fetch GETs url and returns its JSON body if the status is one of accepted.
+func (h *OverviewHandler) fetch(ctx context.Context, url string, accepted ...int) (json.RawMessage, error) {
+	ctx, cancel := context.WithTimeout(ctx, overviewTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if h.internalToken != "" {
+		req.Header.Set("X-Internal-Token", h.internalToken)
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+
+	for _, code := range accepted {
+		if resp.StatusCode == code && json.Valid(body) {
+			return body, nil
+		}
+	}
+	return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+}
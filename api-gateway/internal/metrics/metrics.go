@@ -0,0 +1,88 @@
+// Package metrics exposes Prometheus metrics for the service's HTTP traffic
+// on /metrics.
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/middleware/adaptor"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Path is where the metrics are served.
+const Path = "/metrics"
+
+var (
+	requestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests handled, by method, route, status and user tier.",
+	}, []string{"method", "route", "status", "tier"})
+
+	requestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency, by method, route, status and user tier.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status", "tier"})
+
+	// The route is only known once routing has finished, so requests in
+	// flight are labeled by method alone.
+	requestsInFlight = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "http_requests_in_flight",
+		Help: "HTTP requests currently being handled, by method.",
+	}, []string{"method"})
+)
+
+// tiers are the user tiers used as label values; any other tier, and
+// requests without one, are labeled "anonymous".
+var tiers = map[string]bool{"free": true, "premium": true, "admin": true}
+
+// Middleware records request count, latency and in-flight requests. Routes
+// are labeled by their pattern (e.g. /api/v1/movies/:id), not the raw path,
+// and tiers by tierLabel, to keep label cardinality bounded. Scrapes of
+// Path are not recorded.
+func Middleware() fiber.Handler {
+	return func(c fiber.Ctx) error {
+		if c.Path() == Path {
+			return c.Next()
+		}
+
+		method := c.Method()
+		inFlight := requestsInFlight.WithLabelValues(method)
+		inFlight.Inc()
+		defer inFlight.Dec()
+
+		start := time.Now()
+		err := c.Next()
+
+		status := c.Response().StatusCode()
+		if err != nil {
+			// The error handler has not run yet; use the status it will set
+			status = fiber.StatusInternalServerError
+			if e, ok := err.(*fiber.Error); ok {
+				status = e.Code
+			}
+		}
+		labels := []string{method, c.Route().Path, strconv.Itoa(status), tierLabel(c)}
+		requestsTotal.WithLabelValues(labels...).Inc()
+		requestDuration.WithLabelValues(labels...).Observe(time.Since(start).Seconds())
+		return err
+	}
+}
+
+// tierLabel returns the tier the auth middleware stored in c.Locals("tier")
+// if it is a known one, and "anonymous" otherwise.
+func tierLabel(c fiber.Ctx) string {
+	if tier, ok := c.Locals("tier").(string); ok && tiers[tier] {
+		return tier
+	}
+	return "anonymous"
+}
+
+// Handler serves the registered metrics in the Prometheus exposition format.
+func Handler() fiber.Handler {
+	return adaptor.HTTPHandler(promhttp.Handler())
+}
@@ -1,17 +1,19 @@
 package middleware
 
 import (
+	"crypto/subtle"
+	"errors"
 	"strings"
 
 	"github.com/gofiber/fiber/v3"
 )
 
-// AuthMiddleware provides mock Bearer token authentication.
-// Any non-empty Bearer token is considered valid.
-// Public paths (health, swagger) bypass authentication.
-func AuthMiddleware() fiber.Handler {
-	publicPrefixes := []string{"/health", "/swagger"}
-
+// AuthMiddleware provides Bearer token authentication. With a nil verifier
+// (AUTH_MODE=mock) any non-empty Bearer token is considered valid; otherwise
+// the token must be a valid JWT, and its sub and tier claims are stored in
+// c.Locals("user_id") and c.Locals("tier").
+// Paths starting with one of publicPrefixes bypass authentication.
+func AuthMiddleware(verifier *JWTVerifier, publicPrefixes []string) fiber.Handler {
 	return func(c fiber.Ctx) error {
 		path := c.Path()
 
@@ -42,10 +44,57 @@ func AuthMiddleware() fiber.Handler {
 			})
 		}
 
-		// Mock validation: accept any non-empty token
-		// In production, validate JWT or call an auth service here
+		// Mock mode accepts any non-empty token
+		if verifier != nil {
+			claims, err := verifier.Verify(token)
+			if err != nil {
+				msg := "invalid token"
+				if errors.Is(err, ErrTokenExpired) {
+					msg = "token expired"
+				}
+				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+					"error": msg,
+				})
+			}
+			c.Locals("user_id", claims.Subject)
+			c.Locals("tier", claims.Tier)
+		}
 		c.Locals("auth_token", token)
 
 		return c.Next()
 	}
 }
+
+// AdminTokenHeader carries the admin token, as on the services.
+const AdminTokenHeader = "X-Admin-Token"
+
+// RequireAdminToken restricts a route to callers presenting the admin token
+// in X-Admin-Token. An empty token disables the route.
+func RequireAdminToken(token string) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		if token == "" || subtle.ConstantTimeCompare([]byte(c.Get(AdminTokenHeader)), []byte(token)) != 1 {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "admin token required",
+			})
+		}
+		return c.Next()
+	}
+}
+
+// RequireOwner restricts a route to the user named by its :id parameter: in
+// JWT mode the token's sub claim must match it. Mock mode has no user
+// identity to check, so every authenticated request passes.
+func RequireOwner() fiber.Handler {
+	return func(c fiber.Ctx) error {
+		subject, ok := c.Locals("user_id").(string)
+		if !ok {
+			return c.Next()
+		}
+		if subject != c.Params("id") {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "only the user themselves may do this",
+			})
+		}
+		return c.Next()
+	}
+}
@@ -0,0 +1,255 @@
+package middleware
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Token validation errors.
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrTokenExpired = errors.New("token expired")
+)
+
+// clockSkew tolerates small clock differences with the token issuer.
+const clockSkew = 30 * time.Second
+
+// JWKS keys are refetched after jwksRefreshInterval, or sooner when a token
+// names an unknown key, but never more than once per jwksMinRefreshInterval.
+const (
+	jwksRefreshInterval    = 10 * time.Minute
+	jwksMinRefreshInterval = time.Minute
+)
+
+// Claims are the JWT claims the gateway relies on.
+type Claims struct {
+	Subject   string  `json:"sub"`
+	ExpiresAt float64 `json:"exp"`
+	NotBefore float64 `json:"nbf"`
+	// Tier is the caller's plan (free, premium or admin), if the issuer
+	// sets one.
+	Tier string `json:"tier"`
+}
+
+// JWTVerifier validates HS256 tokens against a shared secret and RS256
+// tokens against the keys published at a JWKS URL. Only the algorithms
+// with a configured key are accepted.
+type JWTVerifier struct {
+	secret []byte
+	jwks   *jwksCache
+}
+
+// NewJWTVerifier creates a verifier; at least one of secret and jwksURL
+// must be set.
+func NewJWTVerifier(secret, jwksURL string) (*JWTVerifier, error) {
+	if secret == "" && jwksURL == "" {
+		return nil, errors.New("JWT auth requires JWT_SECRET or JWT_JWKS_URL")
+	}
+	v := &JWTVerifier{}
+	if secret != "" {
+		v.secret = []byte(secret)
+	}
+	if jwksURL != "" {
+		v.jwks = &jwksCache{
+			url:    jwksURL,
+			client: &http.Client{Timeout: 5 * time.Second},
+			keys:   make(map[string]*rsa.PublicKey),
+		}
+	}
+	return v, nil
+}
+
+// Verify checks the token's signature, exp and nbf, and returns its claims.
+func (v *JWTVerifier) Verify(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, ErrInvalidToken
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	signingInput := parts[0] + "." + parts[1]
+	switch {
+	case header.Alg == "HS256" && v.secret != nil:
+		mac := hmac.New(sha256.New, v.secret)
+		mac.Write([]byte(signingInput))
+		if !hmac.Equal(mac.Sum(nil), sig) {
+			return nil, ErrInvalidToken
+		}
+	case header.Alg == "RS256" && v.jwks != nil:
+		key, err := v.jwks.key(header.Kid)
+		if err != nil {
+			slog.Warn("JWT signing key unavailable", "kid", header.Kid, "error", err)
+			return nil, ErrInvalidToken
+		}
+		digest := sha256.Sum256([]byte(signingInput))
+		if rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig) != nil {
+			return nil, ErrInvalidToken
+		}
+	default:
+		// Covers "none" and algorithms without a configured key
+		return nil, ErrInvalidToken
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+	now := time.Now()
+	if claims.ExpiresAt == 0 || now.After(unixTime(claims.ExpiresAt).Add(clockSkew)) {
+		return nil, ErrTokenExpired
+	}
+	if claims.NotBefore != 0 && now.Add(clockSkew).Before(unixTime(claims.NotBefore)) {
+		return nil, ErrInvalidToken
+	}
+	return &claims, nil
+}
+
+func decodeSegment(seg string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func unixTime(seconds float64) time.Time {
+	return time.Unix(int64(seconds), 0)
+}
+
+// jwksCache holds the RSA keys published at a JWKS URL, keyed by kid. The
+// JWKS endpoint is fetched without holding mu, so requests for known keys
+// are never held up by it.
+type jwksCache struct {
+	url    string
+	client *http.Client
+
+	mu          sync.RWMutex
+	keys        map[string]*rsa.PublicKey
+	fetchedAt   time.Time
+	attemptedAt time.Time
+	// fetching is closed when the running fetch finishes; nil when none is.
+	fetching chan struct{}
+}
+
+func (j *jwksCache) key(kid string) (*rsa.PublicKey, error) {
+	j.mu.RLock()
+	key, ok := j.keys[kid]
+	fresh := time.Since(j.fetchedAt) < jwksRefreshInterval
+	j.mu.RUnlock()
+	if ok && fresh {
+		return key, nil
+	}
+
+	j.mu.Lock()
+	done := j.startRefresh()
+	j.mu.Unlock()
+	if ok {
+		// Keep serving the key we have while the set refreshes
+		return key, nil
+	}
+	if done != nil {
+		<-done
+	}
+
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	if key, ok := j.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// startRefresh starts a fetch of the key set unless one is running or the
+// last attempt was under jwksMinRefreshInterval ago, and returns the running
+// fetch's done channel, or nil when there is none. The caller holds j.mu.
+func (j *jwksCache) startRefresh() <-chan struct{} {
+	if j.fetching != nil {
+		return j.fetching
+	}
+	if time.Since(j.attemptedAt) < jwksMinRefreshInterval {
+		return nil
+	}
+	j.attemptedAt = time.Now()
+	done := make(chan struct{})
+	j.fetching = done
+
+	go func() {
+		keys, err := j.fetch()
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		if err != nil {
+			// Keep serving the keys we have
+			slog.Error("failed to refresh JWKS", "url", j.url, "error", err)
+		} else {
+			j.keys = keys
+			j.fetchedAt = time.Now()
+		}
+		j.fetching = nil
+		close(done)
+	}()
+	return done
+}
+
+// fetch downloads and parses the key set. It does not touch the cache.
+func (j *jwksCache) fetch() (map[string]*rsa.PublicKey, error) {
+	resp, err := j.client.Get(j.url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("JWKS endpoint returned %d", resp.StatusCode)
+	}
+
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("decode JWKS: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, errN := base64.RawURLEncoding.DecodeString(k.N)
+		e, errE := base64.RawURLEncoding.DecodeString(k.E)
+		if errN != nil || errE != nil || len(e) == 0 || len(e) > 4 {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	return keys, nil
+}
@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// jwksServer publishes pub under the kids sent on its channel, one per
+// request, so a test decides when each fetch completes.
+func jwksServer(t *testing.T, pub *rsa.PublicKey, kids <-chan string, hits *atomic.Int32) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		kid := <-kids
+		n := base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+		e := base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		fmt.Fprintf(w, `{"keys":[{"kty":"RSA","kid":%q,"n":%q,"e":%q}]}`, kid, n, e)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestJWKSCacheServesKnownKeysDuringFetch(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kids := make(chan string)
+	var hits atomic.Int32
+	srv := jwksServer(t, &priv.PublicKey, kids, &hits)
+
+	// "old" is cached but due for a refresh
+	j := &jwksCache{
+		url:       srv.URL,
+		client:    srv.Client(),
+		keys:      map[string]*rsa.PublicKey{"old": &priv.PublicKey},
+		fetchedAt: time.Now().Add(-2 * jwksRefreshInterval),
+	}
+
+	// Tokens with an unknown kid all wait on one fetch
+	var wg sync.WaitGroup
+	errs := make(chan error, 5)
+	for range 5 {
+		wg.Go(func() {
+			_, err := j.key("new")
+			errs <- err
+		})
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for hits.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	// While the JWKS endpoint is stalled, the cached key is still served
+	got := make(chan error, 1)
+	go func() {
+		_, err := j.key("old")
+		got <- err
+	}()
+	select {
+	case err := <-got:
+		if err != nil {
+			t.Errorf("cached key: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("cached key blocked behind the JWKS fetch")
+	}
+
+	kids <- "new"
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("new key: %v", err)
+		}
+	}
+	if n := hits.Load(); n != 1 {
+		t.Errorf("JWKS fetched %d times, want 1", n)
+	}
+	if _, err := j.key("old"); err == nil {
+		t.Error("key dropped from the JWKS is still served after the refresh")
+	}
+}
@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// ProblemContentType is the RFC 7807 media type.
+const ProblemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details object.
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance"`
+}
+
+// ProblemDetails renders error responses as RFC 7807 problem details for
+// clients sending Accept: application/problem+json. It rewrites the
+// {"error": "..."} bodies produced by the gateway and the services, so it
+// must run before any middleware that can answer with an error. Other
+// clients keep the plain shape.
+func ProblemDetails() fiber.Handler {
+	return func(c fiber.Ctx) error {
+		err := c.Next()
+		if !strings.Contains(c.Get(fiber.HeaderAccept), ProblemContentType) {
+			return err
+		}
+
+		if err != nil {
+			status, detail := fiber.StatusInternalServerError, err.Error()
+			var fe *fiber.Error
+			if errors.As(err, &fe) {
+				status, detail = fe.Code, fe.Message
+			}
+			return writeProblem(c, status, detail)
+		}
+
+		status := c.Response().StatusCode()
+		contentType := string(c.Response().Header.ContentType())
+		if status < fiber.StatusBadRequest || !strings.HasPrefix(contentType, fiber.MIMEApplicationJSON) {
+			return nil
+		}
+
+		var body struct {
+			Error string `json:"error"`
+		}
+		_ = json.Unmarshal(c.Response().Body(), &body)
+		return writeProblem(c, status, body.Error)
+	}
+}
+
+func writeProblem(c fiber.Ctx, status int, detail string) error {
+	data, err := json.Marshal(Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   detail,
+		Instance: c.OriginalURL(),
+	})
+	if err != nil {
+		return err
+	}
+	c.Set(fiber.HeaderContentType, ProblemContentType)
+	return c.Status(status).Send(data)
+}
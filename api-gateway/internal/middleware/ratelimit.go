@@ -2,8 +2,11 @@ package middleware
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"log/slog"
+	"sync/atomic"
 	"time"
 
 	"github.com/gofiber/fiber/v3"
@@ -28,54 +31,103 @@ func NewRedisClient(cfg config.RedisConfig) (*redis.Client, error) {
 	return client, nil
 }
 
-// RateLimiter provides Redis-backed sliding window rate limiting.
+// slidingWindowScript keeps a sorted set of request timestamps (ms) per
+// key. It trims entries older than the window, admits the request only when
+// fewer than max remain, and returns {allowed, count, ms until the oldest
+// entry leaves the window}. Redis' own clock is used so every gateway
+// instance agrees on the window.
+var slidingWindowScript = redis.NewScript(`
+local key = KEYS[1]
+local window = tonumber(ARGV[1])
+local limit = tonumber(ARGV[2])
+local member = ARGV[3]
+
+local t = redis.call('TIME')
+local now = t[1] * 1000 + math.floor(t[2] / 1000)
+
+redis.call('ZREMRANGEBYSCORE', key, '-inf', now - window)
+local count = redis.call('ZCARD', key)
+local allowed = 0
+if count < limit then
+	redis.call('ZADD', key, now, now .. '-' .. member)
+	count = count + 1
+	allowed = 1
+end
+redis.call('PEXPIRE', key, window)
+
+local reset = window
+local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+if oldest[2] then
+	reset = tonumber(oldest[2]) + window - now
+end
+return {allowed, count, reset}
+`)
+
+// RateLimiter provides Redis-backed sliding window rate limiting: at most
+// maxReqs requests are admitted in any rolling windowSec period.
 type RateLimiter struct {
 	rdb       *redis.Client
 	maxReqs   int
 	windowSec int
+	instance  string
+	seq       atomic.Uint64
 }
 
-// NewRateLimiter creates a rate limiter.
+// NewRateLimiter creates a rate limiter. Each limiter gets a random instance
+// ID so sorted-set members stay unique across gateway replicas sharing Redis.
 func NewRateLimiter(rdb *redis.Client, maxReqs, windowSec int) *RateLimiter {
+	id := make([]byte, 8)
+	rand.Read(id)
 	return &RateLimiter{
 		rdb:       rdb,
 		maxReqs:   maxReqs,
 		windowSec: windowSec,
+		instance:  hex.EncodeToString(id),
 	}
 }
 
+// take records one request against key if the window has room. It returns
+// whether the request is allowed, the requests now in the window, and how
+// long until the oldest of them expires.
+func (rl *RateLimiter) take(ctx context.Context, key string) (bool, int64, time.Duration, error) {
+	window := time.Duration(rl.windowSec) * time.Second
+	// Members must be unique within a millisecond across gateway instances
+	member := fmt.Sprintf("%s-%d", rl.instance, rl.seq.Add(1))
+	res, err := slidingWindowScript.Run(ctx, rl.rdb, []string{key},
+		window.Milliseconds(), rl.maxReqs, member).Int64Slice()
+	if err != nil {
+		return false, 0, 0, err
+	}
+	if len(res) != 3 {
+		return false, 0, 0, fmt.Errorf("unexpected rate limit script result %v", res)
+	}
+	return res[0] == 1, res[1], time.Duration(res[2]) * time.Millisecond, nil
+}
+
 // Handler returns a Fiber middleware handler for rate limiting.
 func (rl *RateLimiter) Handler() fiber.Handler {
 	return func(c fiber.Ctx) error {
 		// Use client IP as the rate limit key
 		ip := c.IP()
 		key := fmt.Sprintf("ratelimit:%s", ip)
-		ctx := context.Background()
 
-		// Increment counter
-		count, err := rl.rdb.Incr(ctx, key).Result()
+		allowed, count, reset, err := rl.take(context.Background(), key)
 		if err != nil {
 			// If Redis fails, allow the request (fail-open)
 			return c.Next()
 		}
-
-		// Set expiry on first request in the window
-		if count == 1 {
-			rl.rdb.Expire(ctx, key, time.Duration(rl.windowSec)*time.Second)
-		}
-
-		// Get remaining TTL for headers
-		ttl, _ := rl.rdb.TTL(ctx, key).Result()
+		resetSec := int((reset + time.Second - 1) / time.Second)
 
 		// Set rate limit headers
 		c.Set("X-RateLimit-Limit", fmt.Sprintf("%d", rl.maxReqs))
 		c.Set("X-RateLimit-Remaining", fmt.Sprintf("%d", max(0, int64(rl.maxReqs)-count)))
-		c.Set("X-RateLimit-Reset", fmt.Sprintf("%d", int(ttl.Seconds())))
+		c.Set("X-RateLimit-Reset", fmt.Sprintf("%d", resetSec))
 
-		if int(count) > rl.maxReqs {
+		if !allowed {
+			c.Set("Retry-After", fmt.Sprintf("%d", resetSec))
 			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
 				"error":       "rate limit exceeded",
-				"retry_after": int(ttl.Seconds()),
+				"retry_after": resetSec,
 			})
 		}
 
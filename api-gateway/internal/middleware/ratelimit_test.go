@@ -0,0 +1,207 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gofiber/fiber/v3"
+	"github.com/redis/go-redis/v9"
+)
+
+// newMiniRedis starts an in-process Redis whose clock the test controls.
+func newMiniRedis(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	mr.SetTime(time.UnixMilli(1_700_000_000_000))
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	return mr, rdb
+}
+
+func TestRateLimiterNeverExceedsMaxInRollingWindow(t *testing.T) {
+	const (
+		maxReqs   = 5
+		windowSec = 10
+		window    = windowSec * time.Second
+	)
+	mr, rdb := newMiniRedis(t)
+	// Two limiters stand in for two gateway replicas sharing one Redis
+	limiters := []*RateLimiter{
+		NewRateLimiter(rdb, maxReqs, windowSec),
+		NewRateLimiter(rdb, maxReqs, windowSec),
+	}
+	ctx := context.Background()
+	rng := rand.New(rand.NewPCG(1, 2))
+
+	now := time.UnixMilli(1_700_000_000_000)
+	var admitted []time.Time
+	for range 2000 {
+		// Mostly bursts within the same millisecond, sometimes long gaps
+		if rng.IntN(3) == 0 {
+			now = now.Add(time.Duration(rng.IntN(1500)) * time.Millisecond)
+			mr.SetTime(now)
+		}
+		allowed, _, _, err := limiters[rng.IntN(len(limiters))].take(ctx, "ratelimit:test")
+		if err != nil {
+			t.Fatalf("take: %v", err)
+		}
+		if allowed {
+			admitted = append(admitted, now)
+		}
+	}
+
+	if len(admitted) < maxReqs {
+		t.Fatalf("only %d requests admitted", len(admitted))
+	}
+	// admitted is in time order; every request plus those in the window
+	// ending at it must fit in maxReqs
+	start := 0
+	for i, at := range admitted {
+		for !admitted[start].After(at.Add(-window)) {
+			start++
+		}
+		if n := i - start + 1; n > maxReqs {
+			t.Fatalf("%d requests admitted in the window ending at %v, want at most %d",
+				n, at.Sub(admitted[0]), maxReqs)
+		}
+	}
+}
+
+func TestRateLimiterReplicasDoNotShareMembers(t *testing.T) {
+	_, rdb := newMiniRedis(t)
+	a, b := NewRateLimiter(rdb, 4, 60), NewRateLimiter(rdb, 4, 60)
+	if a.instance == b.instance {
+		t.Fatalf("both limiters got instance ID %q", a.instance)
+	}
+
+	// Same millisecond, same sequence numbers: members may only differ by
+	// instance ID, otherwise ZADD overwrites and the limit leaks
+	ctx := context.Background()
+	admitted := 0
+	for range 4 {
+		for _, rl := range []*RateLimiter{a, b} {
+			allowed, _, _, err := rl.take(ctx, "ratelimit:test")
+			if err != nil {
+				t.Fatalf("take: %v", err)
+			}
+			if allowed {
+				admitted++
+			}
+		}
+	}
+	if admitted != 4 {
+		t.Errorf("%d requests admitted, want 4", admitted)
+	}
+}
+
+func TestRateLimiterAdmitsAgainOnceOldestExpires(t *testing.T) {
+	mr, rdb := newMiniRedis(t)
+	rl := NewRateLimiter(rdb, 2, 1)
+	ctx := context.Background()
+	start := time.UnixMilli(1_700_000_000_000)
+
+	for i, step := range []struct {
+		offset  time.Duration
+		allowed bool
+	}{
+		{0, true},
+		{400 * time.Millisecond, true},
+		{999 * time.Millisecond, false},
+		{time.Second, true}, // the first request has left the window
+		{1100 * time.Millisecond, false},
+		{1400 * time.Millisecond, true},
+	} {
+		mr.SetTime(start.Add(step.offset))
+		allowed, _, _, err := rl.take(ctx, "ratelimit:test")
+		if err != nil {
+			t.Fatalf("take: %v", err)
+		}
+		if allowed != step.allowed {
+			t.Errorf("request %d at +%v: allowed = %v, want %v", i, step.offset, allowed, step.allowed)
+		}
+	}
+}
+
+func TestRateLimiterHandlerSetsHeaders(t *testing.T) {
+	_, rdb := newMiniRedis(t)
+	app := fiber.New()
+	app.Use(NewRateLimiter(rdb, 1, 60).Handler())
+	app.Get("/", func(c fiber.Ctx) error { return c.SendStatus(fiber.StatusNoContent) })
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != fiber.StatusNoContent || resp.Header.Get("X-RateLimit-Remaining") != "0" {
+		t.Fatalf("first request: status %d, remaining %q", resp.StatusCode, resp.Header.Get("X-RateLimit-Remaining"))
+	}
+
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != fiber.StatusTooManyRequests {
+		t.Fatalf("second request: status %d, want 429", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Retry-After"); got != "60" {
+		t.Errorf("Retry-After = %q, want 60", got)
+	}
+}
+
+func TestRateLimiterFailsOpenWhenRedisIsDown(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1})
+	t.Cleanup(func() { rdb.Close() })
+	mr.Close()
+	app := fiber.New()
+	app.Use(NewRateLimiter(rdb, 1, 60).Handler())
+	app.Get("/", func(c fiber.Ctx) error { return c.SendStatus(fiber.StatusNoContent) })
+
+	for range 3 {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/", nil), fiber.TestConfig{Timeout: 10 * time.Second})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != fiber.StatusNoContent {
+			t.Fatalf("status %d, want 204", resp.StatusCode)
+		}
+	}
+}
+
+// BenchmarkRateLimiterTake measures one sliding-window check across 100
+// client keys. Set TEST_REDIS_ADDR to measure against a real Redis.
+func BenchmarkRateLimiterTake(b *testing.B) {
+	addr := os.Getenv("TEST_REDIS_ADDR")
+	if addr == "" {
+		addr = miniredis.RunT(b).Addr()
+	}
+	rdb := redis.NewClient(&redis.Options{Addr: addr})
+	b.Cleanup(func() { rdb.Close() })
+	ctx := context.Background()
+	if err := rdb.Ping(ctx).Err(); err != nil {
+		b.Skipf("redis unavailable at %s: %v", addr, err)
+	}
+
+	rl := NewRateLimiter(rdb, 100, 60)
+	keys := make([]string, 100)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("ratelimit:bench:%d", i)
+	}
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			if _, _, _, err := rl.take(ctx, keys[i%len(keys)]); err != nil {
+				b.Error(err)
+				return
+			}
+			i++
+		}
+	})
+}
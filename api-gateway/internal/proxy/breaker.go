@@ -0,0 +1,100 @@
+package proxy
+
+import (
+	"sync"
+	"time"
+)
+
+// Circuit states reported in UpstreamStats.
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half_open"
+)
+
+// circuitBreaker stops calls to an upstream after threshold consecutive
+// failures. While open, requests fail fast; once openFor has elapsed one
+// probe request is let through, and its outcome closes or re-opens the
+// circuit. A threshold of 0 disables the breaker.
+type circuitBreaker struct {
+	threshold int
+	openFor   time.Duration
+
+	mu       sync.Mutex
+	failures int
+	open     bool
+	openedAt time.Time
+	trips    int64
+}
+
+func newCircuitBreaker(threshold int, openFor time.Duration) *circuitBreaker {
+	return &circuitBreaker{threshold: threshold, openFor: openFor}
+}
+
+// allow reports whether a request may be sent. When the open period has
+// elapsed it admits one probe and restarts the period, so a probe whose
+// outcome is never reported cannot wedge the circuit.
+func (b *circuitBreaker) allow() bool {
+	if b.threshold <= 0 {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.open {
+		return true
+	}
+	if time.Since(b.openedAt) < b.openFor {
+		return false
+	}
+	b.openedAt = time.Now()
+	return true
+}
+
+func (b *circuitBreaker) success() {
+	if b.threshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.open = false
+}
+
+func (b *circuitBreaker) failure() {
+	if b.threshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	if b.open {
+		// Failed probe: stay open for another period
+		b.openedAt = time.Now()
+		return
+	}
+	if b.failures >= b.threshold {
+		b.open = true
+		b.openedAt = time.Now()
+		b.trips++
+	}
+}
+
+// retryAfter is how long until the next probe is admitted.
+func (b *circuitBreaker) retryAfter() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return max(0, b.openFor-time.Since(b.openedAt))
+}
+
+func (b *circuitBreaker) state() (string, int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch {
+	case !b.open:
+		return CircuitClosed, b.trips
+	case time.Since(b.openedAt) >= b.openFor:
+		return CircuitHalfOpen, b.trips
+	default:
+		return CircuitOpen, b.trips
+	}
+}
@@ -0,0 +1,78 @@
+package proxy
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// upstreamLimiter caps the number of in-flight requests to one upstream.
+// A nil sem means the upstream is unlimited.
+type upstreamLimiter struct {
+	sem      chan struct{}
+	inFlight atomic.Int64
+	rejected atomic.Int64
+}
+
+func newUpstreamLimiter(maxInFlight int) *upstreamLimiter {
+	l := &upstreamLimiter{}
+	if maxInFlight > 0 {
+		l.sem = make(chan struct{}, maxInFlight)
+	}
+	return l
+}
+
+// acquire reserves a slot, waiting up to queueTimeout for one to free up.
+// A zero queueTimeout fails fast. It reports false when no slot was
+// obtained; otherwise the caller must call release.
+func (l *upstreamLimiter) acquire(ctx context.Context, queueTimeout time.Duration) bool {
+	if l.sem != nil {
+		select {
+		case l.sem <- struct{}{}:
+		default:
+			if queueTimeout <= 0 || !l.wait(ctx, queueTimeout) {
+				l.rejected.Add(1)
+				return false
+			}
+		}
+	}
+	l.inFlight.Add(1)
+	return true
+}
+
+func (l *upstreamLimiter) wait(ctx context.Context, queueTimeout time.Duration) bool {
+	timer := time.NewTimer(queueTimeout)
+	defer timer.Stop()
+	select {
+	case l.sem <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (l *upstreamLimiter) release() {
+	l.inFlight.Add(-1)
+	if l.sem != nil {
+		<-l.sem
+	}
+}
+
+// UpstreamStats reports the concurrency and circuit state of one upstream.
+type UpstreamStats struct {
+	InFlight     int64  `json:"in_flight"`
+	MaxInFlight  int    `json:"max_in_flight"`
+	Rejected     int64  `json:"rejected"`
+	Circuit      string `json:"circuit,omitempty"`
+	CircuitTrips int64  `json:"circuit_trips,omitempty"`
+}
+
+func (l *upstreamLimiter) stats() UpstreamStats {
+	return UpstreamStats{
+		InFlight:    l.inFlight.Load(),
+		MaxInFlight: cap(l.sem),
+		Rejected:    l.rejected.Load(),
+	}
+}
@@ -1,41 +1,181 @@
 package proxy
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gofiber/fiber/v3"
 )
 
+// DefaultMaxResponseBytes bounds an upstream response when no limit is
+// configured.
+const DefaultMaxResponseBytes = 50 << 20
+
+// DefaultResponseTimeout bounds the wait for upstream response headers when
+// no timeout is configured.
+const DefaultResponseTimeout = 120 * time.Second
+
+// forwardedRequestHeaders are copied from the client request as-is.
+// X-Request-ID is always present (set by the requestid middleware when the
+// client sent none) and correlates logs across services. Range and If-Range
+// let media be fetched in parts; the upstream's 206 status and
+// Content-Range/Accept-Ranges headers are relayed like any other response.
+// (The transport skips transparent gzip when Range is set, so byte offsets
+// always refer to the upstream's representation.)
+var forwardedRequestHeaders = []string{"Authorization", "X-Admin-Token", "X-Request-ID", "Range", "If-Range"}
+
+// Limits bounds the load the proxy puts on upstreams and on itself.
+type Limits struct {
+	// MaxInFlight caps concurrent requests per upstream (0 = unlimited);
+	// requests over the cap wait up to QueueTimeout before a 503.
+	MaxInFlight  int
+	QueueTimeout time.Duration
+	// MaxResponseBytes caps an upstream response body. A larger declared
+	// Content-Length is answered with 502; a body without one is cut off
+	// once it passes the cap. Non-positive uses DefaultMaxResponseBytes.
+	MaxResponseBytes int64
+	// MaxRetries is how many times a GET or HEAD is retried after a
+	// connection error or a 502/503/504, waiting RetryBackoff times the
+	// attempt number in between. Other methods are never retried.
+	MaxRetries   int
+	RetryBackoff time.Duration
+	// BreakerThreshold consecutive failures open an upstream's circuit
+	// (0 = no breaker); requests then get 503 without being sent until
+	// BreakerOpenDuration has passed and a probe request succeeds.
+	BreakerThreshold    int
+	BreakerOpenDuration time.Duration
+	// ResponseTimeout bounds the wait for an upstream's response headers.
+	// Non-positive uses DefaultResponseTimeout.
+	ResponseTimeout time.Duration
+}
+
 // ServiceProxy forwards requests to downstream microservices.
 type ServiceProxy struct {
-	client *http.Client
+	client           *http.Client
+	maxInFlight      int
+	queueTimeout     time.Duration
+	maxResponseBytes int64
+	maxRetries       int
+	retryBackoff     time.Duration
+	breakerThreshold int
+	breakerOpenFor   time.Duration
+	internalToken    string
+
+	mu       sync.Mutex
+	limiters map[string]*upstreamLimiter
+	breakers map[string]*circuitBreaker
 }
 
 // NewServiceProxy creates a new service proxy with sensible defaults.
-func NewServiceProxy() *ServiceProxy {
+// A non-empty internalToken is sent as X-Internal-Token on every proxied
+// request, for services that only accept calls from inside the mesh.
+func NewServiceProxy(limits Limits, internalToken string) *ServiceProxy {
+	if limits.MaxResponseBytes <= 0 {
+		limits.MaxResponseBytes = DefaultMaxResponseBytes
+	}
+	if limits.ResponseTimeout <= 0 {
+		limits.ResponseTimeout = DefaultResponseTimeout
+	}
 	return &ServiceProxy{
+		// Only the wait for response headers is bounded: bodies are
+		// streamed and may legitimately stay open (e.g. event streams)
 		client: &http.Client{
-			Timeout: 120 * time.Second,
 			Transport: &http.Transport{
-				MaxIdleConns:        100,
-				MaxIdleConnsPerHost: 20,
-				IdleConnTimeout:     90 * time.Second,
+				MaxIdleConns:          100,
+				MaxIdleConnsPerHost:   20,
+				IdleConnTimeout:       90 * time.Second,
+				ResponseHeaderTimeout: limits.ResponseTimeout,
 			},
 		},
+		maxInFlight:      limits.MaxInFlight,
+		queueTimeout:     limits.QueueTimeout,
+		maxResponseBytes: limits.MaxResponseBytes,
+		maxRetries:       max(0, limits.MaxRetries),
+		retryBackoff:     limits.RetryBackoff,
+		breakerThreshold: limits.BreakerThreshold,
+		breakerOpenFor:   limits.BreakerOpenDuration,
+		internalToken:    internalToken,
+		limiters:         make(map[string]*upstreamLimiter),
+		breakers:         make(map[string]*circuitBreaker),
+	}
+}
+
+// limiterFor returns the shared limiter for an upstream base URL, so every
+// route forwarding to the same service counts against one cap.
+func (p *ServiceProxy) limiterFor(baseURL string) *upstreamLimiter {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	l, ok := p.limiters[baseURL]
+	if !ok {
+		l = newUpstreamLimiter(p.maxInFlight)
+		p.limiters[baseURL] = l
+	}
+	return l
+}
+
+// breakerFor returns the shared circuit breaker for an upstream base URL.
+func (p *ServiceProxy) breakerFor(baseURL string) *circuitBreaker {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	b, ok := p.breakers[baseURL]
+	if !ok {
+		b = newCircuitBreaker(p.breakerThreshold, p.breakerOpenFor)
+		p.breakers[baseURL] = b
+	}
+	return b
+}
+
+// Stats returns the current concurrency and circuit state of each upstream,
+// keyed by base URL.
+func (p *ServiceProxy) Stats() map[string]UpstreamStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats := make(map[string]UpstreamStats, len(p.limiters))
+	for baseURL, l := range p.limiters {
+		s := l.stats()
+		if b, ok := p.breakers[baseURL]; ok {
+			s.Circuit, s.CircuitTrips = b.state()
+		}
+		stats[baseURL] = s
 	}
+	return stats
 }
 
 // ForwardTo creates a handler that proxies requests to the given baseURL.
 // The pathPrefix is stripped before forwarding.
 func (p *ServiceProxy) ForwardTo(baseURL, pathPrefix string) fiber.Handler {
 	baseURL = strings.TrimRight(baseURL, "/")
+	limiter := p.limiterFor(baseURL)
+	breaker := p.breakerFor(baseURL)
 
 	return func(c fiber.Ctx) error {
+		// Backpressure: shed load rather than pile onto a saturated upstream
+		if !limiter.acquire(c.Context(), p.queueTimeout) {
+			slog.WarnContext(c.Context(), "upstream concurrency limit reached", "upstream", baseURL)
+			c.Set("Retry-After", "1")
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+				"error": fmt.Sprintf("service busy: %s", baseURL),
+			})
+		}
+		// The slot and the upstream request live until the response body has
+		// been streamed to the client, so both are released by upstreamBody
+		// once the handler hands the body off
+		ctx, cancel := context.WithCancel(c.Context())
+		handedOff := false
+		defer func() {
+			if !handedOff {
+				cancel()
+				limiter.release()
+			}
+		}()
+
 		// Build target URL: strip the gateway prefix, forward the rest
 		originalPath := c.Path()
 		targetPath := originalPath
@@ -51,58 +191,212 @@ func (p *ServiceProxy) ForwardTo(baseURL, pathPrefix string) fiber.Handler {
 			targetURL += "?" + q
 		}
 
-		slog.Debug("proxying request",
+		slog.DebugContext(ctx, "proxying request",
 			"method", c.Method(),
 			"from", originalPath,
 			"to", targetURL,
 		)
 
-		// Build the outgoing request
-		var bodyReader io.Reader
-		if len(c.Body()) > 0 {
-			bodyReader = strings.NewReader(string(c.Body()))
+		// Build the outgoing request headers once; the body is re-read per
+		// attempt
+		reqBody := string(c.Body())
+		header := http.Header{}
+		header.Set("Content-Type", c.Get("Content-Type", "application/json"))
+		for _, name := range forwardedRequestHeaders {
+			if v := c.Get(name); v != "" {
+				header.Set(name, v)
+			}
 		}
-
-		req, err := http.NewRequestWithContext(c.Context(), c.Method(), targetURL, bodyReader)
-		if err != nil {
-			return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
-				"error": "failed to create proxy request",
-			})
+		header.Set("X-Forwarded-For", c.IP())
+		header.Set("X-Forwarded-Host", c.Hostname())
+		if p.internalToken != "" {
+			header.Set("X-Internal-Token", p.internalToken)
 		}
 
-		// Forward relevant headers
-		req.Header.Set("Content-Type", c.Get("Content-Type", "application/json"))
-		if auth := c.Get("Authorization"); auth != "" {
-			req.Header.Set("Authorization", auth)
+		// Only idempotent requests are safe to send twice
+		attempts := 1
+		if c.Method() == fiber.MethodGet || c.Method() == fiber.MethodHead {
+			attempts += p.maxRetries
 		}
-		req.Header.Set("X-Forwarded-For", c.IP())
-		req.Header.Set("X-Forwarded-Host", c.Hostname())
 
-		// Execute the request
-		resp, err := p.client.Do(req)
-		if err != nil {
-			slog.Error("proxy request failed", "url", targetURL, "error", err)
+		var resp *http.Response
+		var err error
+		for attempt := 0; attempt < attempts; attempt++ {
+			if attempt > 0 {
+				slog.WarnContext(ctx, "retrying proxy request", "url", targetURL, "attempt", attempt+1)
+				if !sleepCtx(ctx, p.retryBackoff*time.Duration(attempt)) {
+					break
+				}
+			}
+			if !breaker.allow() {
+				return p.circuitOpen(c, baseURL, breaker)
+			}
+
+			resp, err = p.send(ctx, c.Method(), targetURL, reqBody, header)
+			if ctx.Err() != nil {
+				// The client went away; not the upstream's fault
+				break
+			}
+			if err == nil && !retryableStatus(resp.StatusCode) {
+				breaker.success()
+				break
+			}
+			breaker.failure()
+			if resp != nil && attempt < attempts-1 {
+				resp.Body.Close()
+				resp = nil
+			}
+		}
+		if resp == nil {
+			if err == nil {
+				err = ctx.Err()
+			}
+			slog.ErrorContext(ctx, "proxy request failed", "url", targetURL, "error", err)
 			return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
 				"error": fmt.Sprintf("service unavailable: %s", baseURL),
 			})
 		}
-		defer resp.Body.Close()
-
-		// Read response body
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
-				"error": "failed to read service response",
-			})
+		// A declared oversized body is refused outright; one without a
+		// Content-Length is cut off mid-stream once it passes the limit
+		if resp.ContentLength > p.maxResponseBytes {
+			resp.Body.Close()
+			return p.responseTooLarge(c, targetURL, resp.ContentLength)
 		}
 
-		// Copy response headers
+		// Copy response headers. Framing headers are left to fasthttp,
+		// which sets Content-Length from the stream size or falls back to
+		// chunked encoding when the size is unknown.
 		for key, vals := range resp.Header {
+			if hopHeaders[key] {
+				continue
+			}
 			for _, val := range vals {
 				c.Set(key, val)
 			}
 		}
+		c.Status(resp.StatusCode)
+
+		// Stream the body rather than buffering it. fasthttp closes the
+		// stream once it is written or the client disconnects, which
+		// cancels the upstream request and frees the slot.
+		handedOff = true
+		return c.SendStream(&upstreamBody{
+			Reader:  &capReader{r: resp.Body, remaining: p.maxResponseBytes, url: targetURL},
+			body:    resp.Body,
+			cancel:  cancel,
+			release: limiter.release,
+		}, int(resp.ContentLength))
+	}
+}
+
+// hopHeaders are response headers describing the upstream connection or
+// framing, which the gateway sets itself.
+var hopHeaders = map[string]bool{
+	"Connection":        true,
+	"Content-Length":    true,
+	"Keep-Alive":        true,
+	"Transfer-Encoding": true,
+}
+
+// upstreamBody is an upstream response body being streamed to the client.
+// Closing it closes the upstream body, cancels the upstream request and
+// releases the upstream's concurrency slot, exactly once.
+type upstreamBody struct {
+	io.Reader
+	body    io.Closer
+	cancel  context.CancelFunc
+	release func()
+	once    sync.Once
+}
+
+func (b *upstreamBody) Close() error {
+	var err error
+	b.once.Do(func() {
+		err = b.body.Close()
+		b.cancel()
+		b.release()
+	})
+	return err
+}
+
+// errResponseTooLarge aborts a streamed response that passes the size limit.
+var errResponseTooLarge = errors.New("service response too large")
 
-		return c.Status(resp.StatusCode).Send(body)
+// capReader fails once more than remaining bytes have been read, for
+// upstream responses that did not declare their size.
+type capReader struct {
+	r         io.Reader
+	remaining int64
+	url       string
+}
+
+func (r *capReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.remaining -= int64(n)
+	if r.remaining < 0 {
+		slog.Error("upstream response exceeds size limit, aborting stream", "url", r.url)
+		return 0, errResponseTooLarge
+	}
+	return n, err
+}
+
+// send performs one attempt of a proxied request.
+func (p *ServiceProxy) send(ctx context.Context, method, targetURL, body string, header http.Header) (*http.Response, error) {
+	var bodyReader io.Reader
+	if body != "" {
+		bodyReader = strings.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, targetURL, bodyReader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header = header.Clone()
+	return p.client.Do(req)
+}
+
+// retryableStatus reports whether an upstream status suggests the service
+// is restarting or overloaded rather than rejecting the request.
+func retryableStatus(code int) bool {
+	return code == http.StatusBadGateway ||
+		code == http.StatusServiceUnavailable ||
+		code == http.StatusGatewayTimeout
+}
+
+// sleepCtx waits for d, returning false if ctx is done first.
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
 	}
 }
+
+// circuitOpen answers 503 without contacting an upstream whose circuit is
+// open.
+func (p *ServiceProxy) circuitOpen(c fiber.Ctx, baseURL string, breaker *circuitBreaker) error {
+	slog.WarnContext(c.Context(), "upstream circuit open", "upstream", baseURL)
+	c.Set("Retry-After", fmt.Sprintf("%d", max(1, int(breaker.retryAfter().Seconds()+0.5))))
+	return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+		"error": fmt.Sprintf("service unavailable: %s", baseURL),
+	})
+}
+
+// responseTooLarge answers 502 for an upstream response over the size limit.
+// size is the declared Content-Length, or -1 when it was only detected while
+// reading.
+func (p *ServiceProxy) responseTooLarge(c fiber.Ctx, targetURL string, size int64) error {
+	slog.ErrorContext(c.Context(), "upstream response exceeds size limit",
+		"url", targetURL,
+		"limit_bytes", p.maxResponseBytes,
+		"content_length", size,
+	)
+	return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+		"error": "service response too large",
+	})
+}
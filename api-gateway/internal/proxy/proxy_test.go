@@ -0,0 +1,272 @@
+package proxy
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// flakyBackend answers with fail(n) for the nth request (1-based) while it
+// returns non-zero: a status code, or -1 to drop the connection. Once fail
+// returns 0 it answers 200 "ok".
+type flakyBackend struct {
+	*httptest.Server
+	hits atomic.Int32
+}
+
+func newFlakyBackend(t *testing.T, fail func(n int32) int) *flakyBackend {
+	t.Helper()
+	b := &flakyBackend{}
+	b.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch code := fail(b.hits.Add(1)); code {
+		case 0:
+			io.WriteString(w, "ok")
+		case -1:
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err == nil {
+				conn.Close()
+			}
+		default:
+			w.WriteHeader(code)
+		}
+	}))
+	t.Cleanup(b.Close)
+	return b
+}
+
+// newProxyApp mounts a proxy to backend under /api.
+func newProxyApp(backend *flakyBackend, limits Limits) (*fiber.App, *ServiceProxy) {
+	p := NewServiceProxy(limits, "")
+	app := fiber.New()
+	app.All("/api/*", p.ForwardTo(backend.URL, "/api"))
+	return app, p
+}
+
+func doRequest(t *testing.T, app *fiber.App, method string) *http.Response {
+	t.Helper()
+	var body io.Reader
+	if method == http.MethodPost {
+		body = strings.NewReader(`{}`)
+	}
+	resp, err := app.Test(httptest.NewRequest(method, "/api/movies", body), fiber.TestConfig{Timeout: 5 * time.Second})
+	if err != nil {
+		t.Fatalf("%s: %v", method, err)
+	}
+	return resp
+}
+
+func TestForwardRetriesGetOnRetryableStatus(t *testing.T) {
+	for _, code := range []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout} {
+		t.Run(http.StatusText(code), func(t *testing.T) {
+			backend := newFlakyBackend(t, func(n int32) int {
+				if n <= 2 {
+					return code
+				}
+				return 0
+			})
+			app, _ := newProxyApp(backend, Limits{MaxRetries: 2, RetryBackoff: time.Millisecond})
+
+			resp := doRequest(t, app, http.MethodGet)
+			if resp.StatusCode != http.StatusOK {
+				t.Errorf("status %d, want 200", resp.StatusCode)
+			}
+			if got := backend.hits.Load(); got != 3 {
+				t.Errorf("backend hit %d times, want 3", got)
+			}
+		})
+	}
+}
+
+func TestForwardRetriesGetOnConnectionError(t *testing.T) {
+	backend := newFlakyBackend(t, func(n int32) int {
+		if n == 1 {
+			return -1
+		}
+		return 0
+	})
+	app, _ := newProxyApp(backend, Limits{MaxRetries: 1, RetryBackoff: time.Millisecond})
+
+	resp := doRequest(t, app, http.MethodGet)
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status %d, want 200", resp.StatusCode)
+	}
+	if got := backend.hits.Load(); got != 2 {
+		t.Errorf("backend hit %d times, want 2", got)
+	}
+}
+
+func TestForwardRelaysLastFailureWhenRetriesRunOut(t *testing.T) {
+	backend := newFlakyBackend(t, func(int32) int { return http.StatusServiceUnavailable })
+	app, _ := newProxyApp(backend, Limits{MaxRetries: 2, RetryBackoff: time.Millisecond})
+
+	resp := doRequest(t, app, http.MethodGet)
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("status %d, want 503", resp.StatusCode)
+	}
+	if got := backend.hits.Load(); got != 3 {
+		t.Errorf("backend hit %d times, want 3", got)
+	}
+}
+
+func TestForwardDoesNotRetryPost(t *testing.T) {
+	for name, failure := range map[string]int{"status": http.StatusServiceUnavailable, "connection": -1} {
+		t.Run(name, func(t *testing.T) {
+			backend := newFlakyBackend(t, func(n int32) int {
+				if n == 1 {
+					return failure
+				}
+				return 0
+			})
+			app, _ := newProxyApp(backend, Limits{MaxRetries: 3, RetryBackoff: time.Millisecond})
+
+			resp := doRequest(t, app, http.MethodPost)
+			if resp.StatusCode == http.StatusOK {
+				t.Errorf("status 200, want the first failure relayed")
+			}
+			if got := backend.hits.Load(); got != 1 {
+				t.Errorf("backend hit %d times, want 1", got)
+			}
+		})
+	}
+}
+
+func TestBreakerOpensAfterThresholdAndFailsFast(t *testing.T) {
+	backend := newFlakyBackend(t, func(int32) int { return http.StatusServiceUnavailable })
+	app, p := newProxyApp(backend, Limits{BreakerThreshold: 3, BreakerOpenDuration: time.Hour})
+
+	for range 3 {
+		doRequest(t, app, http.MethodGet)
+	}
+	if got := backend.hits.Load(); got != 3 {
+		t.Fatalf("backend hit %d times before the circuit opened, want 3", got)
+	}
+
+	start := time.Now()
+	resp := doRequest(t, app, http.MethodGet)
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("status %d, want 503", resp.StatusCode)
+	}
+	if resp.Header.Get("Retry-After") == "" {
+		t.Error("no Retry-After on a fast-failed request")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("open circuit took %v to answer", elapsed)
+	}
+	if got := backend.hits.Load(); got != 3 {
+		t.Errorf("backend hit %d times with the circuit open, want 3", got)
+	}
+	if s := p.Stats()[backend.URL]; s.Circuit != CircuitOpen || s.CircuitTrips != 1 {
+		t.Errorf("stats circuit %q trips %d, want open after 1 trip", s.Circuit, s.CircuitTrips)
+	}
+}
+
+func TestBreakerHalfOpensAfterOpenDuration(t *testing.T) {
+	var healthy atomic.Bool
+	backend := newFlakyBackend(t, func(int32) int {
+		if healthy.Load() {
+			return 0
+		}
+		return http.StatusBadGateway
+	})
+	const openFor = 50 * time.Millisecond
+	app, p := newProxyApp(backend, Limits{BreakerThreshold: 2, BreakerOpenDuration: openFor})
+
+	for range 2 {
+		doRequest(t, app, http.MethodGet)
+	}
+	time.Sleep(openFor + 10*time.Millisecond)
+	if s := p.Stats()[backend.URL]; s.Circuit != CircuitHalfOpen {
+		t.Fatalf("circuit %q after the open period, want half_open", s.Circuit)
+	}
+
+	// A failed probe re-opens the circuit for another period
+	doRequest(t, app, http.MethodGet)
+	if got := backend.hits.Load(); got != 3 {
+		t.Fatalf("backend hit %d times, want the probe to reach it", got)
+	}
+	if resp := doRequest(t, app, http.MethodGet); resp.StatusCode != http.StatusServiceUnavailable || backend.hits.Load() != 3 {
+		t.Fatalf("request after a failed probe reached the backend")
+	}
+
+	// A successful probe closes it
+	healthy.Store(true)
+	time.Sleep(openFor + 10*time.Millisecond)
+	if resp := doRequest(t, app, http.MethodGet); resp.StatusCode != http.StatusOK {
+		t.Fatalf("probe status %d, want 200", resp.StatusCode)
+	}
+	if s := p.Stats()[backend.URL]; s.Circuit != CircuitClosed {
+		t.Errorf("circuit %q after a successful probe, want closed", s.Circuit)
+	}
+	if resp := doRequest(t, app, http.MethodGet); resp.StatusCode != http.StatusOK {
+		t.Errorf("status %d after the circuit closed, want 200", resp.StatusCode)
+	}
+}
+
+func TestForwardPassesRangeRequestsThrough(t *testing.T) {
+	content := []byte("0123456789abcdefghij")
+	modified := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	var gotRange, gotIfRange atomic.Value
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotRange.Store(r.Header.Get("Range"))
+		gotIfRange.Store(r.Header.Get("If-Range"))
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeContent(w, r, "poster.bin", modified, bytes.NewReader(content))
+	}))
+	defer stub.Close()
+
+	p := NewServiceProxy(Limits{}, "")
+	app := fiber.New()
+	app.Get("/api/*", p.ForwardTo(stub.URL, "/api"))
+
+	cases := []struct {
+		name         string
+		ifRange      string
+		status       int
+		contentRange string
+		body         string
+	}{
+		{"range", "", http.StatusPartialContent, "bytes 5-9/20", "56789"},
+		{"matching if-range", `"v1"`, http.StatusPartialContent, "bytes 5-9/20", "56789"},
+		{"stale if-range", `"v0"`, http.StatusOK, "", string(content)},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/poster", nil)
+			req.Header.Set("Range", "bytes=5-9")
+			if tc.ifRange != "" {
+				req.Header.Set("If-Range", tc.ifRange)
+			}
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+
+			if got := gotRange.Load(); got != "bytes=5-9" {
+				t.Errorf("stub saw Range %q, want bytes=5-9", got)
+			}
+			if got := gotIfRange.Load(); got != tc.ifRange {
+				t.Errorf("stub saw If-Range %q, want %q", got, tc.ifRange)
+			}
+			if resp.StatusCode != tc.status {
+				t.Errorf("status %d, want %d", resp.StatusCode, tc.status)
+			}
+			if got := resp.Header.Get("Content-Range"); got != tc.contentRange {
+				t.Errorf("Content-Range %q, want %q", got, tc.contentRange)
+			}
+			if got := resp.Header.Get("Accept-Ranges"); got != "bytes" {
+				t.Errorf("Accept-Ranges %q, want bytes", got)
+			}
+			if string(body) != tc.body {
+				t.Errorf("body %q, want %q", body, tc.body)
+			}
+		})
+	}
+}
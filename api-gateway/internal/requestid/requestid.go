@@ -0,0 +1,97 @@
+// Package requestid carries the X-Request-ID correlation header through a
+// request: it is read from the incoming request (or generated when absent),
+// echoed on the response, attached to slog records logged with the request
+// context, and forwarded on outbound calls.
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// Header is the correlation header shared by every service.
+const Header = "X-Request-ID"
+
+// maxLength bounds an incoming ID; longer ones are replaced.
+const maxLength = 128
+
+// LogFormat is the access log format with the request ID added.
+const LogFormat = "[${time}] ${ip} ${status} - ${latency} ${method} ${path} ${respHeader:" + Header + "} ${error}\n"
+
+type contextKey struct{}
+
+// Middleware reads the request ID, generating one when it is missing or
+// malformed, and stores it in the request context.
+func Middleware() fiber.Handler {
+	return func(c fiber.Ctx) error {
+		id := c.Get(Header)
+		if !valid(id) {
+			id = generate()
+			c.Request().Header.Set(Header, id)
+		}
+		c.Set(Header, id)
+		c.SetContext(context.WithValue(c.Context(), contextKey{}, id))
+		return c.Next()
+	}
+}
+
+// FromContext returns the request ID stored by Middleware, or "".
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// Propagate copies the request ID from req's context onto req.
+func Propagate(req *http.Request) {
+	if id := FromContext(req.Context()); id != "" {
+		req.Header.Set(Header, id)
+	}
+}
+
+func valid(id string) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func generate() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// NewLogHandler wraps h so records logged with a request context
+// (slog.InfoContext etc.) carry a request_id attribute.
+func NewLogHandler(h slog.Handler) slog.Handler {
+	return logHandler{h}
+}
+
+type logHandler struct {
+	slog.Handler
+}
+
+func (h logHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := FromContext(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h logHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return logHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h logHandler) WithGroup(name string) slog.Handler {
+	return logHandler{h.Handler.WithGroup(name)}
+}
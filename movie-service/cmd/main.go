@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"log/slog"
 	"os"
 	"os/signal"
@@ -12,17 +13,21 @@ import (
 	"github.com/gofiber/fiber/v3/middleware/logger"
 	"github.com/gofiber/fiber/v3/middleware/recover"
 
+	"movie-discovery-movie-service/internal/cache"
 	"movie-discovery-movie-service/internal/config"
 	"movie-discovery-movie-service/internal/database"
+	"movie-discovery-movie-service/internal/events"
 	"movie-discovery-movie-service/internal/handler"
+	"movie-discovery-movie-service/internal/metrics"
 	"movie-discovery-movie-service/internal/repository"
+	"movie-discovery-movie-service/internal/requestid"
 	"movie-discovery-movie-service/internal/service"
 	"movie-discovery-movie-service/internal/tmdb"
 )
 
 func main() {
 	// Structured logging
-	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo})))
+	slog.SetDefault(slog.New(requestid.NewLogHandler(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))))
 
 	// Load configuration
 	cfg, err := config.Load()
@@ -45,12 +50,26 @@ func main() {
 	}
 
 	// Initialize TMDB client
-	tmdbClient := tmdb.NewClient(cfg.TMDB.APIKey, cfg.TMDB.BaseURL)
+	discoverDefaults := tmdb.DiscoverOptions{
+		Language: cfg.TMDB.Language,
+		Region:   cfg.TMDB.Region,
+		SortBy:   cfg.TMDB.SortBy,
+	}
+	if err := discoverDefaults.Validate(); err != nil {
+		slog.Error("invalid TMDB discover config", "error", err)
+		os.Exit(1)
+	}
+	tmdbClient := tmdb.NewClient(cfg.TMDB.APIKey, cfg.TMDB.BaseURL,
+		tmdb.WithTimeout(cfg.TMDB.Timeout),
+		tmdb.WithRetry(cfg.TMDB.MaxRetries, cfg.TMDB.RetryBaseDelay),
+		tmdb.WithDiscoverDefaults(discoverDefaults),
+	)
 
 	// Initialize layers
 	repo := repository.NewMovieRepository(db)
-	svc := service.NewMovieService(repo, tmdbClient, rdb)
-	h := handler.NewMovieHandler(svc)
+	dispatcher := events.NewDispatcher(cfg.Webhooks.URLs, cfg.Webhooks.Secret, cfg.Webhooks.Timeout, cfg.Webhooks.MaxRetries)
+	svc := service.NewMovieService(repo, tmdbClient, cache.New(rdb), dispatcher, cfg.Cache)
+	h := handler.NewMovieHandler(svc, cfg.MaxFilterValues, cfg.AdminAPIToken)
 
 	// Create Fiber app
 	app := fiber.New(fiber.Config{
@@ -68,7 +87,9 @@ func main() {
 
 	// Middleware
 	app.Use(recover.New())
-	app.Use(logger.New())
+	app.Use(requestid.Middleware())
+	app.Use(metrics.Middleware())
+	app.Use(logger.New(logger.Config{Format: requestid.LogFormat}))
 	app.Use(cors.New())
 
 	// Swagger docs
@@ -79,12 +100,46 @@ func main() {
 		handler.RegisterSwagger(app, swaggerYAML)
 	}
 
+	// Prometheus metrics
+	app.Get(metrics.Path, metrics.Handler())
+
 	// API routes
 	api := app.Group("/api/v1")
 	api.Get("/health", h.Health)
+	readiness := handler.NewReadinessHandler(db, rdb, false)
+	api.Get("/health/ready", readiness.Ready)
+
+	// Kubernetes-style probes: liveness never checks dependencies,
+	// readiness pings them
+	app.Get("/healthz", h.Health)
+	app.Get("/readyz", readiness.Ready)
+
+	// With REQUIRE_INTERNAL_TOKEN every route registered below also needs
+	// the internal token; health, metrics and docs above stay open.
+	if cfg.RequireInternalToken {
+		app.Use(handler.RequireInternalToken(cfg.InternalAPIToken))
+	}
 	api.Get("/movies", h.ListMovies)
+	api.Get("/movies/search", h.SearchMovies)
+	api.Get("/movies/genres", h.GetMovieGenres)
+	api.Post("/movies/batch", h.BatchMovies)
+	api.Get("/movies/on-this-day", h.ListMoviesOnThisDay)
 	api.Get("/movies/:id", h.GetMovieDetail)
+	api.Head("/movies/:id", h.HeadMovie)
+	api.Get("/movies/:id/similar", h.GetSimilarMovies)
+	api.Get("/genres", h.ListGenres)
+	api.Get("/genres/:id/movies", h.ListMoviesByGenre)
 	api.Post("/admin/sync", h.SyncMovies)
+	api.Get("/admin/sync/status/:jobId", h.GetSyncStatus)
+
+	// Per-movie admin routes (require X-Admin-Token)
+	adminMovies := api.Group("/admin/movies", handler.RequireAdminToken(cfg.AdminAPIToken))
+	adminMovies.Post("/relink-genres", h.RelinkGenres)
+	adminMovies.Post("/:id/refresh-cache", h.RefreshMovieCache)
+
+	// Internal service-to-service routes (not exposed via the gateway)
+	internal := app.Group("/internal", handler.RequireInternalToken(cfg.InternalAPIToken))
+	internal.Get("/stats", h.Stats)
 
 	// Graceful shutdown
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
@@ -102,12 +157,23 @@ func main() {
 	<-ctx.Done()
 	slog.Info("shutting down movie service...")
 
-	// Shutdown HTTP server first (stop accepting new requests)
-	if err := app.Shutdown(); err != nil {
+	// Stop accepting new requests and drain in-flight ones, bounded by
+	// the shutdown timeout
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := app.ShutdownWithContext(shutdownCtx); errors.Is(err, context.DeadlineExceeded) {
+		slog.Warn("shutdown deadline reached with requests still in flight",
+			"timeout", cfg.ShutdownTimeout, "open_connections", app.Server().GetOpenConnectionsCount())
+	} else if err != nil {
 		slog.Error("error shutting down HTTP server", "error", err)
 	}
 	slog.Info("HTTP server stopped")
 
+	// Let sync jobs and runtime syncs finish within what is left of it
+	if running := svc.WaitBackground(shutdownCtx); len(running) > 0 {
+		slog.Warn("shutdown deadline reached with background work still running", "tasks", running)
+	}
+
 	// Close database connections
 	if err := db.Close(); err != nil {
 		slog.Error("error closing PostgreSQL connection", "error", err)
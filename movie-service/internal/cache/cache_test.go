@@ -0,0 +1,124 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// newTestRedis returns a client for TEST_REDIS_ADDR when set, otherwise for
+// an in-process miniredis. Tests only touch keys under their own prefixes.
+func newTestRedis(tb testing.TB) *redis.Client {
+	tb.Helper()
+	addr := os.Getenv("TEST_REDIS_ADDR")
+	if addr == "" {
+		addr = miniredis.RunT(tb).Addr()
+	}
+	rdb := redis.NewClient(&redis.Options{Addr: addr})
+	tb.Cleanup(func() { rdb.Close() })
+	if err := rdb.Ping(context.Background()).Err(); err != nil {
+		tb.Skipf("redis unavailable at %s: %v", addr, err)
+	}
+	return rdb
+}
+
+// seed stores n keys named prefix0..prefixN-1.
+func seed(tb testing.TB, rdb *redis.Client, prefix string, n int) {
+	tb.Helper()
+	ctx := context.Background()
+	pipe := rdb.Pipeline()
+	for i := range n {
+		pipe.Set(ctx, fmt.Sprintf("%s%d", prefix, i), "v", 0)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		tb.Fatalf("seed %s: %v", prefix, err)
+	}
+}
+
+func TestInvalidateRemovesEveryMatchingKey(t *testing.T) {
+	rdb := newTestRedis(t)
+	c := New(rdb)
+	ctx := context.Background()
+	// miniredis pages SCAN by position, so keys unlinked during the walk
+	// shift later ones past the cursor. Only Redis guarantees one pass
+	// finds every key; against miniredis the test checks the count.
+	realRedis := os.Getenv("TEST_REDIS_ADDR") != ""
+
+	// More keys than one SCAN page, so several UNLINK batches run
+	const n = 3 * invalidateBatchSize
+	seed(t, rdb, "movies:list:", n)
+	seed(t, rdb, "movie:detail:", n)
+	seed(t, rdb, "users:keep:", 10)
+
+	for _, pattern := range []string{"movies:*", "movie:*"} {
+		deleted, err := c.Invalidate(ctx, pattern)
+		if err != nil {
+			t.Fatalf("Invalidate(%q): %v", pattern, err)
+		}
+		left, err := rdb.Keys(ctx, pattern).Result()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if deleted+len(left) != n {
+			t.Errorf("Invalidate(%q) deleted %d keys and left %d, want %d in all", pattern, deleted, len(left), n)
+		}
+		if deleted <= invalidateBatchSize {
+			t.Errorf("Invalidate(%q) deleted %d keys, want more than one page", pattern, deleted)
+		}
+		if realRedis && len(left) != 0 {
+			t.Errorf("%d keys matching %q survived, e.g. %q", len(left), pattern, left[0])
+		}
+	}
+	if kept, _ := rdb.Keys(ctx, "users:keep:*").Result(); len(kept) != 10 {
+		t.Errorf("%d unrelated keys left, want 10", len(kept))
+	}
+}
+
+// invalidateOneByOne is the previous Invalidate: a SCAN iterator with one
+// DEL round trip per key. It is kept here as the benchmark baseline.
+func invalidateOneByOne(ctx context.Context, rdb *redis.Client, pattern string) (int, error) {
+	deleted := 0
+	iter := rdb.Scan(ctx, 0, pattern, 0).Iterator()
+	for iter.Next(ctx) {
+		if err := rdb.Del(ctx, iter.Val()).Err(); err == nil {
+			deleted++
+		}
+	}
+	return deleted, iter.Err()
+}
+
+// BenchmarkInvalidate compares batched UNLINK with one DEL per key on 2000
+// keys. Set TEST_REDIS_ADDR to measure against a real Redis.
+func BenchmarkInvalidate(b *testing.B) {
+	const keys = 2000
+	rdb := newTestRedis(b)
+	c := New(rdb)
+	ctx := context.Background()
+
+	cases := []struct {
+		name       string
+		invalidate func(pattern string) (int, error)
+	}{
+		{"batched-unlink", func(pattern string) (int, error) { return c.Invalidate(ctx, pattern) }},
+		{"one-at-a-time-del", func(pattern string) (int, error) { return invalidateOneByOne(ctx, rdb, pattern) }},
+	}
+	for _, tc := range cases {
+		b.Run(tc.name, func(b *testing.B) {
+			prefix := "bench:" + tc.name + ":"
+			for b.Loop() {
+				b.StopTimer()
+				seed(b, rdb, prefix, keys)
+				b.StartTimer()
+				// miniredis can leave keys behind (see above); seeding
+				// overwrites them, so every round starts from the same keys
+				if _, err := tc.invalidate(prefix + "*"); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -4,16 +4,34 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 // Config holds all configuration for the movie service.
 type Config struct {
-	DB    DBConfig
-	Redis RedisConfig
-	TMDB  TMDBConfig
-	Port  string
+	DB       DBConfig
+	Redis    RedisConfig
+	TMDB     TMDBConfig
+	Webhooks WebhookConfig
+	Cache    CacheConfig
+	Port     string
+	// InternalAPIToken is the shared secret required on /internal routes
+	// and sent with webhook calls.
+	InternalAPIToken string
+	// RequireInternalToken extends the internal token check to every route
+	// except health, metrics and docs, so only the gateway and the other
+	// services can call this one.
+	RequireInternalToken bool
+	// AdminAPIToken is required (X-Admin-Token) on per-movie admin routes.
+	AdminAPIToken string
+	// MaxFilterValues caps the values accepted by list-valued query filters.
+	MaxFilterValues int
+	// ShutdownTimeout bounds graceful shutdown, including draining
+	// in-flight requests.
+	ShutdownTimeout time.Duration
 }
 
 // DBConfig holds PostgreSQL configuration.
@@ -25,6 +43,11 @@ type DBConfig struct {
 	DBName      string
 	SSLMode     string
 	SSLRootCert string
+	// MaxOpenConns and MaxIdleConns size the connection pool;
+	// ConnMaxLifetime recycles connections after that long (0 = never).
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
 }
 
 // DSN returns the PostgreSQL connection string.
@@ -48,8 +71,32 @@ type RedisConfig struct {
 
 // TMDBConfig holds TMDB API configuration.
 type TMDBConfig struct {
-	APIKey  string
-	BaseURL string
+	APIKey         string
+	BaseURL        string
+	Timeout        time.Duration
+	MaxRetries     int
+	RetryBaseDelay time.Duration
+	// Language, Region and SortBy are the discover defaults for syncs;
+	// empty Language and Region leave them to TMDB.
+	Language string
+	Region   string
+	SortBy   string
+}
+
+// WebhookConfig holds catalog event webhook configuration.
+type WebhookConfig struct {
+	URLs       []string
+	Secret     string
+	Timeout    time.Duration
+	MaxRetries int
+}
+
+// CacheConfig holds the movie detail cache tiers. Movies at or above
+// HotPopularity are cached for HotTTL, all others for ColdTTL.
+type CacheConfig struct {
+	HotPopularity float64
+	HotTTL        time.Duration
+	ColdTTL       time.Duration
 }
 
 // Load reads configuration from environment variables.
@@ -58,17 +105,43 @@ func Load() (*Config, error) {
 	_ = godotenv.Load()
 
 	dbPort, _ := strconv.Atoi(getEnv("DB_PORT", "5432"))
+	dbMaxOpen, _ := strconv.Atoi(getEnv("DB_MAX_OPEN_CONNS", "25"))
+	dbMaxIdle, _ := strconv.Atoi(getEnv("DB_MAX_IDLE_CONNS", "10"))
+	dbConnLifetime, err := time.ParseDuration(getEnv("DB_CONN_MAX_LIFETIME", "0"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid DB_CONN_MAX_LIFETIME: %w", err)
+	}
 	redisDB, _ := strconv.Atoi(getEnv("REDIS_DB", "0"))
+	tmdbTimeout, _ := strconv.Atoi(getEnv("TMDB_TIMEOUT_SECONDS", "15"))
+	tmdbRetries, _ := strconv.Atoi(getEnv("TMDB_MAX_RETRIES", "3"))
+	tmdbRetryDelay, _ := strconv.Atoi(getEnv("TMDB_RETRY_BASE_DELAY_MS", "500"))
+	internalAPIToken := getEnv("INTERNAL_API_TOKEN", "")
+	requireInternalToken, _ := strconv.ParseBool(getEnv("REQUIRE_INTERNAL_TOKEN", "false"))
+	maxFilterValues, _ := strconv.Atoi(getEnv("MAX_FILTER_VALUES", "50"))
+	webhookTimeout, _ := strconv.Atoi(getEnv("WEBHOOK_TIMEOUT_SECONDS", "5"))
+	webhookRetries, _ := strconv.Atoi(getEnv("WEBHOOK_MAX_RETRIES", "3"))
+	hotPopularity, _ := strconv.ParseFloat(getEnv("CACHE_HOT_POPULARITY", "100"), 64)
+	hotTTL, _ := strconv.Atoi(getEnv("CACHE_HOT_TTL_MINUTES", "60"))
+	coldTTL, _ := strconv.Atoi(getEnv("CACHE_COLD_TTL_MINUTES", "10"))
+
+	if requireInternalToken && internalAPIToken == "" {
+		return nil, fmt.Errorf("REQUIRE_INTERNAL_TOKEN is set but INTERNAL_API_TOKEN is empty")
+	}
+
+	shutdownTimeout, _ := strconv.Atoi(getEnv("SHUTDOWN_TIMEOUT_SECONDS", "15"))
 
 	cfg := &Config{
 		DB: DBConfig{
-			Host:        getEnv("DB_HOST", "localhost"),
-			Port:        dbPort,
-			User:        getEnv("DB_USER", "postgres"),
-			Password:    getEnv("DB_PASSWORD", "postgres"),
-			DBName:      getEnv("DB_NAME", "movie_service"),
-			SSLMode:     getEnv("DB_SSLMODE", "verify-ca"),
-			SSLRootCert: getEnv("DB_SSLROOTCERT", ""),
+			Host:            getEnv("DB_HOST", "localhost"),
+			Port:            dbPort,
+			User:            getEnv("DB_USER", "postgres"),
+			Password:        getEnv("DB_PASSWORD", "postgres"),
+			DBName:          getEnv("DB_NAME", "movie_service"),
+			SSLMode:         getEnv("DB_SSLMODE", "verify-ca"),
+			SSLRootCert:     getEnv("DB_SSLROOTCERT", ""),
+			MaxOpenConns:    dbMaxOpen,
+			MaxIdleConns:    dbMaxIdle,
+			ConnMaxLifetime: dbConnLifetime,
 		},
 		Redis: RedisConfig{
 			Addr:     getEnv("REDIS_ADDR", "127.0.0.1:6379"),
@@ -76,10 +149,32 @@ func Load() (*Config, error) {
 			DB:       redisDB,
 		},
 		TMDB: TMDBConfig{
-			APIKey:  getEnv("TMDB_API_KEY", "XXXXXX"),
-			BaseURL: getEnv("TMDB_BASE_URL", "http://api.themoviedb.org/3"),
+			APIKey:         getEnv("TMDB_API_KEY", "XXXXXX"),
+			BaseURL:        getEnv("TMDB_BASE_URL", "http://api.themoviedb.org/3"),
+			Timeout:        time.Duration(tmdbTimeout) * time.Second,
+			MaxRetries:     tmdbRetries,
+			RetryBaseDelay: time.Duration(tmdbRetryDelay) * time.Millisecond,
+			Language:       getEnv("TMDB_LANGUAGE", ""),
+			Region:         getEnv("TMDB_REGION", ""),
+			SortBy:         getEnv("TMDB_SORT_BY", "popularity.desc"),
+		},
+		Webhooks: WebhookConfig{
+			URLs:       splitList(getEnv("WEBHOOK_URLS", "")),
+			Secret:     internalAPIToken,
+			Timeout:    time.Duration(webhookTimeout) * time.Second,
+			MaxRetries: webhookRetries,
+		},
+		Cache: CacheConfig{
+			HotPopularity: hotPopularity,
+			HotTTL:        time.Duration(hotTTL) * time.Minute,
+			ColdTTL:       time.Duration(coldTTL) * time.Minute,
 		},
-		Port: getEnv("SERVER_PORT", "8081"),
+		Port:                 getEnv("SERVER_PORT", "8081"),
+		ShutdownTimeout:      time.Duration(shutdownTimeout) * time.Second,
+		InternalAPIToken:     internalAPIToken,
+		RequireInternalToken: requireInternalToken,
+		AdminAPIToken:        getEnv("ADMIN_API_TOKEN", ""),
+		MaxFilterValues:      maxFilterValues,
 	}
 
 	return cfg, nil
@@ -91,3 +186,14 @@ func getEnv(key, fallback string) string {
 	}
 	return fallback
 }
+
+// splitList parses a comma-separated list, dropping empty entries.
+func splitList(v string) []string {
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
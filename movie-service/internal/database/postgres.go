@@ -21,8 +21,9 @@ func NewPostgres(cfg config.DBConfig) (*sql.DB, error) {
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(10)
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
 
 	slog.Info("connected to PostgreSQL", "db", cfg.DBName)
 
@@ -64,6 +65,53 @@ func runMigrations(db *sql.DB) error {
 		`CREATE INDEX IF NOT EXISTS idx_movies_popularity ON movies(popularity)`,
 		`CREATE INDEX IF NOT EXISTS idx_movies_title ON movies(title)`,
 		`CREATE INDEX IF NOT EXISTS idx_movies_tmdb_id ON movies(tmdb_id)`,
+		// Case- and accent-insensitive title sorting. unaccent() is only
+		// STABLE, so wrap it in an IMMUTABLE function usable in an index.
+		`CREATE EXTENSION IF NOT EXISTS unaccent`,
+		`CREATE OR REPLACE FUNCTION f_unaccent(text) RETURNS text AS
+			$$ SELECT public.unaccent('public.unaccent', $1) $$
+			LANGUAGE sql IMMUTABLE PARALLEL SAFE STRICT`,
+		`CREATE INDEX IF NOT EXISTS idx_movies_title_sort ON movies(LOWER(f_unaccent(title)))`,
+		// TMDB rating
+		`ALTER TABLE movies ADD COLUMN IF NOT EXISTS vote_average DOUBLE PRECISION DEFAULT 0`,
+		`ALTER TABLE movies ALTER COLUMN vote_average SET DEFAULT 0`,
+		`ALTER TABLE movies ADD COLUMN IF NOT EXISTS vote_count INTEGER DEFAULT 0`,
+		// Title search: full-text over title+overview, plus trigrams so
+		// partial titles ("matr") still match via ILIKE
+		`ALTER TABLE movies ADD COLUMN IF NOT EXISTS search_vector tsvector
+			GENERATED ALWAYS AS (to_tsvector('simple', COALESCE(title, '') || ' ' || COALESCE(overview, ''))) STORED`,
+		`CREATE INDEX IF NOT EXISTS idx_movies_search_vector ON movies USING GIN (search_vector)`,
+		`CREATE EXTENSION IF NOT EXISTS pg_trgm`,
+		`CREATE INDEX IF NOT EXISTS idx_movies_title_trgm ON movies USING GIN (title gin_trgm_ops)`,
+		// Sync bookkeeping, e.g. when the catalog was last synced
+		`CREATE TABLE IF NOT EXISTS sync_state (
+			name VARCHAR(50) PRIMARY KEY,
+			last_synced_at TIMESTAMP NOT NULL
+		)`,
+		// "On this day" lookups by release month and day across years
+		`CREATE INDEX IF NOT EXISTS idx_movies_release_month_day
+			ON movies ((EXTRACT(MONTH FROM release_date)), (EXTRACT(DAY FROM release_date)))`,
+		// Top-billed cast
+		`CREATE TABLE IF NOT EXISTS people (
+			id SERIAL PRIMARY KEY,
+			tmdb_id INTEGER UNIQUE NOT NULL,
+			name VARCHAR(300) NOT NULL,
+			profile_path VARCHAR(500) DEFAULT ''
+		)`,
+		`CREATE TABLE IF NOT EXISTS movie_cast (
+			movie_id INTEGER REFERENCES movies(id) ON DELETE CASCADE,
+			person_id INTEGER REFERENCES people(id) ON DELETE CASCADE,
+			character VARCHAR(500) DEFAULT '',
+			cast_order INTEGER NOT NULL,
+			PRIMARY KEY (movie_id, cast_order)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_movie_cast_person_id ON movie_cast(person_id)`,
+		// When the cast was last stored, so movies TMDB lists no cast for
+		// are not fetched again on every sync
+		`ALTER TABLE movies ADD COLUMN IF NOT EXISTS cast_synced_at TIMESTAMP`,
+		`UPDATE movies m SET cast_synced_at = NOW()
+			WHERE cast_synced_at IS NULL
+				AND EXISTS (SELECT 1 FROM movie_cast mc WHERE mc.movie_id = m.id)`,
 	}
 
 	for _, m := range migrations {
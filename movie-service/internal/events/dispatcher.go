@@ -0,0 +1,104 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// TypeCatalogSynced is emitted after a TMDB sync finishes.
+const TypeCatalogSynced = "catalog.synced"
+
+// CatalogSynced is the payload posted to webhooks after a sync.
+type CatalogSynced struct {
+	Type         string    `json:"type"`
+	MoviesSynced int       `json:"movies_synced"`
+	At           time.Time `json:"at"`
+}
+
+// NewCatalogSynced builds a catalog.synced event stamped with the current time.
+func NewCatalogSynced(moviesSynced int) CatalogSynced {
+	return CatalogSynced{
+		Type:         TypeCatalogSynced,
+		MoviesSynced: moviesSynced,
+		At:           time.Now().UTC(),
+	}
+}
+
+// Dispatcher posts JSON events to a fixed set of webhook URLs.
+type Dispatcher struct {
+	urls       []string
+	secret     string
+	maxRetries int
+	http       *http.Client
+}
+
+// NewDispatcher creates a Dispatcher. The secret, if set, is sent in the
+// X-Internal-Token header so internal consumers can authenticate the call.
+func NewDispatcher(urls []string, secret string, timeout time.Duration, maxRetries int) *Dispatcher {
+	return &Dispatcher{
+		urls:       urls,
+		secret:     secret,
+		maxRetries: maxRetries,
+		http:       &http.Client{Timeout: timeout},
+	}
+}
+
+// Dispatch delivers the event to every webhook in the background. Delivery
+// is best-effort: each URL is retried with a linear backoff, then logged.
+func (d *Dispatcher) Dispatch(event any) {
+	if d == nil || len(d.urls) == 0 {
+		return
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		slog.Error("failed to encode webhook event", "error", err)
+		return
+	}
+
+	for _, url := range d.urls {
+		go d.deliver(url, body)
+	}
+}
+
+func (d *Dispatcher) deliver(url string, body []byte) {
+	var err error
+	for attempt := 0; attempt <= d.maxRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * time.Second)
+		}
+		if err = d.post(url, body); err == nil {
+			slog.Info("webhook delivered", "url", url, "attempt", attempt+1)
+			return
+		}
+		slog.Warn("webhook delivery failed", "url", url, "attempt", attempt+1, "error", err)
+	}
+	slog.Error("webhook delivery abandoned", "url", url, "error", err)
+}
+
+func (d *Dispatcher) post(url string, body []byte) error {
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if d.secret != "" {
+		req.Header.Set("X-Internal-Token", d.secret)
+	}
+
+	resp, err := d.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("HTTP request failed: %w", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
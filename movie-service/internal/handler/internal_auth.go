@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"crypto/subtle"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// InternalTokenHeader carries the shared secret on service-to-service calls.
+const InternalTokenHeader = "X-Internal-Token"
+
+// AdminTokenHeader carries the admin secret on operator-only routes.
+const AdminTokenHeader = "X-Admin-Token"
+
+// RequireInternalToken rejects requests whose internal token header does not
+// match token. An empty token disables the check (local development).
+func RequireInternalToken(token string) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		if token == "" {
+			return c.Next()
+		}
+		if subtle.ConstantTimeCompare([]byte(c.Get(InternalTokenHeader)), []byte(token)) != 1 {
+			return c.Status(fiber.StatusUnauthorized).JSON(ErrorResponse{Error: "invalid internal token"})
+		}
+		return c.Next()
+	}
+}
+
+// RequireAdminToken rejects requests whose admin token header does not match
+// token. An empty token disables the guarded routes entirely.
+func RequireAdminToken(token string) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		if !hasAdminToken(c, token) {
+			return c.Status(fiber.StatusForbidden).JSON(ErrorResponse{Error: "admin token required"})
+		}
+		return c.Next()
+	}
+}
+
+// hasAdminToken reports whether the request carries the admin token. An
+// empty token means admin-only options are disabled.
+func hasAdminToken(c fiber.Ctx, token string) bool {
+	if token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(c.Get(AdminTokenHeader)), []byte(token)) == 1
+}
@@ -1,23 +1,32 @@
 package handler
 
 import (
+	"errors"
+	"fmt"
 	"log/slog"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v3"
 
 	"movie-discovery-movie-service/internal/models"
 	"movie-discovery-movie-service/internal/service"
+	"movie-discovery-movie-service/internal/tmdb"
 )
 
 // MovieHandler handles HTTP requests for movies.
 type MovieHandler struct {
 	svc *service.MovieService
+	// maxFilterValues caps list-valued query filters.
+	maxFilterValues int
+	// adminToken unlocks refresh=true; empty disables it.
+	adminToken string
 }
 
 // NewMovieHandler creates a new MovieHandler.
-func NewMovieHandler(svc *service.MovieService) *MovieHandler {
-	return &MovieHandler{svc: svc}
+func NewMovieHandler(svc *service.MovieService, maxFilterValues int, adminToken string) *MovieHandler {
+	return &MovieHandler{svc: svc, maxFilterValues: maxFilterValues, adminToken: adminToken}
 }
 
 // ErrorResponse is the standard error response format.
@@ -38,6 +47,19 @@ func (h *MovieHandler) Health(c fiber.Ctx) error {
 	})
 }
 
+// Stats returns cache and catalog statistics.
+// GET /internal/stats
+func (h *MovieHandler) Stats(c fiber.Ctx) error {
+	stats, err := h.svc.GetStats(c.Context())
+	if err != nil {
+		slog.Error("failed to get stats", "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
+			Error: "failed to get stats",
+		})
+	}
+	return c.JSON(stats)
+}
+
 // ListMovies returns a paginated list of movies.
 // @Summary List movies
 // @Tags movies
@@ -48,20 +70,37 @@ func (h *MovieHandler) Health(c fiber.Ctx) error {
 // @Param order query string false "Sort order" Enums(asc,desc) default(desc)
 // @Param release_date_from query string false "Filter start date (YYYY-MM-DD)"
 // @Param release_date_to query string false "Filter end date (YYYY-MM-DD)"
+// @Param include_genres query bool false "Include genre names on each item" default(false)
+// @Param refresh query bool false "Skip the cached result and recache it; requires X-Admin-Token" default(false)
+// @Param genre query []string false "Genre names, repeated or comma-separated" collectionFormat(multi)
+// @Param genre_match query string false "Match any or all of the genres" Enums(any,all) default(any)
+// @Param strict query bool false "Reject invalid sort_by/order/genre_match with 400 instead of defaulting" default(false)
 // @Success 200 {object} models.MovieListResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 422 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /movies [get]
 func (h *MovieHandler) ListMovies(c fiber.Ctx) error {
-	params := models.MovieListParams{
-		Page:            fiber.Query(c, "page", 1),
-		PageSize:        fiber.Query(c, "page_size", 20),
-		SortBy:          c.Query("sort_by", "popularity"),
-		Order:           c.Query("order", "desc"),
-		ReleaseDateFrom: c.Query("release_date_from"),
-		ReleaseDateTo:   c.Query("release_date_to"),
+	params, err := listParamsFromQuery(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+	if params.Refresh, err = h.refreshRequested(c); err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	params.Genres, err = h.filterValues(c, "genre")
+	if err != nil {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(ErrorResponse{
+			Error: err.Error(),
+		})
 	}
 
-	result, err := h.svc.ListMovies(params)
+	result, err := h.svc.ListMovies(c.Context(), params)
 	if err != nil {
 		slog.Error("failed to list movies", "error", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
@@ -72,12 +111,399 @@ func (h *MovieHandler) ListMovies(c fiber.Ctx) error {
 	return c.JSON(result)
 }
 
+// maxSearchQueryLength caps the q parameter of SearchMovies.
+const maxSearchQueryLength = 100
+
+// SearchMovies returns a paginated list of movies matching a title search.
+// @Summary Search movies
+// @Tags movies
+// @Produce json
+// @Param q query string true "Search text, matched against title and overview"
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Items per page" default(20)
+// @Param sort_by query string false "Sort field" Enums(release_date,title,popularity) default(popularity)
+// @Param order query string false "Sort order" Enums(asc,desc) default(desc)
+// @Param release_date_from query string false "Filter start date (YYYY-MM-DD)"
+// @Param release_date_to query string false "Filter end date (YYYY-MM-DD)"
+// @Param include_genres query bool false "Include genre names on each item" default(false)
+// @Param refresh query bool false "Skip the cached result and recache it; requires X-Admin-Token" default(false)
+// @Param strict query bool false "Reject invalid sort_by/order with 400 instead of defaulting" default(false)
+// @Success 200 {object} models.MovieListResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /movies/search [get]
+func (h *MovieHandler) SearchMovies(c fiber.Ctx) error {
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error: "query parameter q is required",
+		})
+	}
+	if len(query) > maxSearchQueryLength {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error: "query parameter q is too long",
+		})
+	}
+
+	params, err := listParamsFromQuery(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+	if params.Refresh, err = h.refreshRequested(c); err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	result, err := h.svc.SearchMovies(c.Context(), query, params)
+	if err != nil {
+		slog.Error("failed to search movies", "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
+			Error: "failed to search movies",
+		})
+	}
+
+	return c.JSON(result)
+}
+
+// ListMoviesOnThisDay lists movies released on a calendar day in any year.
+// @Summary List movies released on this day in history
+// @Tags movies
+// @Produce json
+// @Param month query int false "Release month (1-12), defaults to the current month"
+// @Param day query int false "Release day of month, defaults to the current day"
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Items per page" default(20)
+// @Param include_genres query bool false "Include genre names on each item" default(false)
+// @Param refresh query bool false "Skip the cached result and recache it; requires X-Admin-Token" default(false)
+// @Success 200 {object} models.MovieListResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /movies/on-this-day [get]
+func (h *MovieHandler) ListMoviesOnThisDay(c fiber.Ctx) error {
+	now := time.Now()
+	month := fiber.Query(c, "month", int(now.Month()))
+	day := fiber.Query(c, "day", now.Day())
+	// Validate against a leap year so Feb 29 is accepted
+	if month < 1 || month > 12 || day < 1 ||
+		time.Date(2000, time.Month(month), day, 0, 0, 0, 0, time.UTC).Month() != time.Month(month) {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error: "invalid month/day",
+		})
+	}
+
+	params := models.MovieListParams{
+		Page:          fiber.Query(c, "page", 1),
+		PageSize:      fiber.Query(c, "page_size", 20),
+		IncludeGenres: fiber.Query(c, "include_genres", false),
+	}
+	var err error
+	if params.Refresh, err = h.refreshRequested(c); err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	result, err := h.svc.ListMoviesOnThisDay(c.Context(), month, day, params)
+	if err != nil {
+		slog.Error("failed to list movies on this day", "month", month, "day", day, "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
+			Error: "failed to retrieve movies",
+		})
+	}
+
+	return c.JSON(result)
+}
+
+// GetMovieGenres returns the genres of several movies in one call.
+// @Summary Get genres for a batch of movies
+// @Tags movies
+// @Produce json
+// @Param ids query string true "Comma-separated movie IDs"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} ErrorResponse
+// @Failure 422 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /movies/genres [get]
+func (h *MovieHandler) GetMovieGenres(c fiber.Ctx) error {
+	values, err := h.filterValues(c, "ids")
+	if err != nil {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+	if len(values) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error: "query parameter ids is required",
+		})
+	}
+
+	ids := make([]int, 0, len(values))
+	for _, v := range values {
+		id, err := strconv.Atoi(v)
+		if err != nil || id <= 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+				Error: "invalid movie ID: " + v,
+			})
+		}
+		ids = append(ids, id)
+	}
+
+	genres, err := h.svc.GetGenresByMovieIDs(c.Context(), ids)
+	if err != nil {
+		slog.Error("failed to get movie genres", "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
+			Error: "failed to retrieve genres",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"data": genres,
+	})
+}
+
+// Similar movie limits of GetSimilarMovies.
+const (
+	defaultSimilarLimit = 10
+	maxSimilarLimit     = 50
+)
+
+// GetSimilarMovies returns the movies sharing the most genres with one movie.
+// @Summary Get similar movies
+// @Tags movies
+// @Produce json
+// @Param id path int true "Movie ID"
+// @Param limit query int false "Maximum movies to return (1-50)" default(10)
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /movies/{id}/similar [get]
+func (h *MovieHandler) GetSimilarMovies(c fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil || id <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error: "invalid movie ID",
+		})
+	}
+
+	limit := fiber.Query(c, "limit", defaultSimilarLimit)
+	if limit < 1 {
+		limit = 1
+	}
+	if limit > maxSimilarLimit {
+		limit = maxSimilarLimit
+	}
+
+	movies, err := h.svc.GetSimilarMovies(c.Context(), id, limit)
+	if err != nil {
+		if err.Error() == "movie not found" {
+			return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{
+				Error: "movie not found",
+			})
+		}
+		slog.Error("failed to get similar movies", "id", id, "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
+			Error: "failed to retrieve similar movies",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"data": movies,
+	})
+}
+
+// BatchMovies returns the details of several movies in one call. Repeated
+// IDs are looked up once, and the distinct IDs are capped like list filter
+// values.
+// @Summary Get details for a batch of movies
+// @Tags movies
+// @Accept json
+// @Produce json
+// @Param ids body []int true "Movie IDs, at most MAX_FILTER_VALUES distinct"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /movies/batch [post]
+func (h *MovieHandler) BatchMovies(c fiber.Ctx) error {
+	var raw []int
+	if err := c.Bind().JSON(&raw); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error: "request body must be a JSON array of movie IDs",
+		})
+	}
+	if len(raw) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error: "at least one movie ID is required",
+		})
+	}
+	seen := make(map[int]bool, len(raw))
+	ids := make([]int, 0, len(raw))
+	for _, id := range raw {
+		if id <= 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+				Error: fmt.Sprintf("invalid movie ID: %d", id),
+			})
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if h.maxFilterValues > 0 && len(ids) > h.maxFilterValues {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error: fmt.Sprintf("at most %d distinct movie IDs are allowed", h.maxFilterValues),
+		})
+	}
+
+	movies, err := h.svc.GetMoviesByIDs(c.Context(), ids)
+	if err != nil {
+		slog.Error("failed to get movies by IDs", "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
+			Error: "failed to retrieve movies",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"data": movies,
+	})
+}
+
+// ListGenres returns every genre, sorted by name.
+// @Summary List genres
+// @Tags genres
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Failure 500 {object} ErrorResponse
+// @Router /genres [get]
+func (h *MovieHandler) ListGenres(c fiber.Ctx) error {
+	genres, err := h.svc.ListGenres(c.Context())
+	if err != nil {
+		slog.Error("failed to list genres", "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
+			Error: "failed to retrieve genres",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"data": genres,
+	})
+}
+
+// ListMoviesByGenre returns a paginated list of movies in one genre.
+// @Summary List movies in a genre
+// @Tags genres
+// @Produce json
+// @Param id path int true "Genre ID"
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Items per page" default(20)
+// @Param sort_by query string false "Sort field" Enums(release_date,title,popularity) default(popularity)
+// @Param order query string false "Sort order" Enums(asc,desc) default(desc)
+// @Param include_genres query bool false "Include genre names on each item" default(false)
+// @Param refresh query bool false "Skip the cached result and recache it; requires X-Admin-Token" default(false)
+// @Param strict query bool false "Reject invalid sort_by/order with 400 instead of defaulting" default(false)
+// @Success 200 {object} models.MovieListResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /genres/{id}/movies [get]
+func (h *MovieHandler) ListMoviesByGenre(c fiber.Ctx) error {
+	genreID, err := strconv.Atoi(c.Params("id"))
+	if err != nil || genreID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error: "invalid genre ID",
+		})
+	}
+
+	params, err := listParamsFromQuery(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+	if params.Refresh, err = h.refreshRequested(c); err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	result, err := h.svc.ListMoviesByGenre(c.Context(), genreID, params)
+	if err != nil {
+		if err.Error() == "genre not found" {
+			return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{
+				Error: "genre not found",
+			})
+		}
+		slog.Error("failed to list movies by genre", "genre_id", genreID, "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
+			Error: "failed to retrieve movies",
+		})
+	}
+
+	return c.JSON(result)
+}
+
+// refreshRequested reports whether the request asks to bypass the cache
+// with refresh=true. Only admins may, so anonymous clients cannot turn
+// cached reads into database queries; callers answer errRefreshForbidden
+// with 403.
+func (h *MovieHandler) refreshRequested(c fiber.Ctx) (bool, error) {
+	if !fiber.Query(c, "refresh", false) {
+		return false, nil
+	}
+	if !hasAdminToken(c, h.adminToken) {
+		return false, errRefreshForbidden
+	}
+	return true, nil
+}
+
+// errRefreshForbidden is returned by refreshRequested without the admin token.
+var errRefreshForbidden = errors.New("refresh requires an admin token")
+
+// listParamsFromQuery reads the shared listing query parameters. With
+// strict=true, invalid sort_by/order/genre_match values are returned as an
+// error.
+func listParamsFromQuery(c fiber.Ctx) (models.MovieListParams, error) {
+	params := models.MovieListParams{
+		Page:            fiber.Query(c, "page", 1),
+		PageSize:        fiber.Query(c, "page_size", 20),
+		SortBy:          c.Query("sort_by", "popularity"),
+		Order:           c.Query("order", "desc"),
+		ReleaseDateFrom: c.Query("release_date_from"),
+		ReleaseDateTo:   c.Query("release_date_to"),
+		IncludeGenres:   fiber.Query(c, "include_genres", false),
+		GenreMatch:      c.Query("genre_match"),
+	}
+	if fiber.Query(c, "strict", false) {
+		if err := params.ValidateStrict(); err != nil {
+			return params, err
+		}
+	}
+	return params, nil
+}
+
+// filterValues reads a list filter given as repeated and/or comma-separated
+// query values, normalized, deduplicated and capped at maxFilterValues.
+// Callers should answer models.ErrTooManyFilterValues with 422.
+func (h *MovieHandler) filterValues(c fiber.Ctx, key string) ([]string, error) {
+	var raw []string
+	for _, v := range c.Request().URI().QueryArgs().PeekMulti(key) {
+		raw = append(raw, strings.Split(string(v), ",")...)
+	}
+	return models.NormalizeFilterValues(key, raw, h.maxFilterValues)
+}
+
 // GetMovieDetail returns detailed info for a single movie.
 // @Summary Get movie detail
 // @Tags movies
 // @Produce json
 // @Param id path int true "Movie ID"
+// @Param expand query string false "Deprecated: cast is always included; expand=cast is still accepted"
+// @Param refresh query bool false "Skip the cached detail and cast and recache them; requires X-Admin-Token" default(false)
 // @Success 200 {object} models.MovieDetail
+// @Failure 400 {object} ErrorResponse
 // @Failure 404 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /movies/{id} [get]
@@ -90,7 +516,26 @@ func (h *MovieHandler) GetMovieDetail(c fiber.Ctx) error {
 		})
 	}
 
-	detail, err := h.svc.GetMovieDetail(id)
+	// Cast used to be opt-in with expand=cast; it is now always included,
+	// and the parameter is only validated so existing clients keep working
+	for _, v := range strings.Split(c.Query("expand"), ",") {
+		switch strings.TrimSpace(v) {
+		case "", "cast":
+		default:
+			return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+				Error: fmt.Sprintf("invalid expand %q, must be: cast", v),
+			})
+		}
+	}
+
+	refresh, err := h.refreshRequested(c)
+	if err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	detail, err := h.svc.GetMovieDetailWithCast(c.Context(), id, refresh)
 	if err != nil {
 		if err.Error() == "movie not found" {
 			return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{
@@ -106,12 +551,44 @@ func (h *MovieHandler) GetMovieDetail(c fiber.Ctx) error {
 	return c.JSON(detail)
 }
 
-// SyncMovies triggers a sync of movies from TMDB.
+// HeadMovie reports whether a movie exists without returning its detail.
+// @Summary Check movie existence
+// @Tags movies
+// @Param id path int true "Movie ID"
+// @Success 200
+// @Failure 400
+// @Failure 404
+// @Failure 500
+// @Router /movies/{id} [head]
+func (h *MovieHandler) HeadMovie(c fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	exists, err := h.svc.MovieExists(c.Context(), id)
+	if err != nil {
+		slog.Error("failed to check movie existence", "id", id, "error", err)
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+	if !exists {
+		return c.SendStatus(fiber.StatusNotFound)
+	}
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// SyncMovies starts a sync of movies from TMDB in the background.
 // @Summary Sync movies from TMDB
 // @Tags admin
 // @Produce json
 // @Param pages query int false "Number of pages to sync" default(5)
-// @Success 200 {object} map[string]interface{}
+// @Param mode query string false "full re-syncs every page, incremental only writes new or changed movies" Enums(full,incremental) default(full)
+// @Param language query string false "TMDB language for this sync, e.g. pt-BR (default TMDB_LANGUAGE)"
+// @Param region query string false "TMDB region for this sync, e.g. MY (default TMDB_REGION)"
+// @Param sort_by query string false "TMDB discover order for this sync, e.g. vote_count.desc (default TMDB_SORT_BY)"
+// @Success 202 {object} map[string]interface{}
+// @Failure 400 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /admin/sync [post]
 func (h *MovieHandler) SyncMovies(c fiber.Ctx) error {
@@ -123,17 +600,132 @@ func (h *MovieHandler) SyncMovies(c fiber.Ctx) error {
 		pages = 50
 	}
 
-	count, err := h.svc.SyncMovies(pages)
+	mode := c.Query("mode", models.SyncModeFull)
+	if mode != models.SyncModeFull && mode != models.SyncModeIncremental {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error: fmt.Sprintf("invalid mode %q, must be one of: full, incremental", mode),
+		})
+	}
+
+	opts := tmdb.DiscoverOptions{
+		Language: c.Query("language"),
+		Region:   c.Query("region"),
+		SortBy:   c.Query("sort_by"),
+	}
+	if err := opts.Validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	job, err := h.svc.StartSync(c.Context(), mode, pages, opts)
+	if err != nil {
+		if errors.Is(err, service.ErrSyncInProgress) {
+			return c.Status(fiber.StatusConflict).JSON(ErrorResponse{
+				Error: err.Error(),
+			})
+		}
+		slog.Error("failed to start sync", "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
+			Error: "failed to start sync",
+		})
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"message":    "sync started",
+		"job_id":     job.ID,
+		"mode":       job.Mode,
+		"pages":      job.Pages,
+		"status_url": "/api/v1/admin/sync/status/" + job.ID,
+	})
+}
+
+// GetSyncStatus reports the state of a background sync job.
+// @Summary Get sync job status
+// @Tags admin
+// @Produce json
+// @Param jobId path string true "Job ID returned by POST /admin/sync"
+// @Success 200 {object} models.SyncJob
+// @Failure 404 {object} ErrorResponse
+// @Router /admin/sync/status/{jobId} [get]
+func (h *MovieHandler) GetSyncStatus(c fiber.Ctx) error {
+	job, err := h.svc.GetSyncJob(c.Context(), c.Params("jobId"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{
+			Error: "sync job not found",
+		})
+	}
+	return c.JSON(job)
+}
+
+// RefreshMovieCache drops a movie's cached detail and cast and returns them
+// reloaded, shaped like the movie detail endpoint.
+// @Summary Refresh a movie's cached detail and cast
+// @Tags admin
+// @Produce json
+// @Param id path int true "Movie ID"
+// @Param from_tmdb query bool false "Re-sync the movie from TMDB first" default(false)
+// @Param X-Admin-Token header string true "Admin secret"
+// @Success 200 {object} models.MovieDetail
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /admin/movies/{id}/refresh-cache [post]
+func (h *MovieHandler) RefreshMovieCache(c fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error: "invalid movie ID",
+		})
+	}
+
+	detail, err := h.svc.RefreshMovieDetail(c.Context(), id, fiber.Query(c, "from_tmdb", false))
+	if err != nil {
+		if err.Error() == "movie not found" {
+			return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{
+				Error: "movie not found",
+			})
+		}
+		slog.Error("failed to refresh movie cache", "id", id, "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
+			Error: "failed to refresh movie: " + err.Error(),
+		})
+	}
+
+	return c.JSON(detail)
+}
+
+// RelinkGenres re-fetches genres from TMDB for movies that have none.
+// @Summary Re-link genres for movies missing them
+// @Tags admin
+// @Produce json
+// @Param limit query int false "Maximum movies to repair (1-500)" default(100)
+// @Param X-Admin-Token header string true "Admin secret"
+// @Success 200 {object} map[string]interface{}
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /admin/movies/relink-genres [post]
+func (h *MovieHandler) RelinkGenres(c fiber.Ctx) error {
+	limit := fiber.Query(c, "limit", 100)
+	if limit < 1 {
+		limit = 1
+	}
+	if limit > 500 {
+		limit = 500
+	}
+
+	checked, relinked, err := h.svc.RelinkGenres(c.Context(), limit)
 	if err != nil {
-		slog.Error("sync failed", "error", err)
+		slog.Error("genre relink failed", "error", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
-			Error: "sync failed: " + err.Error(),
+			Error: "genre relink failed: " + err.Error(),
 		})
 	}
 
 	return c.JSON(fiber.Map{
-		"message":       "sync completed",
-		"movies_synced": count,
-		"pages":         pages,
+		"message":         "genre relink completed",
+		"movies_checked":  checked,
+		"movies_relinked": relinked,
 	})
 }
@@ -0,0 +1,268 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gofiber/fiber/v3"
+	"github.com/redis/go-redis/v9"
+
+	"movie-discovery-movie-service/internal/cache"
+	"movie-discovery-movie-service/internal/config"
+	"movie-discovery-movie-service/internal/models"
+	"movie-discovery-movie-service/internal/repository"
+	"movie-discovery-movie-service/internal/service"
+)
+
+func TestFilterValuesSplitsAndNormalizesQuery(t *testing.T) {
+	h := NewMovieHandler(nil, 3, "")
+	app := fiber.New()
+	app.Get("/filter", func(c fiber.Ctx) error {
+		values, err := h.filterValues(c, "genre")
+		if err != nil {
+			return c.Status(fiber.StatusUnprocessableEntity).SendString(err.Error())
+		}
+		return c.JSON(values)
+	})
+
+	cases := []struct {
+		query  string
+		status int
+		want   []string
+	}{
+		{"genre=Action,%20drama&genre=ACTION", fiber.StatusOK, []string{"action", "drama"}},
+		{"genre=a,b&genre=c", fiber.StatusOK, []string{"a", "b", "c"}},
+		{"genre=a,,b,", fiber.StatusOK, []string{"a", "b"}},
+		{"", fiber.StatusOK, []string{}},
+		{"genre=a,b&genre=c,d", fiber.StatusUnprocessableEntity, nil},
+	}
+	for _, tc := range cases {
+		t.Run(tc.query, func(t *testing.T) {
+			resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/filter?"+tc.query, nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tc.status {
+				t.Fatalf("status %d, want %d", resp.StatusCode, tc.status)
+			}
+			if tc.want == nil {
+				return
+			}
+			var got []string
+			if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestTooManyFilterValuesIsUnprocessable(t *testing.T) {
+	// Over-cap filters are rejected before the service is called
+	h := NewMovieHandler(nil, 2, "")
+	app := fiber.New()
+	app.Get("/movies", h.ListMovies)
+	app.Get("/movies/genres", h.GetMovieGenres)
+
+	for _, target := range []string{
+		"/movies?genre=action,drama,comedy",
+		"/movies/genres?ids=1,2&ids=3",
+	} {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, target, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != fiber.StatusUnprocessableEntity {
+			t.Errorf("%s: status %d, want 422", target, resp.StatusCode)
+		}
+	}
+}
+
+// detailStore is a MovieStore holding movie 1 with one cast member. Methods
+// the tests do not use are left to the embedded interface.
+type detailStore struct {
+	repository.MovieStore
+	castErr error
+}
+
+func (detailStore) GetMovieByID(_ context.Context, id int) (*models.MovieDetail, error) {
+	return &models.MovieDetail{ID: id, Title: "The Secret Life of Pets", Genres: []string{"Animation"}}, nil
+}
+
+func (s detailStore) GetMovieCast(context.Context, int) ([]models.CastMember, error) {
+	if s.castErr != nil {
+		return nil, s.castErr
+	}
+	return []models.CastMember{{Name: "Louis C.K.", Character: "Max (voice)"}}, nil
+}
+
+func TestGetMovieDetailIncludesCast(t *testing.T) {
+	cases := []struct {
+		name     string
+		query    string
+		castErr  error
+		status   int
+		wantCast int
+	}{
+		{"default", "", nil, fiber.StatusOK, 1},
+		{"legacy expand=cast", "?expand=cast", nil, fiber.StatusOK, 1},
+		{"cast lookup fails", "", errors.New("db down"), fiber.StatusOK, 0},
+		{"unknown expand", "?expand=crew", nil, fiber.StatusBadRequest, 0},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			svc := service.NewMovieService(detailStore{castErr: tc.castErr}, nil, cache.Noop{}, nil, config.CacheConfig{})
+			app := fiber.New()
+			app.Get("/movies/:id", NewMovieHandler(svc, 0, "").GetMovieDetail)
+
+			resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/movies/1"+tc.query, nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tc.status {
+				t.Fatalf("status %d, want %d", resp.StatusCode, tc.status)
+			}
+			if tc.status != fiber.StatusOK {
+				return
+			}
+			var detail models.MovieDetail
+			if err := json.NewDecoder(resp.Body).Decode(&detail); err != nil {
+				t.Fatal(err)
+			}
+			if detail.ID != 1 || len(detail.Cast) != tc.wantCast {
+				t.Errorf("got movie %d with %d cast members, want movie 1 with %d", detail.ID, len(detail.Cast), tc.wantCast)
+			}
+		})
+	}
+}
+
+func TestRefreshRequiresAdminToken(t *testing.T) {
+	const token = "secret"
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	// A stale cached cast that refresh must not serve
+	mr.Set("movie:cast:1", `[{"name":"Stale","character":"Nobody"}]`)
+
+	svc := service.NewMovieService(detailStore{}, nil, cache.New(rdb), nil, config.CacheConfig{})
+	h := NewMovieHandler(svc, 0, token)
+	app := fiber.New()
+	app.Get("/movies", h.ListMovies)
+	app.Get("/movies/on-this-day", h.ListMoviesOnThisDay)
+	app.Get("/movies/:id", h.GetMovieDetail)
+
+	cases := []struct {
+		name   string
+		path   string
+		token  string
+		status int
+	}{
+		{"list without token", "/movies?refresh=true", "", fiber.StatusForbidden},
+		{"list with wrong token", "/movies?refresh=true", "guess", fiber.StatusForbidden},
+		{"on this day without token", "/movies/on-this-day?refresh=true", "", fiber.StatusForbidden},
+		{"detail without token", "/movies/1?refresh=true", "", fiber.StatusForbidden},
+		{"detail with token", "/movies/1?refresh=true", token, fiber.StatusOK},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			if tc.token != "" {
+				req.Header.Set(AdminTokenHeader, tc.token)
+			}
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tc.status {
+				t.Fatalf("status %d, want %d", resp.StatusCode, tc.status)
+			}
+			if tc.status != fiber.StatusOK {
+				return
+			}
+			var detail models.MovieDetail
+			if err := json.NewDecoder(resp.Body).Decode(&detail); err != nil {
+				t.Fatal(err)
+			}
+			if len(detail.Cast) != 1 || detail.Cast[0].Name != "Louis C.K." {
+				t.Errorf("got cast %+v, want it reloaded from the store", detail.Cast)
+			}
+		})
+	}
+}
+
+func TestRefreshMovieCacheIncludesCast(t *testing.T) {
+	svc := service.NewMovieService(detailStore{}, nil, cache.Noop{}, nil, config.CacheConfig{})
+	app := fiber.New()
+	app.Post("/admin/movies/:id/refresh-cache", NewMovieHandler(svc, 0, "").RefreshMovieCache)
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodPost, "/admin/movies/1/refresh-cache", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("status %d, want 200", resp.StatusCode)
+	}
+	var detail models.MovieDetail
+	if err := json.NewDecoder(resp.Body).Decode(&detail); err != nil {
+		t.Fatal(err)
+	}
+	if detail.ID != 1 || len(detail.Cast) != 1 {
+		t.Errorf("got movie %d with %d cast members, want movie 1 with 1", detail.ID, len(detail.Cast))
+	}
+}
+
+// batchStore records the IDs BatchMovies looked up.
+type batchStore struct {
+	repository.MovieStore
+	got *[]int
+}
+
+func (s batchStore) GetMoviesByIDs(_ context.Context, ids []int) ([]models.MovieDetail, error) {
+	*s.got = ids
+	return []models.MovieDetail{}, nil
+}
+
+func TestBatchMoviesDedupesAndCapsDistinctIDs(t *testing.T) {
+	cases := []struct {
+		name   string
+		body   string
+		status int
+		want   []int
+	}{
+		{"at the cap", `[1, 2, 3]`, fiber.StatusOK, []int{1, 2, 3}},
+		{"repeats count once", `[3, 1, 3, 2, 1, 3]`, fiber.StatusOK, []int{3, 1, 2}},
+		{"over the cap", `[1, 2, 3, 4]`, fiber.StatusBadRequest, nil},
+		{"invalid ID", `[1, 0]`, fiber.StatusBadRequest, nil},
+		{"empty", `[]`, fiber.StatusBadRequest, nil},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []int
+			svc := service.NewMovieService(batchStore{got: &got}, nil, cache.Noop{}, nil, config.CacheConfig{})
+			app := fiber.New()
+			app.Post("/movies/batch", NewMovieHandler(svc, 3, "").BatchMovies)
+
+			req := httptest.NewRequest(http.MethodPost, "/movies/batch", strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tc.status {
+				t.Fatalf("status %d, want %d", resp.StatusCode, tc.status)
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("looked up %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
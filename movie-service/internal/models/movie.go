@@ -1,6 +1,13 @@
 package models
 
-import "time"
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"movie-discovery-movie-service/internal/cache"
+)
 
 // Movie represents a movie stored in our database.
 type Movie struct {
@@ -14,6 +21,8 @@ type Movie struct {
 	BackdropPath     string    `json:"backdrop_path"`
 	OriginalLanguage string    `json:"original_language"`
 	Runtime          int       `json:"runtime"`
+	VoteAverage      float64   `json:"vote_average"`
+	VoteCount        int       `json:"vote_count"`
 	CreatedAt        time.Time `json:"created_at"`
 	UpdatedAt        time.Time `json:"updated_at"`
 }
@@ -31,7 +40,17 @@ type MovieListItem struct {
 	Title       string  `json:"title"`
 	ReleaseDate string  `json:"release_date"`
 	Popularity  float64 `json:"popularity"`
+	VoteAverage float64 `json:"vote_average"`
+	VoteCount   int     `json:"vote_count"`
 	PosterURL   string  `json:"poster_url"`
+	// Genres is only populated when the listing requests include_genres.
+	Genres []string `json:"genres,omitempty"`
+}
+
+// SimilarMovie is a movie related to another by the genres they share.
+type SimilarMovie struct {
+	MovieListItem
+	SharedGenres int `json:"shared_genres"`
 }
 
 // MovieListResponse is the paginated movie listing response.
@@ -53,11 +72,26 @@ type MovieDetail struct {
 	Language    string   `json:"language"`
 	Duration    int      `json:"duration"`
 	Popularity  float64  `json:"popularity"`
+	VoteAverage float64  `json:"vote_average"`
+	VoteCount   int      `json:"vote_count"`
 	PosterURL   string   `json:"poster_url"`
 	BackdropURL string   `json:"backdrop_url"`
 	BookingURL  string   `json:"booking_url"`
+	// Cast is set by the movie detail endpoint and left out of list and
+	// batch responses.
+	Cast []CastMember `json:"cast,omitempty"`
+}
+
+// CastMember is one top-billed actor of a movie, in billing order.
+type CastMember struct {
+	Name       string `json:"name"`
+	Character  string `json:"character"`
+	ProfileURL string `json:"profile_url,omitempty"`
 }
 
+// MaxCastMembers is how many top-billed cast members are stored per movie.
+const MaxCastMembers = 10
+
 // MovieListParams holds query parameters for movie listing.
 type MovieListParams struct {
 	Page            int    `query:"page"`
@@ -66,8 +100,30 @@ type MovieListParams struct {
 	Order           string `query:"order"`
 	ReleaseDateFrom string `query:"release_date_from"`
 	ReleaseDateTo   string `query:"release_date_to"`
+	IncludeGenres   bool   `query:"include_genres"`
+	// GenreID restricts results to one genre (internal ID); 0 means all.
+	GenreID int `query:"-"`
+	// Search restricts results to titles/overviews matching the text.
+	Search string `query:"-"`
+	// ReleaseMonth and ReleaseDay restrict results to movies released on
+	// that calendar day in any year; 0 means no restriction.
+	ReleaseMonth int `query:"-"`
+	ReleaseDay   int `query:"-"`
+	// Genres restricts results by genre name (case-insensitive). GenreMatch
+	// "any" (default) keeps movies with at least one of them, "all" only
+	// movies with every one.
+	Genres     []string `query:"-"`
+	GenreMatch string   `query:"genre_match"`
+	// Refresh skips the cached page and overwrites it with fresh results.
+	Refresh bool `query:"refresh"`
 }
 
+// Accepted genre_match values.
+const (
+	GenreMatchAny = "any"
+	GenreMatchAll = "all"
+)
+
 // Validate sets defaults and validates parameters.
 func (p *MovieListParams) Validate() {
 	if p.Page < 1 {
@@ -83,18 +139,143 @@ func (p *MovieListParams) Validate() {
 		p.Order = "desc"
 	}
 	// Validate sort_by values
-	validSorts := map[string]bool{"release_date": true, "title": true, "popularity": true}
-	if !validSorts[p.SortBy] {
+	if !contains(ValidSortFields, p.SortBy) {
 		p.SortBy = "popularity"
 	}
 	// Validate order values
-	if p.Order != "asc" && p.Order != "desc" {
+	if !contains(ValidSortOrders, p.Order) {
 		p.Order = "desc"
 	}
+	// Genre names match case-insensitively
+	genres, _ := NormalizeFilterValues("genre", p.Genres, 0)
+	p.Genres = genres
+	if p.GenreMatch != GenreMatchAll {
+		p.GenreMatch = GenreMatchAny
+	}
+}
+
+// Accepted sort_by and order values.
+var (
+	ValidSortFields = []string{"release_date", "title", "popularity"}
+	ValidSortOrders = []string{"asc", "desc"}
+	ValidGenreMatch = []string{GenreMatchAny, GenreMatchAll}
+)
+
+// ValidateStrict reports an invalid sort_by, order or genre_match value instead of
+// letting Validate silently fall back to the default. Empty values are
+// accepted and defaulted as usual.
+func (p *MovieListParams) ValidateStrict() error {
+	if p.SortBy != "" && !contains(ValidSortFields, p.SortBy) {
+		return fmt.Errorf("invalid sort_by %q, must be one of: %s", p.SortBy, strings.Join(ValidSortFields, ", "))
+	}
+	if p.Order != "" && !contains(ValidSortOrders, p.Order) {
+		return fmt.Errorf("invalid order %q, must be one of: %s", p.Order, strings.Join(ValidSortOrders, ", "))
+	}
+	if p.GenreMatch != "" && !contains(ValidGenreMatch, p.GenreMatch) {
+		return fmt.Errorf("invalid genre_match %q, must be one of: %s", p.GenreMatch, strings.Join(ValidGenreMatch, ", "))
+	}
+	return nil
+}
+
+// ErrTooManyFilterValues is returned when a list filter exceeds its cap.
+var ErrTooManyFilterValues = errors.New("too many filter values")
+
+// NormalizeFilterValues trims, lowercases and deduplicates list filter
+// values, dropping empty ones, so that repeated values never reach an
+// = ANY($n) query. It fails with ErrTooManyFilterValues when more than max
+// distinct values remain; a max of zero or less disables the cap.
+func NormalizeFilterValues(field string, values []string, max int) ([]string, error) {
+	seen := make(map[string]bool, len(values))
+	normalized := make([]string, 0, len(values))
+	for _, v := range values {
+		v = strings.ToLower(strings.TrimSpace(v))
+		if v == "" || seen[v] {
+			continue
+		}
+		seen[v] = true
+		normalized = append(normalized, v)
+	}
+	if max > 0 && len(normalized) > max {
+		return nil, fmt.Errorf("%w: %s accepts at most %d values", ErrTooManyFilterValues, field, max)
+	}
+	return normalized, nil
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
 }
 
 const (
 	TMDBImageBaseW500 = "https://image.tmdb.org/t/p/w500"
 	TMDBImageBaseW780 = "https://image.tmdb.org/t/p/w780"
+	TMDBImageBaseW185 = "https://image.tmdb.org/t/p/w185"
 	DefaultBookingURL = "https://www.google.com/"
 )
+
+// Sync modes accepted by the admin sync endpoint.
+const (
+	SyncModeFull        = "full"
+	SyncModeIncremental = "incremental"
+)
+
+// SyncResult summarizes an incremental sync: movies stored for the first
+// time, stored movies whose data changed, and movies left as they were.
+type SyncResult struct {
+	Since   time.Time `json:"since"`
+	Created int       `json:"created"`
+	Updated int       `json:"updated"`
+	Skipped int       `json:"skipped"`
+}
+
+// Sync job states.
+const (
+	SyncJobRunning   = "running"
+	SyncJobCompleted = "completed"
+	SyncJobFailed    = "failed"
+)
+
+// SyncJob is the status of a background catalog sync.
+type SyncJob struct {
+	ID           string `json:"job_id"`
+	Mode         string `json:"mode"`
+	State        string `json:"state"`
+	Pages        int    `json:"pages"`
+	PagesDone    int    `json:"pages_done"`
+	MoviesSynced int    `json:"movies_synced"`
+	// Language, Region and SortBy are the sync's discover overrides, empty
+	// when it used the configured defaults.
+	Language string `json:"language,omitempty"`
+	Region   string `json:"region,omitempty"`
+	SortBy   string `json:"sort_by,omitempty"`
+	// Result breaks down a completed incremental sync.
+	Result     *SyncResult `json:"result,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	StartedAt  time.Time   `json:"started_at"`
+	FinishedAt *time.Time  `json:"finished_at,omitempty"`
+}
+
+// ServiceStats is the operational snapshot served on /internal/stats.
+type ServiceStats struct {
+	Service    string      `json:"service"`
+	Cache      cache.Stats `json:"cache"`
+	MovieCount int         `json:"movie_count"`
+	// LastSyncAt is the most recent time a movie was written by a sync.
+	LastSyncAt *time.Time `json:"last_sync_at"`
+	// DetailTiers reports how movie details were spread across cache tiers.
+	DetailTiers DetailCacheTierStats `json:"detail_tiers"`
+}
+
+// DetailCacheTierStats counts movie detail cache writes per TTL tier since
+// startup, alongside the tier settings, to judge the effect on hit ratio.
+type DetailCacheTierStats struct {
+	HotPopularity  float64 `json:"hot_popularity"`
+	HotTTLSeconds  int     `json:"hot_ttl_seconds"`
+	ColdTTLSeconds int     `json:"cold_ttl_seconds"`
+	HotSets        int64   `json:"hot_sets"`
+	ColdSets       int64   `json:"cold_sets"`
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestNormalizeFilterValues(t *testing.T) {
+	cases := []struct {
+		name    string
+		values  []string
+		max     int
+		want    []string
+		wantErr bool
+	}{
+		{"trims and lowercases", []string{" Action ", "DRAMA"}, 5, []string{"action", "drama"}, false},
+		{"dedups after normalizing", []string{"Action", "action", " ACTION", "Drama"}, 5, []string{"action", "drama"}, false},
+		{"drops empty values", []string{"", "  ", "Comedy"}, 5, []string{"comedy"}, false},
+		{"keeps first-seen order", []string{"b", "a", "b"}, 5, []string{"b", "a"}, false},
+		{"nothing given", nil, 5, []string{}, false},
+		{"at max", []string{"a", "b", "c"}, 3, []string{"a", "b", "c"}, false},
+		{"duplicates do not count toward max", []string{"a", "A", "b", "B", "c"}, 3, []string{"a", "b", "c"}, false},
+		{"over max", []string{"a", "b", "c", "d"}, 3, nil, true},
+		{"cap disabled", []string{"a", "b", "c", "d"}, 0, []string{"a", "b", "c", "d"}, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := NormalizeFilterValues("genre", tc.values, tc.max)
+			if tc.wantErr {
+				if !errors.Is(err, ErrTooManyFilterValues) {
+					t.Errorf("got %v, want ErrTooManyFilterValues", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("got %v, want nil", err)
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -1,12 +1,15 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log/slog"
 	"strings"
 	"time"
 
+	"github.com/lib/pq"
+
 	"movie-discovery-movie-service/internal/models"
 )
 
@@ -21,9 +24,9 @@ func NewMovieRepository(db *sql.DB) *MovieRepository {
 }
 
 // UpsertGenre inserts or updates a genre.
-func (r *MovieRepository) UpsertGenre(tmdbID int, name string) (int, error) {
+func (r *MovieRepository) UpsertGenre(ctx context.Context, tmdbID int, name string) (int, error) {
 	var id int
-	err := r.db.QueryRow(`
+	err := r.db.QueryRowContext(ctx, `
 		INSERT INTO genres (tmdb_id, name)
 		VALUES ($1, $2)
 		ON CONFLICT (tmdb_id) DO UPDATE SET name = EXCLUDED.name
@@ -33,12 +36,12 @@ func (r *MovieRepository) UpsertGenre(tmdbID int, name string) (int, error) {
 }
 
 // UpsertMovie inserts or updates a movie.
-func (r *MovieRepository) UpsertMovie(m *models.Movie) (int, error) {
+func (r *MovieRepository) UpsertMovie(ctx context.Context, m *models.Movie) (int, error) {
 	var id int
-	err := r.db.QueryRow(`
+	err := r.db.QueryRowContext(ctx, `
 		INSERT INTO movies (tmdb_id, title, overview, release_date, popularity,
-			poster_path, backdrop_path, original_language, runtime, updated_at)
-		VALUES ($1, $2, $3, $4::date, $5, $6, $7, $8, $9, $10)
+			poster_path, backdrop_path, original_language, runtime, vote_average, vote_count, updated_at)
+		VALUES ($1, $2, $3, $4::date, $5, $6, $7, $8, $9, $10, $11, $12)
 		ON CONFLICT (tmdb_id) DO UPDATE SET
 			title = EXCLUDED.title,
 			overview = EXCLUDED.overview,
@@ -48,17 +51,19 @@ func (r *MovieRepository) UpsertMovie(m *models.Movie) (int, error) {
 			backdrop_path = EXCLUDED.backdrop_path,
 			original_language = EXCLUDED.original_language,
 			runtime = EXCLUDED.runtime,
+			vote_average = EXCLUDED.vote_average,
+			vote_count = EXCLUDED.vote_count,
 			updated_at = EXCLUDED.updated_at
 		RETURNING id
 	`, m.TMDBId, m.Title, m.Overview, nullableDate(m.ReleaseDate),
 		m.Popularity, m.PosterPath, m.BackdropPath,
-		m.OriginalLanguage, m.Runtime, time.Now()).Scan(&id)
+		m.OriginalLanguage, m.Runtime, m.VoteAverage, m.VoteCount, time.Now()).Scan(&id)
 	return id, err
 }
 
 // LinkMovieGenre creates the movie-genre association.
-func (r *MovieRepository) LinkMovieGenre(movieID, genreID int) error {
-	_, err := r.db.Exec(`
+func (r *MovieRepository) LinkMovieGenre(ctx context.Context, movieID, genreID int) error {
+	_, err := r.db.ExecContext(ctx, `
 		INSERT INTO movie_genres (movie_id, genre_id)
 		VALUES ($1, $2)
 		ON CONFLICT DO NOTHING
@@ -67,14 +72,47 @@ func (r *MovieRepository) LinkMovieGenre(movieID, genreID int) error {
 }
 
 // GetGenreIDByTMDBId returns the internal genre ID for a TMDB genre ID.
-func (r *MovieRepository) GetGenreIDByTMDBId(tmdbID int) (int, error) {
+func (r *MovieRepository) GetGenreIDByTMDBId(ctx context.Context, tmdbID int) (int, error) {
 	var id int
-	err := r.db.QueryRow(`SELECT id FROM genres WHERE tmdb_id = $1`, tmdbID).Scan(&id)
+	err := r.db.QueryRowContext(ctx, `SELECT id FROM genres WHERE tmdb_id = $1`, tmdbID).Scan(&id)
 	return id, err
 }
 
+// GenreExists reports whether a genre with the given internal ID exists.
+func (r *MovieRepository) GenreExists(ctx context.Context, id int) (bool, error) {
+	var exists bool
+	err := r.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM genres WHERE id = $1)`, id).Scan(&exists)
+	return exists, err
+}
+
+// ListGenres returns every stored genre, sorted by name.
+func (r *MovieRepository) ListGenres(ctx context.Context) ([]models.Genre, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT id, tmdb_id, name FROM genres ORDER BY name, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	genres := []models.Genre{}
+	for rows.Next() {
+		var g models.Genre
+		if err := rows.Scan(&g.ID, &g.TMDBId, &g.Name); err != nil {
+			return nil, err
+		}
+		genres = append(genres, g)
+	}
+	return genres, rows.Err()
+}
+
+// MovieExists reports whether a movie with the given internal ID exists.
+func (r *MovieRepository) MovieExists(ctx context.Context, id int) (bool, error) {
+	var exists bool
+	err := r.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM movies WHERE id = $1)`, id).Scan(&exists)
+	return exists, err
+}
+
 // ListMovies returns a paginated list of movies matching the given filters.
-func (r *MovieRepository) ListMovies(params models.MovieListParams) (*models.MovieListResponse, error) {
+func (r *MovieRepository) ListMovies(ctx context.Context, params models.MovieListParams) (*models.MovieListResponse, error) {
 	// Build WHERE clause
 	conditions := []string{"1=1"}
 	args := []interface{}{}
@@ -90,18 +128,54 @@ func (r *MovieRepository) ListMovies(params models.MovieListParams) (*models.Mov
 		args = append(args, params.ReleaseDateTo)
 		argIdx++
 	}
+	if params.ReleaseMonth > 0 && params.ReleaseDay > 0 {
+		// Matches idx_movies_release_month_day
+		conditions = append(conditions, fmt.Sprintf(
+			"EXTRACT(MONTH FROM m.release_date) = $%d AND EXTRACT(DAY FROM m.release_date) = $%d", argIdx, argIdx+1))
+		args = append(args, params.ReleaseMonth, params.ReleaseDay)
+		argIdx += 2
+	}
+	if params.GenreID > 0 {
+		conditions = append(conditions, fmt.Sprintf(
+			"EXISTS (SELECT 1 FROM movie_genres mg WHERE mg.movie_id = m.id AND mg.genre_id = $%d)", argIdx))
+		args = append(args, params.GenreID)
+		argIdx++
+	}
+	if len(params.Genres) > 0 {
+		const genreExists = "EXISTS (SELECT 1 FROM movie_genres mg INNER JOIN genres g ON g.id = mg.genre_id " +
+			"WHERE mg.movie_id = m.id AND LOWER(g.name) = ANY($%d))"
+		if params.GenreMatch == models.GenreMatchAll {
+			// One EXISTS per genre: the movie must carry every one
+			for _, genre := range params.Genres {
+				conditions = append(conditions, fmt.Sprintf(genreExists, argIdx))
+				args = append(args, pq.Array([]string{genre}))
+				argIdx++
+			}
+		} else {
+			conditions = append(conditions, fmt.Sprintf(genreExists, argIdx))
+			args = append(args, pq.Array(params.Genres))
+			argIdx++
+		}
+	}
+	if params.Search != "" {
+		conditions = append(conditions, fmt.Sprintf(
+			"(m.search_vector @@ plainto_tsquery('simple', $%d) OR m.title ILIKE $%d)", argIdx, argIdx+1))
+		args = append(args, params.Search, "%"+escapeLike(params.Search)+"%")
+		argIdx += 2
+	}
 
 	whereClause := strings.Join(conditions, " AND ")
 
 	// Validate sort column to prevent SQL injection
-	sortColumn := "popularity"
+	sortColumn := "m.popularity"
 	switch params.SortBy {
 	case "release_date":
-		sortColumn = "release_date"
+		sortColumn = "m.release_date"
 	case "title":
-		sortColumn = "title"
+		// Case- and accent-insensitive, matching idx_movies_title_sort
+		sortColumn = "LOWER(f_unaccent(m.title))"
 	case "popularity":
-		sortColumn = "popularity"
+		sortColumn = "m.popularity"
 	}
 	orderDir := "DESC"
 	if params.Order == "asc" {
@@ -111,7 +185,7 @@ func (r *MovieRepository) ListMovies(params models.MovieListParams) (*models.Mov
 	// Count total results
 	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM movies m WHERE %s", whereClause)
 	var totalResults int
-	if err := r.db.QueryRow(countQuery, args...).Scan(&totalResults); err != nil {
+	if err := r.db.QueryRowContext(ctx, countQuery, args...).Scan(&totalResults); err != nil {
 		return nil, fmt.Errorf("count query failed: %w", err)
 	}
 
@@ -126,16 +200,17 @@ func (r *MovieRepository) ListMovies(params models.MovieListParams) (*models.Mov
 	listQuery := fmt.Sprintf(`
 		SELECT m.id, m.title, 
 			COALESCE(TO_CHAR(m.release_date, 'YYYY-MM-DD'), '') as release_date,
-			m.popularity, COALESCE(m.poster_path, '') as poster_path
+			m.popularity, COALESCE(m.vote_average, 0), COALESCE(m.vote_count, 0),
+			COALESCE(m.poster_path, '') as poster_path
 		FROM movies m
 		WHERE %s
-		ORDER BY m.%s %s NULLS LAST
+		ORDER BY %s %s NULLS LAST
 		LIMIT $%d OFFSET $%d
 	`, whereClause, sortColumn, orderDir, argIdx, argIdx+1)
 
 	args = append(args, params.PageSize, offset)
 
-	rows, err := r.db.Query(listQuery, args...)
+	rows, err := r.db.QueryContext(ctx, listQuery, args...)
 	if err != nil {
 		return nil, fmt.Errorf("list query failed: %w", err)
 	}
@@ -145,7 +220,8 @@ func (r *MovieRepository) ListMovies(params models.MovieListParams) (*models.Mov
 	for rows.Next() {
 		var item models.MovieListItem
 		var posterPath string
-		if err := rows.Scan(&item.ID, &item.Title, &item.ReleaseDate, &item.Popularity, &posterPath); err != nil {
+		if err := rows.Scan(&item.ID, &item.Title, &item.ReleaseDate, &item.Popularity,
+			&item.VoteAverage, &item.VoteCount, &posterPath); err != nil {
 			slog.Error("failed to scan movie row", "error", err)
 			continue
 		}
@@ -155,6 +231,20 @@ func (r *MovieRepository) ListMovies(params models.MovieListParams) (*models.Mov
 		items = append(items, item)
 	}
 
+	if params.IncludeGenres && len(items) > 0 {
+		ids := make([]int, len(items))
+		for i, item := range items {
+			ids[i] = item.ID
+		}
+		genres, err := r.GetGenresByMovieIDs(ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+		for i := range items {
+			items[i].Genres = genres[items[i].ID]
+		}
+	}
+
 	return &models.MovieListResponse{
 		Page:         params.Page,
 		PageSize:     params.PageSize,
@@ -164,22 +254,71 @@ func (r *MovieRepository) ListMovies(params models.MovieListParams) (*models.Mov
 	}, nil
 }
 
+// SearchMovies returns a paginated list of movies whose title or overview
+// matches query, honoring the usual sorting and filters.
+func (r *MovieRepository) SearchMovies(ctx context.Context, query string, params models.MovieListParams) (*models.MovieListResponse, error) {
+	params.Search = query
+	return r.ListMovies(ctx, params)
+}
+
+// ListMoviesOnThisDay returns a paginated list of movies released on the
+// given month and day in any year.
+func (r *MovieRepository) ListMoviesOnThisDay(ctx context.Context, month, day int, params models.MovieListParams) (*models.MovieListResponse, error) {
+	params.ReleaseMonth = month
+	params.ReleaseDay = day
+	return r.ListMovies(ctx, params)
+}
+
+// escapeLike escapes LIKE wildcards so user input matches literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// GetGenresByMovieIDs returns genre names for each of the given movies,
+// sorted by name, using a single grouped query.
+func (r *MovieRepository) GetGenresByMovieIDs(ctx context.Context, movieIDs []int) (map[int][]string, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT mg.movie_id, ARRAY_AGG(g.name ORDER BY g.name)
+		FROM movie_genres mg
+		INNER JOIN genres g ON g.id = mg.genre_id
+		WHERE mg.movie_id = ANY($1)
+		GROUP BY mg.movie_id
+	`, pq.Array(movieIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query genres: %w", err)
+	}
+	defer rows.Close()
+
+	result := make(map[int][]string, len(movieIDs))
+	for rows.Next() {
+		var movieID int
+		var names []string
+		if err := rows.Scan(&movieID, pq.Array(&names)); err != nil {
+			return nil, fmt.Errorf("failed to scan genres: %w", err)
+		}
+		result[movieID] = names
+	}
+	return result, rows.Err()
+}
+
 // GetMovieByID returns detailed movie information by internal ID.
-func (r *MovieRepository) GetMovieByID(id int) (*models.MovieDetail, error) {
+func (r *MovieRepository) GetMovieByID(ctx context.Context, id int) (*models.MovieDetail, error) {
 	var detail models.MovieDetail
 	var posterPath, backdropPath string
 
-	err := r.db.QueryRow(`
+	err := r.db.QueryRowContext(ctx, `
 		SELECT m.id, m.title, COALESCE(m.overview, ''),
 			COALESCE(TO_CHAR(m.release_date, 'YYYY-MM-DD'), ''),
 			m.original_language, m.runtime, m.popularity,
+			COALESCE(m.vote_average, 0), COALESCE(m.vote_count, 0),
 			COALESCE(m.poster_path, ''), COALESCE(m.backdrop_path, '')
 		FROM movies m
 		WHERE m.id = $1
 	`, id).Scan(
 		&detail.ID, &detail.Title, &detail.Overview,
 		&detail.ReleaseDate, &detail.Language, &detail.Duration,
-		&detail.Popularity, &posterPath, &backdropPath,
+		&detail.Popularity, &detail.VoteAverage, &detail.VoteCount,
+		&posterPath, &backdropPath,
 	)
 	if err != nil {
 		return nil, err
@@ -194,7 +333,7 @@ func (r *MovieRepository) GetMovieByID(id int) (*models.MovieDetail, error) {
 	detail.BookingURL = models.DefaultBookingURL
 
 	// Fetch genres
-	rows, err := r.db.Query(`
+	rows, err := r.db.QueryContext(ctx, `
 		SELECT g.name FROM genres g
 		INNER JOIN movie_genres mg ON mg.genre_id = g.id
 		WHERE mg.movie_id = $1
@@ -216,25 +355,288 @@ func (r *MovieRepository) GetMovieByID(id int) (*models.MovieDetail, error) {
 	return &detail, nil
 }
 
+// GetMoviesByIDs returns detailed information for the given internal IDs,
+// in the order requested, with genres aggregated in the same query. Unknown
+// IDs are skipped.
+func (r *MovieRepository) GetMoviesByIDs(ctx context.Context, ids []int) ([]models.MovieDetail, error) {
+	result := make([]models.MovieDetail, 0, len(ids))
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT m.id, m.title, COALESCE(m.overview, ''),
+			COALESCE(TO_CHAR(m.release_date, 'YYYY-MM-DD'), ''),
+			m.original_language, m.runtime, m.popularity,
+			COALESCE(m.vote_average, 0), COALESCE(m.vote_count, 0),
+			COALESCE(m.poster_path, ''), COALESCE(m.backdrop_path, ''),
+			COALESCE(ARRAY_AGG(g.name ORDER BY g.name) FILTER (WHERE g.name IS NOT NULL), '{}')
+		FROM movies m
+		LEFT JOIN movie_genres mg ON mg.movie_id = m.id
+		LEFT JOIN genres g ON g.id = mg.genre_id
+		WHERE m.id = ANY($1)
+		GROUP BY m.id
+		ORDER BY ARRAY_POSITION($1, m.id)
+	`, pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query movies: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var detail models.MovieDetail
+		var posterPath, backdropPath string
+		var genres []string
+		if err := rows.Scan(
+			&detail.ID, &detail.Title, &detail.Overview,
+			&detail.ReleaseDate, &detail.Language, &detail.Duration,
+			&detail.Popularity, &detail.VoteAverage, &detail.VoteCount,
+			&posterPath, &backdropPath, pq.Array(&genres),
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan movie: %w", err)
+		}
+
+		if posterPath != "" {
+			detail.PosterURL = models.TMDBImageBaseW500 + posterPath
+		}
+		if backdropPath != "" {
+			detail.BackdropURL = models.TMDBImageBaseW780 + backdropPath
+		}
+		detail.BookingURL = models.DefaultBookingURL
+		detail.Genres = genres
+		if detail.Genres == nil {
+			detail.Genres = make([]string, 0)
+		}
+		result = append(result, detail)
+	}
+	return result, rows.Err()
+}
+
+// GetSimilarMovies returns up to limit other movies sharing genres with the
+// given one, most shared genres first, then by popularity. A movie without
+// genres has none.
+func (r *MovieRepository) GetSimilarMovies(ctx context.Context, id, limit int) ([]models.SimilarMovie, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT m.id, m.title,
+			COALESCE(TO_CHAR(m.release_date, 'YYYY-MM-DD'), ''),
+			m.popularity, COALESCE(m.vote_average, 0), COALESCE(m.vote_count, 0),
+			COALESCE(m.poster_path, ''), COUNT(*) AS shared_genres
+		FROM movie_genres target
+		INNER JOIN movie_genres mg ON mg.genre_id = target.genre_id AND mg.movie_id <> target.movie_id
+		INNER JOIN movies m ON m.id = mg.movie_id
+		WHERE target.movie_id = $1
+		GROUP BY m.id
+		ORDER BY shared_genres DESC, m.popularity DESC NULLS LAST, m.id
+		LIMIT $2
+	`, id, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query similar movies: %w", err)
+	}
+	defer rows.Close()
+
+	movies := make([]models.SimilarMovie, 0, limit)
+	for rows.Next() {
+		var movie models.SimilarMovie
+		var posterPath string
+		if err := rows.Scan(&movie.ID, &movie.Title, &movie.ReleaseDate, &movie.Popularity,
+			&movie.VoteAverage, &movie.VoteCount, &posterPath, &movie.SharedGenres); err != nil {
+			return nil, fmt.Errorf("failed to scan similar movie: %w", err)
+		}
+		if posterPath != "" {
+			movie.PosterURL = models.TMDBImageBaseW500 + posterPath
+		}
+		movies = append(movies, movie)
+	}
+	return movies, rows.Err()
+}
+
+// UpsertPerson inserts or updates a cast member and returns the internal ID.
+func (r *MovieRepository) UpsertPerson(ctx context.Context, tmdbID int, name, profilePath string) (int, error) {
+	var id int
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO people (tmdb_id, name, profile_path)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (tmdb_id) DO UPDATE SET name = EXCLUDED.name, profile_path = EXCLUDED.profile_path
+		RETURNING id
+	`, tmdbID, name, profilePath).Scan(&id)
+	return id, err
+}
+
+// AddMovieCast stores a cast member at the given billing position.
+func (r *MovieRepository) AddMovieCast(ctx context.Context, movieID, personID int, character string, order int) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO movie_cast (movie_id, person_id, character, cast_order)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (movie_id, cast_order) DO UPDATE SET person_id = EXCLUDED.person_id, character = EXCLUDED.character
+	`, movieID, personID, character, order)
+	return err
+}
+
+// ClearMovieCast removes all cast entries for a movie.
+func (r *MovieRepository) ClearMovieCast(ctx context.Context, movieID int) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM movie_cast WHERE movie_id = $1`, movieID)
+	return err
+}
+
+// MarkCastSynced records that a movie's cast was stored, even when TMDB
+// listed none.
+func (r *MovieRepository) MarkCastSynced(ctx context.Context, movieID int) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE movies SET cast_synced_at = NOW() WHERE id = $1`, movieID)
+	return err
+}
+
+// GetMovieCast returns a movie's stored cast in billing order.
+func (r *MovieRepository) GetMovieCast(ctx context.Context, movieID int) ([]models.CastMember, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT p.name, COALESCE(mc.character, ''), COALESCE(p.profile_path, '')
+		FROM movie_cast mc
+		INNER JOIN people p ON p.id = mc.person_id
+		WHERE mc.movie_id = $1
+		ORDER BY mc.cast_order
+	`, movieID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query cast: %w", err)
+	}
+	defer rows.Close()
+
+	cast := make([]models.CastMember, 0)
+	for rows.Next() {
+		var member models.CastMember
+		var profilePath string
+		if err := rows.Scan(&member.Name, &member.Character, &profilePath); err != nil {
+			return nil, fmt.Errorf("failed to scan cast: %w", err)
+		}
+		if profilePath != "" {
+			member.ProfileURL = models.TMDBImageBaseW185 + profilePath
+		}
+		cast = append(cast, member)
+	}
+	return cast, rows.Err()
+}
+
+// GetMoviesByTMDBIds returns the stored movies among the given TMDB IDs,
+// keyed by TMDB ID.
+func (r *MovieRepository) GetMoviesByTMDBIds(ctx context.Context, tmdbIDs []int) (map[int]models.Movie, error) {
+	result := make(map[int]models.Movie, len(tmdbIDs))
+	if len(tmdbIDs) == 0 {
+		return result, nil
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, tmdb_id, title, COALESCE(overview, ''),
+			COALESCE(TO_CHAR(release_date, 'YYYY-MM-DD'), ''), popularity,
+			COALESCE(poster_path, ''), COALESCE(backdrop_path, ''),
+			COALESCE(original_language, ''), runtime,
+			COALESCE(vote_average, 0), COALESCE(vote_count, 0)
+		FROM movies
+		WHERE tmdb_id = ANY($1)
+	`, pq.Array(tmdbIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query movies: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var m models.Movie
+		if err := rows.Scan(&m.ID, &m.TMDBId, &m.Title, &m.Overview, &m.ReleaseDate,
+			&m.Popularity, &m.PosterPath, &m.BackdropPath, &m.OriginalLanguage,
+			&m.Runtime, &m.VoteAverage, &m.VoteCount); err != nil {
+			return nil, fmt.Errorf("failed to scan movie: %w", err)
+		}
+		result[m.TMDBId] = m
+	}
+	return result, rows.Err()
+}
+
+// GetSyncState returns when the named sync last ran, or nil if never.
+func (r *MovieRepository) GetSyncState(ctx context.Context, name string) (*time.Time, error) {
+	var at time.Time
+	err := r.db.QueryRowContext(ctx, `SELECT last_synced_at FROM sync_state WHERE name = $1`, name).Scan(&at)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &at, nil
+}
+
+// SetSyncState records when the named sync last ran.
+func (r *MovieRepository) SetSyncState(ctx context.Context, name string, at time.Time) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO sync_state (name, last_synced_at) VALUES ($1, $2)
+		ON CONFLICT (name) DO UPDATE SET last_synced_at = EXCLUDED.last_synced_at
+	`, name, at)
+	return err
+}
+
+// GetTMDBIdByID returns the TMDB ID of a stored movie.
+func (r *MovieRepository) GetTMDBIdByID(ctx context.Context, id int) (int, error) {
+	var tmdbID int
+	err := r.db.QueryRowContext(ctx, `SELECT tmdb_id FROM movies WHERE id = $1`, id).Scan(&tmdbID)
+	return tmdbID, err
+}
+
 // GetMovieByTMDBId returns detailed movie information by TMDB ID.
-func (r *MovieRepository) GetMovieByTMDBId(tmdbID int) (*models.MovieDetail, error) {
+func (r *MovieRepository) GetMovieByTMDBId(ctx context.Context, tmdbID int) (*models.MovieDetail, error) {
 	var internalID int
-	err := r.db.QueryRow(`SELECT id FROM movies WHERE tmdb_id = $1`, tmdbID).Scan(&internalID)
+	err := r.db.QueryRowContext(ctx, `SELECT id FROM movies WHERE tmdb_id = $1`, tmdbID).Scan(&internalID)
 	if err != nil {
 		return nil, err
 	}
-	return r.GetMovieByID(internalID)
+	return r.GetMovieByID(ctx, internalID)
 }
 
 // ClearMovieGenres removes all genre links for a movie.
-func (r *MovieRepository) ClearMovieGenres(movieID int) error {
-	_, err := r.db.Exec(`DELETE FROM movie_genres WHERE movie_id = $1`, movieID)
+func (r *MovieRepository) ClearMovieGenres(ctx context.Context, movieID int) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM movie_genres WHERE movie_id = $1`, movieID)
 	return err
 }
 
-// GetAllMovies returns all movie IDs and TMDB IDs (for syncing runtime).
-func (r *MovieRepository) GetAllMovies() ([]struct{ ID, TMDBId int }, error) {
-	rows, err := r.db.Query(`SELECT id, tmdb_id FROM movies WHERE runtime = 0`)
+// GetCatalogStats returns the number of movies and when one was last written.
+func (r *MovieRepository) GetCatalogStats(ctx context.Context) (int, *time.Time, error) {
+	var count int
+	var lastUpdated sql.NullTime
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*), MAX(updated_at) FROM movies`).Scan(&count, &lastUpdated)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to query catalog stats: %w", err)
+	}
+	if !lastUpdated.Valid {
+		return count, nil, nil
+	}
+	return count, &lastUpdated.Time, nil
+}
+
+// GetAllMovies returns the IDs and TMDB IDs of movies still missing their
+// runtime or never cast-synced (for syncing details).
+func (r *MovieRepository) GetAllMovies(ctx context.Context) ([]struct{ ID, TMDBId int }, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT m.id, m.tmdb_id FROM movies m
+		WHERE m.runtime = 0 OR m.cast_synced_at IS NULL
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []struct{ ID, TMDBId int }
+	for rows.Next() {
+		var item struct{ ID, TMDBId int }
+		if err := rows.Scan(&item.ID, &item.TMDBId); err == nil {
+			result = append(result, item)
+		}
+	}
+	return result, nil
+}
+
+// GetMoviesWithoutGenres returns up to limit movies that have no genre links.
+func (r *MovieRepository) GetMoviesWithoutGenres(ctx context.Context, limit int) ([]struct{ ID, TMDBId int }, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT m.id, m.tmdb_id FROM movies m
+		WHERE NOT EXISTS (SELECT 1 FROM movie_genres mg WHERE mg.movie_id = m.id)
+		ORDER BY m.id
+		LIMIT $1
+	`, limit)
 	if err != nil {
 		return nil, err
 	}
@@ -251,8 +653,8 @@ func (r *MovieRepository) GetAllMovies() ([]struct{ ID, TMDBId int }, error) {
 }
 
 // UpdateRuntime sets the runtime for a movie.
-func (r *MovieRepository) UpdateRuntime(id, runtime int) error {
-	_, err := r.db.Exec(`UPDATE movies SET runtime = $1, updated_at = NOW() WHERE id = $2`, runtime, id)
+func (r *MovieRepository) UpdateRuntime(ctx context.Context, id, runtime int) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE movies SET runtime = $1, updated_at = NOW() WHERE id = $2`, runtime, id)
 	return err
 }
 
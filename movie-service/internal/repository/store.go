@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"movie-discovery-movie-service/internal/models"
+)
+
+// MovieStore is the persistence contract the movie service depends on.
+// MovieRepository is the Postgres implementation; tests may supply a fake.
+type MovieStore interface {
+	UpsertGenre(ctx context.Context, tmdbID int, name string) (int, error)
+	UpsertMovie(ctx context.Context, m *models.Movie) (int, error)
+	LinkMovieGenre(ctx context.Context, movieID, genreID int) error
+	ClearMovieGenres(ctx context.Context, movieID int) error
+	GetGenreIDByTMDBId(ctx context.Context, tmdbID int) (int, error)
+	GenreExists(ctx context.Context, id int) (bool, error)
+	ListGenres(ctx context.Context) ([]models.Genre, error)
+	ListMovies(ctx context.Context, params models.MovieListParams) (*models.MovieListResponse, error)
+	SearchMovies(ctx context.Context, query string, params models.MovieListParams) (*models.MovieListResponse, error)
+	ListMoviesOnThisDay(ctx context.Context, month, day int, params models.MovieListParams) (*models.MovieListResponse, error)
+	MovieExists(ctx context.Context, id int) (bool, error)
+	GetMovieByID(ctx context.Context, id int) (*models.MovieDetail, error)
+	GetMoviesByIDs(ctx context.Context, ids []int) ([]models.MovieDetail, error)
+	GetSimilarMovies(ctx context.Context, id, limit int) ([]models.SimilarMovie, error)
+	GetGenresByMovieIDs(ctx context.Context, movieIDs []int) (map[int][]string, error)
+	UpsertPerson(ctx context.Context, tmdbID int, name, profilePath string) (int, error)
+	AddMovieCast(ctx context.Context, movieID, personID int, character string, order int) error
+	ClearMovieCast(ctx context.Context, movieID int) error
+	MarkCastSynced(ctx context.Context, movieID int) error
+	GetMovieCast(ctx context.Context, movieID int) ([]models.CastMember, error)
+	GetMoviesByTMDBIds(ctx context.Context, tmdbIDs []int) (map[int]models.Movie, error)
+	GetSyncState(ctx context.Context, name string) (*time.Time, error)
+	SetSyncState(ctx context.Context, name string, at time.Time) error
+	GetTMDBIdByID(ctx context.Context, id int) (int, error)
+	GetAllMovies(ctx context.Context) ([]struct{ ID, TMDBId int }, error)
+	GetMoviesWithoutGenres(ctx context.Context, limit int) ([]struct{ ID, TMDBId int }, error)
+	GetCatalogStats(ctx context.Context) (int, *time.Time, error)
+	UpdateRuntime(ctx context.Context, id, runtime int) error
+}
+
+var _ MovieStore = (*MovieRepository)(nil)
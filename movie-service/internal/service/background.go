@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// background tracks the goroutines a MovieService starts outside any
+// request, such as sync jobs and runtime syncs, so shutdown can wait for
+// them and report the ones it had to abandon.
+type background struct {
+	wg    sync.WaitGroup
+	mu    sync.Mutex
+	tasks map[string]int
+	// ctx is handed to every task and cancelled when shutdown gives up
+	// waiting, so TMDB calls and retry waits stop.
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func newBackground() *background {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &background{tasks: make(map[string]int), ctx: ctx, cancel: cancel}
+}
+
+// run starts fn in a goroutine, counted under name until it returns.
+func (b *background) run(name string, fn func(ctx context.Context)) {
+	b.mu.Lock()
+	b.tasks[name]++
+	b.mu.Unlock()
+
+	b.wg.Add(1)
+	go func() {
+		defer func() {
+			b.mu.Lock()
+			if b.tasks[name]--; b.tasks[name] == 0 {
+				delete(b.tasks, name)
+			}
+			b.mu.Unlock()
+			b.wg.Done()
+		}()
+		fn(b.ctx)
+	}()
+}
+
+// running returns the names of the tasks still running, sorted.
+func (b *background) running() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	names := make([]string, 0, len(b.tasks))
+	for name := range b.tasks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// WaitBackground waits for the service's background work to finish or ctx
+// to be done, whichever comes first. It returns the tasks still running
+// when ctx ended, or nil when everything finished. Tasks still running are
+// cancelled.
+func (s *MovieService) WaitBackground(ctx context.Context) []string {
+	done := make(chan struct{})
+	go func() {
+		s.bg.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.bg.cancel()
+		return s.bg.running()
+	}
+}
@@ -6,47 +6,89 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"sort"
+	"strings"
+	"sync/atomic"
 	"time"
 
-	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/singleflight"
 
+	"movie-discovery-movie-service/internal/cache"
+	"movie-discovery-movie-service/internal/config"
+	"movie-discovery-movie-service/internal/events"
+	"movie-discovery-movie-service/internal/metrics"
 	"movie-discovery-movie-service/internal/models"
 	"movie-discovery-movie-service/internal/repository"
 	"movie-discovery-movie-service/internal/tmdb"
 )
 
+const movieListCacheTTL = 5 * time.Minute
+
+// genreListCacheTTL is long since genres only change with a TMDB sync,
+// which drops the cached list anyway.
+const genreListCacheTTL = 24 * time.Hour
+
+// genreListCacheKey is the cache key of the full genre list.
+const genreListCacheKey = "genres:list"
+
+// catalogSyncState names the sync_state row recording the last catalog sync.
+const catalogSyncState = "catalog"
+
+// Incremental syncs read TMDB's changes feed back to the last sync, limited
+// to the 14 days TMDB accepts; without a recorded sync the last day is used.
 const (
-	movieListCacheTTL   = 5 * time.Minute
-	movieDetailCacheTTL = 30 * time.Minute
+	defaultChangesWindow = 24 * time.Hour
+	maxChangesWindow     = 14 * 24 * time.Hour
+	maxChangesPages      = 100
 )
 
 // MovieService handles business logic for movies.
 type MovieService struct {
-	repo       *repository.MovieRepository
+	repo       repository.MovieStore
 	tmdbClient *tmdb.Client
-	redis      *redis.Client
+	cache      cache.Cache
+	events     *events.Dispatcher
+	cacheCfg   config.CacheConfig
+	// hotSets and coldSets count movie detail cache writes per tier.
+	hotSets  atomic.Int64
+	coldSets atomic.Int64
+	jobs     *syncJobs
+	bg       *background
+	// flight collapses concurrent cache misses on the same key into one
+	// database query.
+	flight singleflight.Group
 }
 
 // NewMovieService creates a new MovieService.
-func NewMovieService(repo *repository.MovieRepository, tmdbClient *tmdb.Client, rdb *redis.Client) *MovieService {
+func NewMovieService(repo repository.MovieStore, tmdbClient *tmdb.Client, c cache.Cache, dispatcher *events.Dispatcher, cacheCfg config.CacheConfig) *MovieService {
 	return &MovieService{
 		repo:       repo,
 		tmdbClient: tmdbClient,
-		redis:      rdb,
+		cache:      c,
+		events:     dispatcher,
+		cacheCfg:   cacheCfg,
+		jobs:       newSyncJobs(),
+		bg:         newBackground(),
 	}
 }
 
-// SyncMovies fetches movies from TMDB and stores them in PostgreSQL.
-func (s *MovieService) SyncMovies(pages int) (int, error) {
+// SyncProgress is called after each discover page of a sync with the pages
+// processed and movies written so far.
+type SyncProgress func(pagesDone, moviesSynced int)
+
+// SyncMovies fetches movies from TMDB and stores them in PostgreSQL. Empty
+// fields of opts use the TMDB client's defaults. progress may be nil.
+func (s *MovieService) SyncMovies(ctx context.Context, pages int, opts tmdb.DiscoverOptions, progress SyncProgress) (int, error) {
 	slog.Info("starting TMDB sync", "pages", pages)
+	startedAt := time.Now()
 
 	// First, sync genres
-	genres, err := s.tmdbClient.GetGenres()
+	genres, err := s.tmdbClient.GetGenres(ctx)
 	if err != nil {
 		return 0, fmt.Errorf("failed to fetch TMDB genres: %w", err)
 	}
 	for _, g := range genres {
-		if _, err := s.repo.UpsertGenre(g.ID, g.Name); err != nil {
+		if _, err := s.repo.UpsertGenre(ctx, g.ID, g.Name); err != nil {
 			slog.Error("failed to upsert genre", "genre", g.Name, "error", err)
 		}
 	}
@@ -55,9 +97,10 @@ func (s *MovieService) SyncMovies(pages int) (int, error) {
 	// Then, sync movies from discover endpoint
 	totalSynced := 0
 	for page := 1; page <= pages; page++ {
-		result, err := s.tmdbClient.DiscoverMovies(page)
+		result, err := s.tmdbClient.DiscoverMovies(ctx, page, opts)
 		if err != nil {
 			slog.Error("failed to fetch TMDB page", "page", page, "error", err)
+			reportProgress(progress, page, totalSynced)
 			continue
 		}
 
@@ -71,57 +114,265 @@ func (s *MovieService) SyncMovies(pages int) (int, error) {
 				PosterPath:       tmdbMovie.PosterPath,
 				BackdropPath:     tmdbMovie.BackdropPath,
 				OriginalLanguage: tmdbMovie.OriginalLanguage,
+				VoteAverage:      tmdbMovie.VoteAverage,
+				VoteCount:        tmdbMovie.VoteCount,
 			}
 
-			movieID, err := s.repo.UpsertMovie(movie)
+			movieID, err := s.repo.UpsertMovie(ctx, movie)
 			if err != nil {
 				slog.Error("failed to upsert movie", "title", movie.Title, "error", err)
 				continue
 			}
 
-			// Clear existing genre links and re-create
-			_ = s.repo.ClearMovieGenres(movieID)
-			for _, genreID := range tmdbMovie.GenreIDs {
-				internalGenreID, err := s.repo.GetGenreIDByTMDBId(genreID)
-				if err != nil {
-					continue
-				}
-				_ = s.repo.LinkMovieGenre(movieID, internalGenreID)
-			}
+			s.relinkGenreIDs(ctx, movieID, tmdbMovie.GenreIDs)
 
 			totalSynced++
 		}
 
 		slog.Info("synced page", "page", page, "movies", len(result.Results))
+		reportProgress(progress, page, totalSynced)
 	}
 
 	// Fetch runtime for movies that don't have it yet
-	go s.syncRuntimes()
+	s.bg.run("runtime-sync", s.syncRuntimes)
 
 	// Invalidate Redis cache after sync
-	s.invalidateCache()
+	s.invalidateCache(ctx)
 
+	// Notify downstream consumers that the catalog changed
+	s.events.Dispatch(events.NewCatalogSynced(totalSynced))
+
+	s.recordSync(ctx, startedAt)
 	slog.Info("TMDB sync completed", "total_synced", totalSynced)
 	return totalSynced, nil
 }
 
-// syncRuntimes fetches runtime for movies that don't have it.
-func (s *MovieService) syncRuntimes() {
-	movies, err := s.repo.GetAllMovies()
+// reportProgress calls progress unless it is nil.
+func reportProgress(progress SyncProgress, pagesDone, moviesSynced int) {
+	if progress != nil {
+		progress(pagesDone, moviesSynced)
+	}
+}
+
+// SyncMoviesIncremental runs SyncMoviesSince from the last recorded sync.
+func (s *MovieService) SyncMoviesIncremental(ctx context.Context, pages int, opts tmdb.DiscoverOptions, progress SyncProgress) (*models.SyncResult, error) {
+	last, err := s.repo.GetSyncState(ctx, catalogSyncState)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read last sync time: %w", err)
+	}
+	since := time.Now().Add(-defaultChangesWindow)
+	if last != nil {
+		since = *last
+	}
+	return s.SyncMoviesSince(ctx, pages, since, opts, progress)
+}
+
+// SyncMoviesSince is a cheap alternative to SyncMovies for frequent
+// scheduled runs. Movies on the first discover pages are only written when
+// new or different from what is stored, and stored movies outside those
+// pages are refreshed only when TMDB's changes feed lists them since the
+// given time. progress may be nil; it only covers the discover pages.
+func (s *MovieService) SyncMoviesSince(ctx context.Context, pages int, since time.Time, opts tmdb.DiscoverOptions, progress SyncProgress) (*models.SyncResult, error) {
+	startedAt := time.Now()
+	if startedAt.Sub(since) > maxChangesWindow {
+		since = startedAt.Add(-maxChangesWindow)
+	}
+	slog.Info("starting incremental TMDB sync", "pages", pages, "since", since)
+
+	result := &models.SyncResult{Since: since}
+	seen := make(map[int]bool)
+
+	// Discover pages: new or changed movies only
+	for page := 1; page <= pages; page++ {
+		discover, err := s.tmdbClient.DiscoverMovies(ctx, page, opts)
+		if err != nil {
+			slog.Error("failed to fetch TMDB page", "page", page, "error", err)
+			reportProgress(progress, page, result.Created+result.Updated)
+			continue
+		}
+
+		ids := make([]int, len(discover.Results))
+		for i, m := range discover.Results {
+			ids[i] = m.ID
+		}
+		stored, err := s.repo.GetMoviesByTMDBIds(ctx, ids)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load stored movies: %w", err)
+		}
+
+		for _, tmdbMovie := range discover.Results {
+			seen[tmdbMovie.ID] = true
+			movie := &models.Movie{
+				TMDBId:           tmdbMovie.ID,
+				Title:            tmdbMovie.Title,
+				Overview:         tmdbMovie.Overview,
+				ReleaseDate:      tmdbMovie.ReleaseDate,
+				Popularity:       tmdbMovie.Popularity,
+				PosterPath:       tmdbMovie.PosterPath,
+				BackdropPath:     tmdbMovie.BackdropPath,
+				OriginalLanguage: tmdbMovie.OriginalLanguage,
+				VoteAverage:      tmdbMovie.VoteAverage,
+				VoteCount:        tmdbMovie.VoteCount,
+			}
+
+			current, exists := stored[tmdbMovie.ID]
+			if exists {
+				if !movieChanged(current, *movie) {
+					result.Skipped++
+					continue
+				}
+				// Discover results carry no runtime; keep the stored one
+				movie.Runtime = current.Runtime
+			}
+
+			movieID, err := s.repo.UpsertMovie(ctx, movie)
+			if err != nil {
+				slog.Error("failed to upsert movie", "title", movie.Title, "error", err)
+				continue
+			}
+			s.relinkGenreIDs(ctx, movieID, tmdbMovie.GenreIDs)
+
+			if exists {
+				result.Updated++
+			} else {
+				result.Created++
+			}
+		}
+		reportProgress(progress, page, result.Created+result.Updated)
+
+		if page >= discover.TotalPages {
+			break
+		}
+	}
+
+	// Changes feed: stored movies edited on TMDB since the last sync
+	for page := 1; page <= maxChangesPages; page++ {
+		changes, err := s.tmdbClient.GetMovieChanges(ctx, since, startedAt, page)
+		if err != nil {
+			slog.Error("failed to fetch TMDB changes", "page", page, "error", err)
+			break
+		}
+
+		var ids []int
+		for _, c := range changes.Results {
+			if !seen[c.ID] {
+				seen[c.ID] = true
+				ids = append(ids, c.ID)
+			}
+		}
+		stored, err := s.repo.GetMoviesByTMDBIds(ctx, ids)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load stored movies: %w", err)
+		}
+
+		for tmdbID, current := range stored {
+			detail, err := s.tmdbClient.GetMovieDetail(ctx, tmdbID)
+			if err != nil {
+				slog.Error("failed to fetch movie detail", "tmdb_id", tmdbID, "error", err)
+				continue
+			}
+			movie := movieFromDetail(detail)
+			if !movieChanged(current, *movie) {
+				result.Skipped++
+				continue
+			}
+
+			if _, err := s.repo.UpsertMovie(ctx, movie); err != nil {
+				slog.Error("failed to upsert movie", "title", movie.Title, "error", err)
+				continue
+			}
+			_ = s.repo.ClearMovieGenres(ctx, current.ID)
+			s.linkGenres(ctx, current.ID, detail.Genres)
+			s.storeCast(ctx, current.ID, detail.Credits)
+			result.Updated++
+
+			// Rate limit TMDB requests
+			time.Sleep(100 * time.Millisecond)
+		}
+
+		if page >= changes.TotalPages {
+			break
+		}
+		if page == maxChangesPages {
+			slog.Warn("TMDB changes feed truncated", "pages", maxChangesPages)
+		}
+	}
+
+	if result.Created > 0 {
+		// Fetch runtime and cast for the new movies
+		s.bg.run("runtime-sync", s.syncRuntimes)
+	}
+	if written := result.Created + result.Updated; written > 0 {
+		s.invalidateCache(ctx)
+		s.events.Dispatch(events.NewCatalogSynced(written))
+	}
+
+	s.recordSync(ctx, startedAt)
+	slog.Info("incremental TMDB sync completed",
+		"created", result.Created, "updated", result.Updated, "skipped", result.Skipped)
+	return result, nil
+}
+
+// movieChanged reports whether TMDB data differs from the stored movie. A
+// zero incoming runtime means TMDB did not send one.
+func movieChanged(stored, incoming models.Movie) bool {
+	return stored.Title != incoming.Title ||
+		stored.Overview != incoming.Overview ||
+		stored.ReleaseDate != incoming.ReleaseDate ||
+		stored.Popularity != incoming.Popularity ||
+		stored.PosterPath != incoming.PosterPath ||
+		stored.BackdropPath != incoming.BackdropPath ||
+		stored.OriginalLanguage != incoming.OriginalLanguage ||
+		stored.VoteAverage != incoming.VoteAverage ||
+		stored.VoteCount != incoming.VoteCount ||
+		(incoming.Runtime > 0 && stored.Runtime != incoming.Runtime)
+}
+
+// recordSync stores the start time of a completed sync, which the next
+// incremental sync reads the changes feed from.
+func (s *MovieService) recordSync(ctx context.Context, startedAt time.Time) {
+	if err := s.repo.SetSyncState(ctx, catalogSyncState, startedAt); err != nil {
+		slog.Error("failed to record sync time", "error", err)
+	}
+}
+
+// relinkGenreIDs replaces a movie's genre links with the given TMDB genre
+// IDs. Genres not stored yet are skipped.
+func (s *MovieService) relinkGenreIDs(ctx context.Context, movieID int, tmdbGenreIDs []int) {
+	// Clear existing genre links and re-create
+	_ = s.repo.ClearMovieGenres(ctx, movieID)
+	for _, genreID := range tmdbGenreIDs {
+		internalGenreID, err := s.repo.GetGenreIDByTMDBId(ctx, genreID)
+		if err != nil {
+			continue
+		}
+		_ = s.repo.LinkMovieGenre(ctx, movieID, internalGenreID)
+	}
+}
+
+// syncRuntimes fetches runtime and top-billed cast for movies that don't
+// have them.
+func (s *MovieService) syncRuntimes(ctx context.Context) {
+	movies, err := s.repo.GetAllMovies(ctx)
 	if err != nil {
 		slog.Error("failed to get movies for runtime sync", "error", err)
 		return
 	}
 
 	for _, m := range movies {
-		detail, err := s.tmdbClient.GetMovieDetail(m.TMDBId)
+		if ctx.Err() != nil {
+			slog.Warn("runtime sync stopped", "error", ctx.Err())
+			return
+		}
+		detail, err := s.tmdbClient.GetMovieDetail(ctx, m.TMDBId)
 		if err != nil {
 			slog.Error("failed to fetch movie detail", "tmdb_id", m.TMDBId, "error", err)
 			continue
 		}
-		if err := s.repo.UpdateRuntime(m.ID, detail.Runtime); err != nil {
+		if err := s.repo.UpdateRuntime(ctx, m.ID, detail.Runtime); err != nil {
 			slog.Error("failed to update runtime", "id", m.ID, "error", err)
 		}
+		s.storeCast(ctx, m.ID, detail.Credits)
 		// Rate limit TMDB requests
 		time.Sleep(100 * time.Millisecond)
 	}
@@ -129,96 +380,558 @@ func (s *MovieService) syncRuntimes() {
 }
 
 // ListMovies returns a paginated list of movies.
-func (s *MovieService) ListMovies(params models.MovieListParams) (*models.MovieListResponse, error) {
+func (s *MovieService) ListMovies(ctx context.Context, params models.MovieListParams) (*models.MovieListResponse, error) {
 	params.Validate()
 
-	// Try Redis cache
-	cacheKey := fmt.Sprintf("movies:list:%d:%d:%s:%s:%s:%s",
+	// Try Redis cache unless asked to refresh
+	cacheKey := fmt.Sprintf("movies:list:%d:%d:%s:%s:%s:%s:%t:%s:%s",
 		params.Page, params.PageSize, params.SortBy, params.Order,
-		params.ReleaseDateFrom, params.ReleaseDateTo)
+		params.ReleaseDateFrom, params.ReleaseDateTo, params.IncludeGenres,
+		strings.Join(params.Genres, ","), params.GenreMatch)
+
+	if !params.Refresh {
+		if cached, err := s.getFromCache(ctx, cacheKey); err == nil {
+			var result models.MovieListResponse
+			if json.Unmarshal([]byte(cached), &result) == nil {
+				slog.Debug("cache hit", "key", cacheKey)
+				return &result, nil
+			}
+		}
+	}
+
+	// Query from database, once for all concurrent misses on this key
+	v, err := s.shared(ctx, cacheKey, func(ctx context.Context) (any, error) {
+		result, err := s.repo.ListMovies(ctx, params)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list movies: %w", err)
+		}
+
+		// Store in cache
+		if data, err := json.Marshal(result); err == nil {
+			s.setCache(ctx, cacheKey, string(data), movieListCacheTTL)
+		}
+		return result, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Callers sharing the query each get their own copy
+	result := *v.(*models.MovieListResponse)
+	return &result, nil
+}
+
+// ListMoviesByGenre returns a paginated list of movies in one genre.
+func (s *MovieService) ListMoviesByGenre(ctx context.Context, genreID int, params models.MovieListParams) (*models.MovieListResponse, error) {
+	params.Validate()
+	params.GenreID = genreID
+
+	// Try Redis cache unless asked to refresh
+	cacheKey := fmt.Sprintf("movies:genre:%d:%d:%d:%s:%s:%s:%s:%t",
+		genreID, params.Page, params.PageSize, params.SortBy, params.Order,
+		params.ReleaseDateFrom, params.ReleaseDateTo, params.IncludeGenres)
+
+	if !params.Refresh {
+		if cached, err := s.getFromCache(ctx, cacheKey); err == nil {
+			var result models.MovieListResponse
+			if json.Unmarshal([]byte(cached), &result) == nil {
+				slog.Debug("cache hit", "key", cacheKey)
+				return &result, nil
+			}
+		}
+	}
+
+	exists, err := s.repo.GenreExists(ctx, genreID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check genre: %w", err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("genre not found")
+	}
+
+	result, err := s.repo.ListMovies(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list movies by genre: %w", err)
+	}
+
+	// Store in cache
+	if data, err := json.Marshal(result); err == nil {
+		s.setCache(ctx, cacheKey, string(data), movieListCacheTTL)
+	}
+
+	return result, nil
+}
+
+// SearchMovies returns a paginated list of movies matching a title search.
+func (s *MovieService) SearchMovies(ctx context.Context, query string, params models.MovieListParams) (*models.MovieListResponse, error) {
+	params.Validate()
+
+	// Try Redis cache unless asked to refresh
+	cacheKey := fmt.Sprintf("movies:search:%s:%d:%d:%s:%s:%s:%s:%t",
+		strings.ToLower(query), params.Page, params.PageSize, params.SortBy, params.Order,
+		params.ReleaseDateFrom, params.ReleaseDateTo, params.IncludeGenres)
+
+	if !params.Refresh {
+		if cached, err := s.getFromCache(ctx, cacheKey); err == nil {
+			var result models.MovieListResponse
+			if json.Unmarshal([]byte(cached), &result) == nil {
+				slog.Debug("cache hit", "key", cacheKey)
+				return &result, nil
+			}
+		}
+	}
+
+	result, err := s.repo.SearchMovies(ctx, query, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search movies: %w", err)
+	}
+
+	// Store in cache
+	if data, err := json.Marshal(result); err == nil {
+		s.setCache(ctx, cacheKey, string(data), movieListCacheTTL)
+	}
+
+	return result, nil
+}
+
+// ListMoviesOnThisDay returns movies released on the given month and day in
+// any year, most popular first.
+func (s *MovieService) ListMoviesOnThisDay(ctx context.Context, month, day int, params models.MovieListParams) (*models.MovieListResponse, error) {
+	params.Validate()
+	params.SortBy = "popularity"
+	params.Order = "desc"
+
+	// Try Redis cache unless asked to refresh
+	cacheKey := fmt.Sprintf("movies:onthisday:%02d-%02d:%d:%d:%t",
+		month, day, params.Page, params.PageSize, params.IncludeGenres)
+
+	if !params.Refresh {
+		if cached, err := s.getFromCache(ctx, cacheKey); err == nil {
+			var result models.MovieListResponse
+			if json.Unmarshal([]byte(cached), &result) == nil {
+				slog.Debug("cache hit", "key", cacheKey)
+				return &result, nil
+			}
+		}
+	}
+
+	result, err := s.repo.ListMoviesOnThisDay(ctx, month, day, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list movies on this day: %w", err)
+	}
 
-	if cached, err := s.getFromCache(cacheKey); err == nil {
-		var result models.MovieListResponse
+	// Store in cache
+	if data, err := json.Marshal(result); err == nil {
+		s.setCache(ctx, cacheKey, string(data), movieListCacheTTL)
+	}
+
+	return result, nil
+}
+
+// ListGenres returns every genre, sorted by name.
+func (s *MovieService) ListGenres(ctx context.Context) ([]models.Genre, error) {
+	if cached, err := s.getFromCache(ctx, genreListCacheKey); err == nil {
+		var genres []models.Genre
+		if json.Unmarshal([]byte(cached), &genres) == nil {
+			slog.Debug("cache hit", "key", genreListCacheKey)
+			return genres, nil
+		}
+	}
+
+	genres, err := s.repo.ListGenres(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list genres: %w", err)
+	}
+
+	if data, err := json.Marshal(genres); err == nil {
+		s.setCache(ctx, genreListCacheKey, string(data), genreListCacheTTL)
+	}
+
+	return genres, nil
+}
+
+// GetGenresByMovieIDs returns genre names for each of the given movies.
+// Movies without genres, or unknown IDs, map to an empty list.
+func (s *MovieService) GetGenresByMovieIDs(ctx context.Context, ids []int) (map[int][]string, error) {
+	genres, err := s.repo.GetGenresByMovieIDs(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get genres: %w", err)
+	}
+	for _, id := range ids {
+		if genres[id] == nil {
+			genres[id] = []string{}
+		}
+	}
+	return genres, nil
+}
+
+// GetSimilarMovies returns up to limit movies sharing the most genres with
+// the given one, then the most popular.
+func (s *MovieService) GetSimilarMovies(ctx context.Context, id, limit int) ([]models.SimilarMovie, error) {
+	cacheKey := fmt.Sprintf("movies:similar:%d:%d", id, limit)
+	if cached, err := s.getFromCache(ctx, cacheKey); err == nil {
+		var result []models.SimilarMovie
 		if json.Unmarshal([]byte(cached), &result) == nil {
 			slog.Debug("cache hit", "key", cacheKey)
-			return &result, nil
+			return result, nil
 		}
 	}
 
-	// Query from database
-	result, err := s.repo.ListMovies(params)
+	exists, err := s.repo.MovieExists(ctx, id)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list movies: %w", err)
+		return nil, fmt.Errorf("failed to check movie: %w", err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("movie not found")
+	}
+
+	result, err := s.repo.GetSimilarMovies(ctx, id, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get similar movies: %w", err)
 	}
 
 	// Store in cache
 	if data, err := json.Marshal(result); err == nil {
-		s.setCache(cacheKey, string(data), movieListCacheTTL)
+		s.setCache(ctx, cacheKey, string(data), movieListCacheTTL)
 	}
 
 	return result, nil
 }
 
-// GetMovieDetail returns detailed movie info by ID.
-func (s *MovieService) GetMovieDetail(id int) (*models.MovieDetail, error) {
-	// Try Redis cache
+// GetMoviesByIDs returns details for several movies in one query, in the
+// order requested. Unknown IDs are skipped.
+func (s *MovieService) GetMoviesByIDs(ctx context.Context, ids []int) ([]models.MovieDetail, error) {
+	movies, err := s.repo.GetMoviesByIDs(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get movies: %w", err)
+	}
+	return movies, nil
+}
+
+// GetMovieDetail returns detailed movie info by ID. With refresh, the cached
+// detail is skipped and overwritten with the database copy.
+func (s *MovieService) GetMovieDetail(ctx context.Context, id int, refresh bool) (*models.MovieDetail, error) {
+	// Try Redis cache unless asked to refresh
 	cacheKey := fmt.Sprintf("movie:detail:%d", id)
 
-	if cached, err := s.getFromCache(cacheKey); err == nil {
-		var result models.MovieDetail
-		if json.Unmarshal([]byte(cached), &result) == nil {
-			slog.Debug("cache hit", "key", cacheKey)
-			return &result, nil
+	if !refresh {
+		if cached, err := s.getFromCache(ctx, cacheKey); err == nil {
+			var result models.MovieDetail
+			if json.Unmarshal([]byte(cached), &result) == nil {
+				slog.Debug("cache hit", "key", cacheKey)
+				return &result, nil
+			}
+		}
+	}
+
+	// Query from database, once for all concurrent misses on this movie
+	v, err := s.shared(ctx, cacheKey, func(ctx context.Context) (any, error) {
+		detail, err := s.repo.GetMovieByID(ctx, id)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return nil, fmt.Errorf("movie not found")
+			}
+			return nil, fmt.Errorf("failed to get movie: %w", err)
+		}
+
+		// Store in cache
+		if data, err := json.Marshal(detail); err == nil {
+			s.setCache(ctx, cacheKey, string(data), s.detailCacheTTL(detail.Popularity))
+		}
+		return detail, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Callers sharing the query each get their own copy, since
+	// GetMovieDetailWithCast sets Cast on it
+	detail := *v.(*models.MovieDetail)
+	return &detail, nil
+}
+
+// GetMovieDetailWithCast returns a movie's detail with its top-billed cast,
+// as the detail endpoints serve it. The detail is still useful without its
+// cast, so a failed cast lookup is logged and only leaves it out. With
+// refresh, both cached entries are skipped and overwritten.
+func (s *MovieService) GetMovieDetailWithCast(ctx context.Context, id int, refresh bool) (*models.MovieDetail, error) {
+	detail, err := s.GetMovieDetail(ctx, id, refresh)
+	if err != nil {
+		return nil, err
+	}
+
+	cast, err := s.GetMovieCast(ctx, id, detail.Popularity, refresh)
+	if err != nil {
+		slog.Error("failed to get movie cast", "id", id, "error", err)
+		return detail, nil
+	}
+	detail.Cast = cast
+	return detail, nil
+}
+
+// MovieExists reports whether a movie exists. A cached detail answers
+// without touching the database; otherwise only an existence check runs.
+func (s *MovieService) MovieExists(ctx context.Context, id int) (bool, error) {
+	if _, err := s.getFromCache(ctx, fmt.Sprintf("movie:detail:%d", id)); err == nil {
+		return true, nil
+	}
+	exists, err := s.repo.MovieExists(ctx, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to check movie: %w", err)
+	}
+	return exists, nil
+}
+
+// GetMovieCast returns a movie's top-billed cast. Cast is cached alongside
+// the detail, with the same TTL tier. With refresh, the cached cast is
+// skipped and overwritten.
+func (s *MovieService) GetMovieCast(ctx context.Context, id int, popularity float64, refresh bool) ([]models.CastMember, error) {
+	cacheKey := fmt.Sprintf("movie:cast:%d", id)
+
+	if !refresh {
+		if cached, err := s.getFromCache(ctx, cacheKey); err == nil {
+			var cast []models.CastMember
+			if json.Unmarshal([]byte(cached), &cast) == nil {
+				slog.Debug("cache hit", "key", cacheKey)
+				return cast, nil
+			}
+		}
+	}
+
+	cast, err := s.repo.GetMovieCast(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cast: %w", err)
+	}
+
+	if data, err := json.Marshal(cast); err == nil {
+		s.setCache(ctx, cacheKey, string(data), s.detailCacheTTL(popularity))
+	}
+
+	return cast, nil
+}
+
+// detailCacheTTL picks the cache tier for a movie detail: popular movies are
+// requested often enough to keep for the hot TTL, the long tail expires
+// sooner to save Redis memory.
+func (s *MovieService) detailCacheTTL(popularity float64) time.Duration {
+	if popularity >= s.cacheCfg.HotPopularity {
+		s.hotSets.Add(1)
+		return s.cacheCfg.HotTTL
+	}
+	s.coldSets.Add(1)
+	return s.cacheCfg.ColdTTL
+}
+
+// RefreshMovieDetail drops the cached detail and cast for one movie and
+// reloads them from the database. With fromTMDB, the movie and its genres
+// are re-synced from TMDB first.
+func (s *MovieService) RefreshMovieDetail(ctx context.Context, id int, fromTMDB bool) (*models.MovieDetail, error) {
+	if fromTMDB {
+		if err := s.resyncMovie(ctx, id); err != nil {
+			return nil, err
 		}
 	}
 
-	// Query from database
-	detail, err := s.repo.GetMovieByID(id)
+	s.delCache(ctx, fmt.Sprintf("movie:detail:%d", id))
+	s.delCache(ctx, fmt.Sprintf("movie:cast:%d", id))
+	return s.GetMovieDetailWithCast(ctx, id, false)
+}
+
+// resyncMovie refreshes one stored movie from TMDB.
+func (s *MovieService) resyncMovie(ctx context.Context, id int) error {
+	tmdbID, err := s.repo.GetTMDBIdByID(ctx, id)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("movie not found")
+			return fmt.Errorf("movie not found")
 		}
-		return nil, fmt.Errorf("failed to get movie: %w", err)
+		return fmt.Errorf("failed to get movie: %w", err)
 	}
 
-	// Store in cache
-	if data, err := json.Marshal(detail); err == nil {
-		s.setCache(cacheKey, string(data), movieDetailCacheTTL)
+	detail, err := s.tmdbClient.GetMovieDetail(ctx, tmdbID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch TMDB movie: %w", err)
 	}
 
-	return detail, nil
+	movieID, err := s.repo.UpsertMovie(ctx, movieFromDetail(detail))
+	if err != nil {
+		return fmt.Errorf("failed to upsert movie: %w", err)
+	}
+
+	// Clear existing genre links and re-create
+	_ = s.repo.ClearMovieGenres(ctx, movieID)
+	s.linkGenres(ctx, movieID, detail.Genres)
+	s.storeCast(ctx, movieID, detail.Credits)
+
+	slog.Info("movie re-synced from TMDB", "id", movieID, "tmdb_id", tmdbID)
+	return nil
 }
 
-// ---- Redis Helpers ----
+// movieFromDetail maps a TMDB movie detail to a stored movie.
+func movieFromDetail(detail *tmdb.TMDBMovieDetail) *models.Movie {
+	return &models.Movie{
+		TMDBId:           detail.ID,
+		Title:            detail.Title,
+		Overview:         detail.Overview,
+		ReleaseDate:      detail.ReleaseDate,
+		Popularity:       detail.Popularity,
+		PosterPath:       detail.PosterPath,
+		BackdropPath:     detail.BackdropPath,
+		OriginalLanguage: detail.OriginalLanguage,
+		Runtime:          detail.Runtime,
+		VoteAverage:      detail.VoteAverage,
+		VoteCount:        detail.VoteCount,
+	}
+}
 
-func (s *MovieService) getFromCache(key string) (string, error) {
-	if s.redis == nil {
-		return "", fmt.Errorf("redis not available")
+// RelinkGenres repairs movies left without genre links (e.g. by an
+// interrupted sync) by fetching their genres from TMDB. At most limit movies
+// are processed per call. It returns how many movies were checked and how
+// many got at least one genre linked.
+func (s *MovieService) RelinkGenres(ctx context.Context, limit int) (checked, relinked int, err error) {
+	movies, err := s.repo.GetMoviesWithoutGenres(ctx, limit)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to find movies without genres: %w", err)
+	}
+
+	for _, m := range movies {
+		detail, err := s.tmdbClient.GetMovieDetail(ctx, m.TMDBId)
+		if err != nil {
+			slog.Error("failed to fetch movie detail", "tmdb_id", m.TMDBId, "error", err)
+			continue
+		}
+		if s.linkGenres(ctx, m.ID, detail.Genres) > 0 {
+			relinked++
+		}
+		// Rate limit TMDB requests
+		time.Sleep(100 * time.Millisecond)
 	}
-	return s.redis.Get(context.Background(), key).Result()
+
+	if relinked > 0 {
+		s.invalidateCache(ctx)
+	}
+
+	slog.Info("genre relink completed", "checked", len(movies), "relinked", relinked)
+	return len(movies), relinked, nil
 }
 
-func (s *MovieService) setCache(key, value string, ttl time.Duration) {
-	if s.redis == nil {
+// linkGenres links a movie to the given TMDB genres, creating any genre not
+// stored yet, and returns the number of links made.
+func (s *MovieService) linkGenres(ctx context.Context, movieID int, genres []tmdb.TMDBGenre) int {
+	linked := 0
+	for _, g := range genres {
+		genreID, err := s.repo.UpsertGenre(ctx, g.ID, g.Name)
+		if err != nil {
+			slog.Error("failed to upsert genre", "genre", g.Name, "error", err)
+			continue
+		}
+		if err := s.repo.LinkMovieGenre(ctx, movieID, genreID); err != nil {
+			slog.Error("failed to link genre", "movie_id", movieID, "genre", g.Name, "error", err)
+			continue
+		}
+		linked++
+	}
+	return linked
+}
+
+// storeCast replaces a movie's stored cast with the top-billed members of
+// the given TMDB credits. Nil credits leave the stored cast untouched.
+func (s *MovieService) storeCast(ctx context.Context, movieID int, credits *tmdb.TMDBCredits) {
+	if credits == nil {
 		return
 	}
-	if err := s.redis.Set(context.Background(), key, value, ttl).Err(); err != nil {
-		slog.Error("failed to set cache", "key", key, "error", err)
+
+	cast := credits.Cast
+	sort.SliceStable(cast, func(i, j int) bool { return cast[i].Order < cast[j].Order })
+	if len(cast) > models.MaxCastMembers {
+		cast = cast[:models.MaxCastMembers]
 	}
-}
 
-func (s *MovieService) invalidateCache() {
-	if s.redis == nil {
+	if err := s.repo.ClearMovieCast(ctx, movieID); err != nil {
+		slog.Error("failed to clear cast", "movie_id", movieID, "error", err)
 		return
 	}
-	ctx := context.Background()
-	iter := s.redis.Scan(ctx, 0, "movies:*", 0).Iterator()
-	for iter.Next(ctx) {
-		s.redis.Del(ctx, iter.Val())
+	for i, member := range cast {
+		personID, err := s.repo.UpsertPerson(ctx, member.ID, member.Name, member.ProfilePath)
+		if err != nil {
+			slog.Error("failed to upsert person", "name", member.Name, "error", err)
+			continue
+		}
+		if err := s.repo.AddMovieCast(ctx, movieID, personID, member.Character, i); err != nil {
+			slog.Error("failed to link cast member", "movie_id", movieID, "name", member.Name, "error", err)
+		}
+	}
+	if err := s.repo.MarkCastSynced(ctx, movieID); err != nil {
+		slog.Error("failed to mark cast synced", "movie_id", movieID, "error", err)
+	}
+}
+
+// GetStats returns cache effectiveness and catalog size for ops dashboards.
+func (s *MovieService) GetStats(ctx context.Context) (*models.ServiceStats, error) {
+	count, lastSync, err := s.repo.GetCatalogStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &models.ServiceStats{
+		Service:    "movie-service",
+		Cache:      s.cache.Stats(),
+		MovieCount: count,
+		LastSyncAt: lastSync,
+		DetailTiers: models.DetailCacheTierStats{
+			HotPopularity:  s.cacheCfg.HotPopularity,
+			HotTTLSeconds:  int(s.cacheCfg.HotTTL.Seconds()),
+			ColdTTLSeconds: int(s.cacheCfg.ColdTTL.Seconds()),
+			HotSets:        s.hotSets.Load(),
+			ColdSets:       s.coldSets.Load(),
+		},
+	}, nil
+}
+
+// ---- Cache Helpers ----
+
+func (s *MovieService) getFromCache(ctx context.Context, key string) (string, error) {
+	val, err := s.cache.Get(ctx, key)
+	if err != nil {
+		metrics.CacheLookups.WithLabelValues("miss").Inc()
+		return "", err
+	}
+	metrics.CacheLookups.WithLabelValues("hit").Inc()
+	return val, nil
+}
+
+// shared runs fn once for all concurrent callers with the same key. fn runs
+// detached from the caller's cancellation, so one waiter giving up does not
+// fail the query for the others; each caller still returns as soon as its
+// own ctx is done.
+func (s *MovieService) shared(ctx context.Context, key string, fn func(context.Context) (any, error)) (any, error) {
+	detached := context.WithoutCancel(ctx)
+	ch := s.flight.DoChan(key, func() (any, error) {
+		return fn(detached)
+	})
+	select {
+	case res := <-ch:
+		return res.Val, res.Err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (s *MovieService) setCache(ctx context.Context, key, value string, ttl time.Duration) {
+	if err := s.cache.Set(ctx, key, value, ttl); err != nil {
+		slog.Error("failed to set cache", "key", key, "error", err)
 	}
-	iter2 := s.redis.Scan(ctx, 0, "movie:*", 0).Iterator()
-	for iter2.Next(ctx) {
-		s.redis.Del(ctx, iter2.Val())
+}
+
+func (s *MovieService) delCache(ctx context.Context, key string) {
+	if err := s.cache.Del(ctx, key); err != nil {
+		slog.Error("failed to delete cache", "key", key, "error", err)
+	}
+}
+
+func (s *MovieService) invalidateCache(ctx context.Context) {
+	for _, pattern := range []string{"movies:*", "movie:*", "genres:*"} {
+		if _, err := s.cache.Invalidate(ctx, pattern); err != nil {
+			slog.Error("failed to invalidate cache", "pattern", pattern, "error", err)
+		}
 	}
 	slog.Info("Redis cache invalidated")
 }
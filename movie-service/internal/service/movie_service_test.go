@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"movie-discovery-movie-service/internal/cache"
+	"movie-discovery-movie-service/internal/config"
+	"movie-discovery-movie-service/internal/models"
+	"movie-discovery-movie-service/internal/repository"
+)
+
+// countingStore is a MovieStore whose list and detail queries count their
+// calls and block until release is closed, so concurrent callers pile up
+// behind the first one. Other methods are not used by these tests.
+type countingStore struct {
+	repository.MovieStore
+	listCalls   atomic.Int32
+	detailCalls atomic.Int32
+	started     chan struct{}
+	startOnce   sync.Once
+	release     chan struct{}
+}
+
+func newCountingStore() *countingStore {
+	return &countingStore{started: make(chan struct{}), release: make(chan struct{})}
+}
+
+func (f *countingStore) wait() {
+	f.startOnce.Do(func() { close(f.started) })
+	<-f.release
+}
+
+func (f *countingStore) ListMovies(_ context.Context, params models.MovieListParams) (*models.MovieListResponse, error) {
+	f.listCalls.Add(1)
+	f.wait()
+	return &models.MovieListResponse{Page: params.Page, PageSize: params.PageSize, Data: []models.MovieListItem{{ID: 1}}}, nil
+}
+
+func (f *countingStore) GetMovieByID(_ context.Context, id int) (*models.MovieDetail, error) {
+	f.detailCalls.Add(1)
+	f.wait()
+	return &models.MovieDetail{ID: id, Genres: []string{}}, nil
+}
+
+const concurrentCallers = 100
+
+// runConcurrently calls fn from concurrentCallers goroutines, lets store
+// answer once they have had time to join the first query, and returns the
+// callers' errors.
+func runConcurrently(t *testing.T, store *countingStore, fn func() error) []error {
+	t.Helper()
+	begin := make(chan struct{})
+	errs := make([]error, concurrentCallers)
+	var wg sync.WaitGroup
+	for i := range concurrentCallers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-begin
+			errs[i] = fn()
+		}()
+	}
+	close(begin)
+
+	select {
+	case <-store.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("repository was never called")
+	}
+	// Give the remaining callers time to reach the in-flight query
+	time.Sleep(100 * time.Millisecond)
+	close(store.release)
+	wg.Wait()
+	return errs
+}
+
+func TestListMoviesCollapsesConcurrentMisses(t *testing.T) {
+	store := newCountingStore()
+	svc := NewMovieService(store, nil, cache.Noop{}, nil, config.CacheConfig{})
+	params := models.MovieListParams{Page: 1, PageSize: 20}
+
+	errs := runConcurrently(t, store, func() error {
+		result, err := svc.ListMovies(context.Background(), params)
+		if err == nil && len(result.Data) != 1 {
+			t.Errorf("got %d movies, want 1", len(result.Data))
+		}
+		return err
+	})
+
+	for _, err := range errs {
+		if err != nil {
+			t.Fatalf("ListMovies: %v", err)
+		}
+	}
+	if got := store.listCalls.Load(); got != 1 {
+		t.Errorf("repository ListMovies called %d times, want 1", got)
+	}
+}
+
+func TestGetMovieDetailCollapsesConcurrentMisses(t *testing.T) {
+	store := newCountingStore()
+	svc := NewMovieService(store, nil, cache.Noop{}, nil, config.CacheConfig{})
+
+	errs := runConcurrently(t, store, func() error {
+		detail, err := svc.GetMovieDetail(context.Background(), 42, false)
+		if err == nil && detail.ID != 42 {
+			t.Errorf("got movie %d, want 42", detail.ID)
+		}
+		return err
+	})
+
+	for _, err := range errs {
+		if err != nil {
+			t.Fatalf("GetMovieDetail: %v", err)
+		}
+	}
+	if got := store.detailCalls.Load(); got != 1 {
+		t.Errorf("repository GetMovieByID called %d times, want 1", got)
+	}
+}
+
+func TestSharedQuerySurvivesCallerCancellation(t *testing.T) {
+	store := newCountingStore()
+	svc := NewMovieService(store, nil, cache.Noop{}, nil, config.CacheConfig{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := svc.GetMovieDetail(ctx, 7, false)
+		first <- err
+	}()
+	<-store.started
+
+	second := make(chan error, 1)
+	go func() {
+		_, err := svc.GetMovieDetail(context.Background(), 7, false)
+		second <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	// The first caller leaves; the one still waiting must get its result
+	cancel()
+	if err := <-first; err != context.Canceled {
+		t.Errorf("cancelled caller got %v, want context.Canceled", err)
+	}
+	close(store.release)
+	if err := <-second; err != nil {
+		t.Errorf("waiting caller got %v, want nil", err)
+	}
+	if got := store.detailCalls.Load(); got != 1 {
+		t.Errorf("repository GetMovieByID called %d times, want 1", got)
+	}
+}
@@ -0,0 +1,173 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"movie-discovery-movie-service/internal/metrics"
+	"movie-discovery-movie-service/internal/models"
+	"movie-discovery-movie-service/internal/tmdb"
+)
+
+// syncJobTTL is how long a finished sync job's status stays in Redis.
+const syncJobTTL = 24 * time.Hour
+
+// maxTrackedSyncJobs bounds the sync jobs kept in memory.
+const maxTrackedSyncJobs = 20
+
+var (
+	// ErrSyncInProgress is returned when a sync is started while another
+	// one is still running.
+	ErrSyncInProgress = errors.New("a sync is already running")
+	// ErrSyncJobNotFound is returned for an unknown or expired job ID.
+	ErrSyncJobNotFound = errors.New("sync job not found")
+)
+
+// syncJobs tracks background sync jobs in memory, so their status is still
+// available when Redis is down. Only one sync runs at a time.
+type syncJobs struct {
+	mu     sync.Mutex
+	jobs   map[string]*models.SyncJob
+	order  []string
+	active string
+}
+
+func newSyncJobs() *syncJobs {
+	return &syncJobs{jobs: make(map[string]*models.SyncJob)}
+}
+
+// StartSync starts a sync of the given mode in the background and returns
+// the running job. Empty fields of opts use the configured discover
+// defaults. It fails with ErrSyncInProgress if a sync is running.
+func (s *MovieService) StartSync(ctx context.Context, mode string, pages int, opts tmdb.DiscoverOptions) (*models.SyncJob, error) {
+	id, err := newSyncJobID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create job ID: %w", err)
+	}
+
+	s.jobs.mu.Lock()
+	if s.jobs.active != "" {
+		s.jobs.mu.Unlock()
+		return nil, ErrSyncInProgress
+	}
+	s.jobs.active = id
+	s.jobs.mu.Unlock()
+
+	job := models.SyncJob{
+		ID:        id,
+		Mode:      mode,
+		State:     models.SyncJobRunning,
+		Pages:     pages,
+		Language:  opts.Language,
+		Region:    opts.Region,
+		SortBy:    opts.SortBy,
+		StartedAt: time.Now().UTC(),
+	}
+	s.saveSyncJob(ctx, job)
+
+	s.bg.run("sync-job", func(ctx context.Context) { s.runSyncJob(ctx, job) })
+
+	slog.Info("sync job started", "job_id", id, "mode", mode, "pages", pages,
+		"language", opts.Language, "region", opts.Region, "sort_by", opts.SortBy)
+	return &job, nil
+}
+
+// runSyncJob runs the sync for job, saving its progress after every page.
+// ctx is the service's background context, so the job outlives the request
+// that started it.
+func (s *MovieService) runSyncJob(ctx context.Context, job models.SyncJob) {
+	defer func() {
+		s.jobs.mu.Lock()
+		s.jobs.active = ""
+		s.jobs.mu.Unlock()
+	}()
+
+	progress := func(pagesDone, moviesSynced int) {
+		job.PagesDone = pagesDone
+		job.MoviesSynced = moviesSynced
+		s.saveSyncJob(ctx, job)
+	}
+
+	opts := tmdb.DiscoverOptions{Language: job.Language, Region: job.Region, SortBy: job.SortBy}
+	var err error
+	if job.Mode == models.SyncModeIncremental {
+		var result *models.SyncResult
+		if result, err = s.SyncMoviesIncremental(ctx, job.Pages, opts, progress); err == nil {
+			job.Result = result
+			job.MoviesSynced = result.Created + result.Updated
+		}
+	} else {
+		job.MoviesSynced, err = s.SyncMovies(ctx, job.Pages, opts, progress)
+	}
+
+	finishedAt := time.Now().UTC()
+	job.FinishedAt = &finishedAt
+	if err != nil {
+		job.State = models.SyncJobFailed
+		job.Error = err.Error()
+		metrics.SyncRuns.WithLabelValues(job.Mode, "failure").Inc()
+		slog.Error("sync job failed", "job_id", job.ID, "error", err)
+	} else {
+		job.State = models.SyncJobCompleted
+		metrics.SyncRuns.WithLabelValues(job.Mode, "success").Inc()
+		slog.Info("sync job completed", "job_id", job.ID, "movies_synced", job.MoviesSynced)
+	}
+	s.saveSyncJob(ctx, job)
+}
+
+// GetSyncJob returns the status of a sync job, from memory or, for a job
+// started by another instance, from Redis.
+func (s *MovieService) GetSyncJob(ctx context.Context, id string) (*models.SyncJob, error) {
+	s.jobs.mu.Lock()
+	job, ok := s.jobs.jobs[id]
+	s.jobs.mu.Unlock()
+	if ok {
+		return job, nil
+	}
+
+	if cached, err := s.getFromCache(ctx, syncJobKey(id)); err == nil {
+		var job models.SyncJob
+		if json.Unmarshal([]byte(cached), &job) == nil {
+			return &job, nil
+		}
+	}
+	return nil, ErrSyncJobNotFound
+}
+
+// saveSyncJob stores a snapshot of job in memory and in Redis.
+func (s *MovieService) saveSyncJob(ctx context.Context, job models.SyncJob) {
+	s.jobs.mu.Lock()
+	if _, ok := s.jobs.jobs[job.ID]; !ok {
+		s.jobs.order = append(s.jobs.order, job.ID)
+		// Forget the oldest jobs; the running one is always the newest
+		for len(s.jobs.order) > maxTrackedSyncJobs {
+			delete(s.jobs.jobs, s.jobs.order[0])
+			s.jobs.order = s.jobs.order[1:]
+		}
+	}
+	s.jobs.jobs[job.ID] = &job
+	s.jobs.mu.Unlock()
+
+	if data, err := json.Marshal(job); err == nil {
+		s.setCache(ctx, syncJobKey(job.ID), string(data), syncJobTTL)
+	}
+}
+
+func syncJobKey(id string) string {
+	return "sync:job:" + id
+}
+
+func newSyncJobID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
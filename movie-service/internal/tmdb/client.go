@@ -1,40 +1,180 @@
 package tmdb
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log/slog"
+	"math/rand/v2"
 	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 )
 
 // Client is the TMDB API client.
 type Client struct {
-	apiKey  string
-	baseURL string
-	http    *http.Client
+	apiKey         string
+	baseURL        string
+	http           *http.Client
+	maxRetries     int
+	retryBaseDelay time.Duration
+	discover       DiscoverOptions
+}
+
+// DefaultTimeout is the request timeout used when none is configured.
+const DefaultTimeout = 15 * time.Second
+
+// Retry defaults used when none are configured. A 429 or 5xx response, or a
+// failed request, is retried with exponential backoff and jitter.
+const (
+	DefaultMaxRetries     = 3
+	DefaultRetryBaseDelay = 500 * time.Millisecond
+)
+
+// DefaultSortBy is the discover order used when none is configured.
+const DefaultSortBy = "popularity.desc"
+
+// DiscoverOptions localizes and orders the discover endpoint. Empty fields
+// fall back to the client's defaults.
+type DiscoverOptions struct {
+	// Language is an ISO 639-1 code, optionally with a region (en, pt-BR),
+	// for titles and overviews.
+	Language string
+	// Region is an ISO 3166-1 code (US, MY) for release dates.
+	Region string
+	// SortBy is a TMDB sort field and direction, e.g. popularity.desc.
+	SortBy string
+}
+
+var (
+	languagePattern = regexp.MustCompile(`^[a-z]{2}(-[A-Z]{2})?$`)
+	regionPattern   = regexp.MustCompile(`^[A-Z]{2}$`)
+)
+
+// sortFields are the discover sort fields TMDB accepts.
+var sortFields = map[string]bool{
+	"popularity": true, "revenue": true, "primary_release_date": true,
+	"vote_average": true, "vote_count": true, "title": true, "original_title": true,
+}
+
+// Validate reports the first malformed field, if any.
+func (o DiscoverOptions) Validate() error {
+	if o.Language != "" && !languagePattern.MatchString(o.Language) {
+		return fmt.Errorf("invalid language %q, expected e.g. en or pt-BR", o.Language)
+	}
+	if o.Region != "" && !regionPattern.MatchString(o.Region) {
+		return fmt.Errorf("invalid region %q, expected e.g. US", o.Region)
+	}
+	if o.SortBy != "" {
+		field, dir, ok := strings.Cut(o.SortBy, ".")
+		if !ok || !sortFields[field] || (dir != "asc" && dir != "desc") {
+			return fmt.Errorf("invalid sort_by %q, expected e.g. popularity.desc", o.SortBy)
+		}
+	}
+	return nil
+}
+
+// or returns o with its empty fields taken from defaults.
+func (o DiscoverOptions) or(defaults DiscoverOptions) DiscoverOptions {
+	if o.Language == "" {
+		o.Language = defaults.Language
+	}
+	if o.Region == "" {
+		o.Region = defaults.Region
+	}
+	if o.SortBy == "" {
+		o.SortBy = defaults.SortBy
+	}
+	return o
+}
+
+// maxRetryDelay caps a single wait, including one asked for by Retry-After.
+const maxRetryDelay = 30 * time.Second
+
+// StatusError is returned when TMDB answers with a non-200 status, after
+// any retries were exhausted.
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("TMDB API returned status %d: %s", e.StatusCode, e.Body)
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithTimeout sets the timeout of the client's HTTP requests.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		if timeout > 0 {
+			c.http.Timeout = timeout
+		}
+	}
+}
+
+// WithRetry sets how many times a failed request is retried and the base
+// delay of the exponential backoff. A maxRetries of zero disables retries.
+func WithRetry(maxRetries int, baseDelay time.Duration) Option {
+	return func(c *Client) {
+		if maxRetries >= 0 {
+			c.maxRetries = maxRetries
+		}
+		if baseDelay > 0 {
+			c.retryBaseDelay = baseDelay
+		}
+	}
+}
+
+// WithHTTPClient replaces the underlying HTTP client, e.g. with one backed
+// by an httptest server or a stub RoundTripper.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		if httpClient != nil {
+			c.http = httpClient
+		}
+	}
+}
+
+// WithDiscoverDefaults sets the discover options used when a sync does not
+// override them. Empty fields keep the built-in defaults.
+func WithDiscoverDefaults(opts DiscoverOptions) Option {
+	return func(c *Client) {
+		c.discover = opts.or(c.discover)
+	}
 }
 
 // NewClient creates a new TMDB API client.
-func NewClient(apiKey, baseURL string) *Client {
-	return &Client{
+func NewClient(apiKey, baseURL string, opts ...Option) *Client {
+	c := &Client{
 		apiKey:  apiKey,
 		baseURL: baseURL,
 		http: &http.Client{
-			Timeout: 15 * time.Second,
+			Timeout: DefaultTimeout,
 		},
+		maxRetries:     DefaultMaxRetries,
+		retryBaseDelay: DefaultRetryBaseDelay,
+		discover:       DiscoverOptions{SortBy: DefaultSortBy},
+	}
+	for _, opt := range opts {
+		opt(c)
 	}
+	return c
 }
 
 // ---- TMDB Response Types (internal, not exposed to consumers) ----
 
 // DiscoverResponse is the TMDB discover/movie response.
 type DiscoverResponse struct {
-	Page         int           `json:"page"`
-	Results      []TMDBMovie   `json:"results"`
-	TotalPages   int           `json:"total_pages"`
-	TotalResults int           `json:"total_results"`
+	Page         int         `json:"page"`
+	Results      []TMDBMovie `json:"results"`
+	TotalPages   int         `json:"total_pages"`
+	TotalResults int         `json:"total_results"`
 }
 
 // TMDBMovie is a movie from TMDB discover results.
@@ -48,6 +188,8 @@ type TMDBMovie struct {
 	BackdropPath     string  `json:"backdrop_path"`
 	GenreIDs         []int   `json:"genre_ids"`
 	OriginalLanguage string  `json:"original_language"`
+	VoteAverage      float64 `json:"vote_average"`
+	VoteCount        int     `json:"vote_count"`
 }
 
 // TMDBMovieDetail is the detailed movie info from TMDB.
@@ -62,6 +204,24 @@ type TMDBMovieDetail struct {
 	Genres           []TMDBGenre `json:"genres"`
 	OriginalLanguage string      `json:"original_language"`
 	Runtime          int         `json:"runtime"`
+	VoteAverage      float64     `json:"vote_average"`
+	VoteCount        int         `json:"vote_count"`
+	// Credits is only present when requested via append_to_response.
+	Credits *TMDBCredits `json:"credits,omitempty"`
+}
+
+// TMDBCredits is the movie credits block from TMDB.
+type TMDBCredits struct {
+	Cast []TMDBCastMember `json:"cast"`
+}
+
+// TMDBCastMember is one billed cast member; Order is the billing position.
+type TMDBCastMember struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	Character   string `json:"character"`
+	Order       int    `json:"order"`
+	ProfilePath string `json:"profile_path"`
 }
 
 // TMDBGenre is a genre from TMDB.
@@ -75,17 +235,36 @@ type GenreListResponse struct {
 	Genres []TMDBGenre `json:"genres"`
 }
 
+// ChangesResponse is the TMDB movie/changes response: IDs of movies edited
+// within a date range.
+type ChangesResponse struct {
+	Page    int `json:"page"`
+	Results []struct {
+		ID int `json:"id"`
+	} `json:"results"`
+	TotalPages int `json:"total_pages"`
+}
+
 // ---- Client Methods ----
 
-// DiscoverMovies fetches movies from the TMDB discover endpoint.
-func (c *Client) DiscoverMovies(page int) (*DiscoverResponse, error) {
-	url := fmt.Sprintf(
-		"%s/discover/movie?api_key=%s&sort_by=popularity.desc&page=%d",
-		c.baseURL, c.apiKey, page,
-	)
+// DiscoverMovies fetches movies from the TMDB discover endpoint. Empty
+// fields of opts use the client's defaults.
+func (c *Client) DiscoverMovies(ctx context.Context, page int, opts DiscoverOptions) (*DiscoverResponse, error) {
+	opts = opts.or(c.discover)
+	query := url.Values{}
+	query.Set("api_key", c.apiKey)
+	query.Set("sort_by", opts.SortBy)
+	query.Set("page", strconv.Itoa(page))
+	if opts.Language != "" {
+		query.Set("language", opts.Language)
+	}
+	if opts.Region != "" {
+		query.Set("region", opts.Region)
+	}
 
-	slog.Debug("fetching TMDB discover", "url", url)
-	resp, err := c.doGet(url)
+	slog.Debug("fetching TMDB discover", "page", page, "language", opts.Language,
+		"region", opts.Region, "sort_by", opts.SortBy)
+	resp, err := c.doGet(ctx, c.baseURL+"/discover/movie?"+query.Encode())
 	if err != nil {
 		return nil, err
 	}
@@ -98,15 +277,16 @@ func (c *Client) DiscoverMovies(page int) (*DiscoverResponse, error) {
 	return &result, nil
 }
 
-// GetMovieDetail fetches detailed movie info from TMDB.
-func (c *Client) GetMovieDetail(tmdbID int) (*TMDBMovieDetail, error) {
+// GetMovieDetail fetches detailed movie info from TMDB, including its
+// credits in the same request.
+func (c *Client) GetMovieDetail(ctx context.Context, tmdbID int) (*TMDBMovieDetail, error) {
 	url := fmt.Sprintf(
-		"%s/movie/%d?api_key=%s",
+		"%s/movie/%d?api_key=%s&append_to_response=credits",
 		c.baseURL, tmdbID, c.apiKey,
 	)
 
 	slog.Debug("fetching TMDB movie detail", "tmdb_id", tmdbID)
-	resp, err := c.doGet(url)
+	resp, err := c.doGet(ctx, url)
 	if err != nil {
 		return nil, err
 	}
@@ -119,15 +299,37 @@ func (c *Client) GetMovieDetail(tmdbID int) (*TMDBMovieDetail, error) {
 	return &result, nil
 }
 
+// GetMovieChanges fetches one page of IDs of movies changed on TMDB between
+// start and end. TMDB accepts ranges of at most 14 days.
+func (c *Client) GetMovieChanges(ctx context.Context, start, end time.Time, page int) (*ChangesResponse, error) {
+	url := fmt.Sprintf(
+		"%s/movie/changes?api_key=%s&start_date=%s&end_date=%s&page=%d",
+		c.baseURL, c.apiKey, start.UTC().Format("2006-01-02"), end.UTC().Format("2006-01-02"), page,
+	)
+
+	slog.Debug("fetching TMDB movie changes", "page", page)
+	resp, err := c.doGet(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result ChangesResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode changes response: %w", err)
+	}
+	return &result, nil
+}
+
 // GetGenres fetches all movie genres from TMDB.
-func (c *Client) GetGenres() ([]TMDBGenre, error) {
+func (c *Client) GetGenres(ctx context.Context) ([]TMDBGenre, error) {
 	url := fmt.Sprintf(
 		"%s/genre/movie/list?api_key=%s",
 		c.baseURL, c.apiKey,
 	)
 
 	slog.Debug("fetching TMDB genres")
-	resp, err := c.doGet(url)
+	resp, err := c.doGet(ctx, url)
 	if err != nil {
 		return nil, err
 	}
@@ -140,15 +342,79 @@ func (c *Client) GetGenres() ([]TMDBGenre, error) {
 	return result.Genres, nil
 }
 
-func (c *Client) doGet(url string) (*http.Response, error) {
-	resp, err := c.http.Get(url)
-	if err != nil {
-		return nil, fmt.Errorf("HTTP request failed: %w", err)
-	}
-	if resp.StatusCode != http.StatusOK {
+// doGet performs a GET, retrying failed requests, 429 and 5xx responses up
+// to maxRetries times. The last failure is returned, wrapped with the number
+// of attempts made; a done ctx ends the request or retry wait at once.
+func (c *Client) doGet(ctx context.Context, url string) (*http.Response, error) {
+	var lastErr error
+	for attempt := 0; attempt <= c.maxRetries; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := c.http.Do(req)
+		if err != nil {
+			lastErr = fmt.Errorf("HTTP request failed: %w", err)
+			if err := c.waitBeforeRetry(ctx, attempt, "", lastErr); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if resp.StatusCode == http.StatusOK {
+			return resp, nil
+		}
+
 		body, _ := io.ReadAll(resp.Body)
 		resp.Body.Close()
-		return nil, fmt.Errorf("TMDB API returned status %d: %s", resp.StatusCode, string(body))
+		lastErr = &StatusError{StatusCode: resp.StatusCode, Body: string(body)}
+		if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < http.StatusInternalServerError {
+			return nil, lastErr
+		}
+		if err := c.waitBeforeRetry(ctx, attempt, resp.Header.Get("Retry-After"), lastErr); err != nil {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("giving up after %d attempts: %w", c.maxRetries+1, lastErr)
+}
+
+// waitBeforeRetry sleeps before the next attempt, unless attempt was the
+// last one. The wait honors Retry-After when TMDB sends it, otherwise it is
+// retryBaseDelay doubled per attempt plus up to 50% jitter. It returns
+// ctx's error if ctx is done first.
+func (c *Client) waitBeforeRetry(ctx context.Context, attempt int, retryAfter string, err error) error {
+	if attempt >= c.maxRetries {
+		return nil
+	}
+
+	delay := c.retryBaseDelay << attempt
+	delay += time.Duration(rand.Int64N(int64(delay)/2 + 1))
+	if d, ok := parseRetryAfter(retryAfter); ok {
+		delay = d
+	}
+	delay = min(delay, maxRetryDelay)
+
+	slog.Warn("TMDB request failed, retrying", "attempt", attempt+1, "delay", delay, "error", err)
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("retry wait interrupted: %w", ctx.Err())
+	}
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an
+// HTTP date.
+func parseRetryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0), true
 	}
-	return resp, nil
+	return 0, false
 }
@@ -0,0 +1,121 @@
+package tmdb
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestClient returns a client for srv with fast retries.
+func newTestClient(srv *httptest.Server, maxRetries int) *Client {
+	return NewClient("key", srv.URL,
+		WithHTTPClient(srv.Client()),
+		WithRetry(maxRetries, time.Millisecond),
+	)
+}
+
+func TestGetRetriesRateLimitedRequests(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= 2 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`{"genres":[{"id":28,"name":"Action"}]}`))
+	}))
+	defer srv.Close()
+
+	genres, err := newTestClient(srv, 3).GetGenres(context.Background())
+	if err != nil {
+		t.Fatalf("GetGenres: %v", err)
+	}
+	if len(genres) != 1 || genres[0].Name != "Action" {
+		t.Errorf("got genres %+v, want [Action]", genres)
+	}
+	if got := calls.Load(); got != 3 {
+		t.Errorf("server called %d times, want 3", got)
+	}
+}
+
+func TestGetWrapsStatusErrorWhenRetriesRunOut(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	_, err := newTestClient(srv, 2).GetGenres(context.Background())
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		t.Fatalf("got error %v, want one wrapping *StatusError", err)
+	}
+	if statusErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("got status %d, want 503", statusErr.StatusCode)
+	}
+	if got := calls.Load(); got != 3 {
+		t.Errorf("server called %d times, want 3 (1 + 2 retries)", got)
+	}
+}
+
+func TestGetDoesNotRetryClientErrors(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		http.Error(w, "not found", http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	_, err := newTestClient(srv, 3).GetMovieDetail(context.Background(), 1)
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusNotFound {
+		t.Fatalf("got error %v, want a 404 *StatusError", err)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("server called %d times, want 1", got)
+	}
+}
+
+func TestRetryWaitStopsWhenContextIsDone(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := newTestClient(srv, 3).GetGenres(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got error %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("returned after %v, want well before the 30s Retry-After", elapsed)
+	}
+}
+
+func TestDiscoverEscapesOptions(t *testing.T) {
+	var query string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		w.Write([]byte(`{"page":1,"results":[],"total_pages":1}`))
+	}))
+	defer srv.Close()
+
+	c := NewClient("a&b", srv.URL, WithHTTPClient(srv.Client()),
+		WithDiscoverDefaults(DiscoverOptions{Language: "pt-BR"}))
+	if _, err := c.DiscoverMovies(context.Background(), 2, DiscoverOptions{Region: "MY"}); err != nil {
+		t.Fatalf("DiscoverMovies: %v", err)
+	}
+	want := "api_key=a%26b&language=pt-BR&page=2&region=MY&sort_by=popularity.desc"
+	if query != want {
+		t.Errorf("got query %q, want %q", query, want)
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"log/slog"
 	"os"
 	"os/signal"
@@ -12,15 +13,18 @@ import (
 	"github.com/gofiber/fiber/v3/middleware/logger"
 	"github.com/gofiber/fiber/v3/middleware/recover"
 
+	"movie-discovery-recommendation-service/internal/cache"
 	"movie-discovery-recommendation-service/internal/config"
 	"movie-discovery-recommendation-service/internal/database"
 	"movie-discovery-recommendation-service/internal/handler"
+	"movie-discovery-recommendation-service/internal/metrics"
 	"movie-discovery-recommendation-service/internal/repository"
+	"movie-discovery-recommendation-service/internal/requestid"
 	"movie-discovery-recommendation-service/internal/service"
 )
 
 func main() {
-	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	slog.SetDefault(slog.New(requestid.NewLogHandler(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))))
 
 	cfg, err := config.Load()
 	if err != nil {
@@ -44,8 +48,9 @@ func main() {
 
 	// Initialize layers
 	repo := repository.NewRecommendationRepository(db)
-	svc := service.NewRecommendationService(repo, rdb, cfg.MovieServiceURL, cfg.UserPreferenceServiceURL)
-	h := handler.NewRecommendationHandler(svc)
+	svc := service.NewRecommendationService(repo, cache.New(rdb), cfg.MovieServiceURL, cfg.UserPreferenceServiceURL, cfg.Recommendation,
+		service.WithInternalToken(cfg.InternalAPIToken), service.WithUpstreamTimeout(cfg.UpstreamTimeout))
+	h := handler.NewRecommendationHandler(svc, cfg.AdminAPIToken)
 
 	// Load swagger spec
 	swaggerYAML, err := os.ReadFile("docs/swagger.yaml")
@@ -61,7 +66,9 @@ func main() {
 
 	// Middleware
 	app.Use(recover.New())
-	app.Use(logger.New())
+	app.Use(requestid.Middleware())
+	app.Use(metrics.Middleware())
+	app.Use(logger.New(logger.Config{Format: requestid.LogFormat}))
 	app.Use(cors.New())
 
 	// Swagger
@@ -69,11 +76,44 @@ func main() {
 		handler.RegisterSwagger(app, swaggerYAML)
 	}
 
+	// Prometheus metrics
+	app.Get(metrics.Path, metrics.Handler())
+
 	// Routes
 	api := app.Group("/api/v1")
 	api.Get("/health", h.Health)
+	readiness := handler.NewReadinessHandler(db, rdb, true).WithServices(map[string]string{
+		"movie-service":           cfg.MovieServiceURL,
+		"user-preference-service": cfg.UserPreferenceServiceURL,
+	})
+	api.Get("/health/ready", readiness.Ready)
+
+	// Kubernetes-style probes: liveness never checks dependencies,
+	// readiness pings them
+	app.Get("/healthz", h.Health)
+	app.Get("/readyz", readiness.Ready)
+
+	// With REQUIRE_INTERNAL_TOKEN every route registered below also needs
+	// the internal token; health, metrics and docs above stay open.
+	if cfg.RequireInternalToken {
+		app.Use(handler.RequireInternalToken(cfg.InternalAPIToken))
+	}
 	api.Get("/users/:id/recommendations", h.GetRecommendations)
+	api.Get("/users/:id/recommendations/history", h.GetRecommendationHistory)
+	api.Get("/users/:id/recommendations/:movieId/explain", h.ExplainRecommendation)
 	api.Get("/rules", h.GetRules)
+	requireAdmin := handler.RequireAdminToken(cfg.AdminAPIToken)
+	api.Post("/rules", requireAdmin, h.CreateRule)
+	api.Put("/rules/:id", requireAdmin, h.UpdateRule)
+	api.Delete("/rules/:id", requireAdmin, h.DeactivateRule)
+	api.Get("/admin/users/:id/effective-preferences", requireAdmin, h.GetEffectivePreferences)
+
+	// Internal service-to-service routes (not exposed via the gateway)
+	internal := app.Group("/internal", handler.RequireInternalToken(cfg.InternalAPIToken))
+	internal.Post("/users/:id/recommendations/warm", h.WarmRecommendations)
+	internal.Post("/recommendations/batch", h.BatchRecommendations)
+	internal.Post("/catalog-changed", h.CatalogChanged)
+	internal.Get("/stats", h.Stats)
 
 	// Graceful shutdown
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
@@ -89,8 +129,14 @@ func main() {
 	<-ctx.Done()
 	slog.Info("shutting down recommendation-service...")
 
-	// Shutdown HTTP server first (stop accepting new requests)
-	if err := app.Shutdown(); err != nil {
+	// Stop accepting new requests and drain in-flight ones, bounded by
+	// the shutdown timeout
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := app.ShutdownWithContext(shutdownCtx); errors.Is(err, context.DeadlineExceeded) {
+		slog.Warn("shutdown deadline reached with requests still in flight",
+			"timeout", cfg.ShutdownTimeout, "open_connections", app.Server().GetOpenConnectionsCount())
+	} else if err != nil {
 		slog.Error("error shutting down HTTP server", "error", err)
 	}
 	slog.Info("HTTP server stopped")
@@ -0,0 +1,126 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrMiss is returned by Get when the key is not cached.
+var ErrMiss = errors.New("cache miss")
+
+// invalidateBatchSize is the COUNT hint of each SCAN step in Invalidate,
+// and so roughly how many keys one UNLINK removes.
+const invalidateBatchSize = 500
+
+// Cache is the key/value store the services use for response caching.
+type Cache interface {
+	// Get returns the cached value for key, or ErrMiss.
+	Get(ctx context.Context, key string) (string, error)
+	Set(ctx context.Context, key, value string, ttl time.Duration) error
+	Del(ctx context.Context, keys ...string) error
+	// Invalidate deletes every key matching the glob pattern and returns
+	// how many were removed.
+	Invalidate(ctx context.Context, pattern string) (int, error)
+	// Stats reports lookups served since the process started.
+	Stats() Stats
+}
+
+// Stats summarizes cache effectiveness.
+type Stats struct {
+	Enabled  bool    `json:"enabled"`
+	Hits     uint64  `json:"hits"`
+	Misses   uint64  `json:"misses"`
+	HitRatio float64 `json:"hit_ratio"`
+}
+
+var (
+	_ Cache = (*Redis)(nil)
+	_ Cache = Noop{}
+)
+
+// New returns a Redis-backed Cache, or a Noop cache when rdb is nil.
+func New(rdb *redis.Client) Cache {
+	if rdb == nil {
+		return Noop{}
+	}
+	return &Redis{rdb: rdb}
+}
+
+// Redis is a Cache backed by a Redis client.
+type Redis struct {
+	rdb    *redis.Client
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+func (r *Redis) Get(ctx context.Context, key string) (string, error) {
+	val, err := r.rdb.Get(ctx, key).Result()
+	if err != nil {
+		r.misses.Add(1)
+		if errors.Is(err, redis.Nil) {
+			return "", ErrMiss
+		}
+		return "", err
+	}
+	r.hits.Add(1)
+	return val, nil
+}
+
+func (r *Redis) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	return r.rdb.Set(ctx, key, value, ttl).Err()
+}
+
+func (r *Redis) Del(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return r.rdb.Del(ctx, keys...).Err()
+}
+
+// Invalidate walks the keyspace with SCAN and removes each page of matches
+// with a single UNLINK, which frees the values in the background instead
+// of blocking Redis like DEL. A full SCAN returns every key that existed
+// throughout it, so nothing cached before the call survives.
+func (r *Redis) Invalidate(ctx context.Context, pattern string) (int, error) {
+	deleted := 0
+	var cursor uint64
+	for {
+		keys, next, err := r.rdb.Scan(ctx, cursor, pattern, invalidateBatchSize).Result()
+		if err != nil {
+			return deleted, err
+		}
+		if len(keys) > 0 {
+			n, err := r.rdb.Unlink(ctx, keys...).Result()
+			if err != nil {
+				return deleted, err
+			}
+			deleted += int(n)
+		}
+		if cursor = next; cursor == 0 {
+			return deleted, nil
+		}
+	}
+}
+
+func (r *Redis) Stats() Stats {
+	hits, misses := r.hits.Load(), r.misses.Load()
+	stats := Stats{Enabled: true, Hits: hits, Misses: misses}
+	if total := hits + misses; total > 0 {
+		stats.HitRatio = float64(hits) / float64(total)
+	}
+	return stats
+}
+
+// Noop is a Cache that stores nothing. It is used when Redis is not
+// configured, and in tests that should not depend on Redis.
+type Noop struct{}
+
+func (Noop) Get(context.Context, string) (string, error)              { return "", ErrMiss }
+func (Noop) Set(context.Context, string, string, time.Duration) error { return nil }
+func (Noop) Del(context.Context, ...string) error                     { return nil }
+func (Noop) Invalidate(context.Context, string) (int, error)          { return 0, nil }
+func (Noop) Stats() Stats                                             { return Stats{} }
@@ -3,17 +3,34 @@ package config
 import (
 	"fmt"
 	"os"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	DB                     DBConfig
-	Redis                  RedisConfig
-	Port                   string
-	MovieServiceURL        string
+	DB                       DBConfig
+	Redis                    RedisConfig
+	Port                     string
+	MovieServiceURL          string
 	UserPreferenceServiceURL string
+	// UpstreamTimeout bounds each call to the movie and user preference
+	// services.
+	UpstreamTimeout time.Duration
+	// InternalAPIToken is the shared secret required on /internal routes.
+	InternalAPIToken string
+	// RequireInternalToken extends the internal token check to every route
+	// except health, metrics and docs.
+	RequireInternalToken bool
+	// AdminAPIToken authorizes per-request admin overrides; empty disables them.
+	AdminAPIToken  string
+	Recommendation RecommendationConfig
+	// ShutdownTimeout bounds graceful shutdown, including draining
+	// in-flight requests.
+	ShutdownTimeout time.Duration
 }
 
 type DBConfig struct {
@@ -24,6 +41,11 @@ type DBConfig struct {
 	DBName      string
 	SSLMode     string
 	SSLRootCert string
+	// MaxOpenConns and MaxIdleConns size the connection pool;
+	// ConnMaxLifetime recycles connections after that long (0 = never).
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
 }
 
 func (d DBConfig) DSN() string {
@@ -43,21 +65,128 @@ type RedisConfig struct {
 	DB       int
 }
 
+// RecommendationConfig holds tunables for the scoring engine.
+type RecommendationConfig struct {
+	// InteractionHalfLife is the age at which an interaction counts half as
+	// much as one made today when deriving implicit preferences.
+	InteractionHalfLife time.Duration
+	// InferredGenreCount is how many genres are inferred from a user's
+	// positive interactions when they never set preferences; 0 disables
+	// inference.
+	InferredGenreCount int
+	// MinScore drops movies scoring below it, even if fewer than the
+	// requested number of recommendations remain.
+	MinScore float64
+	// BlockedGenres are excluded from recommendations regardless of user
+	// preferences, on every request when EnforceBlocklist is set and
+	// otherwise only for requests with family=true.
+	BlockedGenres    []string
+	EnforceBlocklist bool
+	// ActivityWindow and CacheTTLTiers pick how long a user's computed
+	// recommendations are cached from their number of interactions within
+	// the window, so active users get fresher feeds.
+	ActivityWindow time.Duration
+	CacheTTLTiers  []ActivityTTLTier
+	// CacheTTL, when set, caches every user's recommendations for that
+	// long instead of using the activity tiers.
+	CacheTTL time.Duration
+	// PopularityScale normalizes popularity linearly (PopularityScaleLinear)
+	// or by log(1+popularity) (PopularityScaleLog), which keeps a few
+	// blockbusters from flattening everything else to near zero.
+	PopularityScale string
+	// MaxSnapshotsPerUser caps the recommendation snapshots stored per
+	// generation; 0 disables snapshots. SnapshotGenerations is how many of
+	// a user's latest generations are kept.
+	MaxSnapshotsPerUser int
+	SnapshotGenerations int
+	// BatchConcurrency bounds how many users a batch request generates
+	// recommendations for at once.
+	BatchConcurrency int
+	// UnknownReleaseRecency is the 0–1 recency score given to movies with
+	// a missing or unparseable release date, instead of treating them as
+	// old.
+	UnknownReleaseRecency float64
+	// PoolPages is how many pages of 20 popular movies form the candidate
+	// pool when a request does not choose its own pool size.
+	PoolPages int
+	// DiversityLambda trades relevance (1) against genre variety (0) when
+	// a request asks for genre diversification.
+	DiversityLambda float64
+}
+
+// Popularity normalizations for RecommendationConfig.PopularityScale.
+const (
+	PopularityScaleLinear = "linear"
+	PopularityScaleLog    = "log"
+)
+
+// ActivityTTLTier caches recommendations for TTL when a user has at least
+// MinInteractions recent interactions.
+type ActivityTTLTier struct {
+	MinInteractions int
+	TTL             time.Duration
+}
+
 func Load() (*Config, error) {
 	_ = godotenv.Load()
 
 	dbPort, _ := strconv.Atoi(getEnv("DB_PORT", "5432"))
+	dbMaxOpen, _ := strconv.Atoi(getEnv("DB_MAX_OPEN_CONNS", "25"))
+	dbMaxIdle, _ := strconv.Atoi(getEnv("DB_MAX_IDLE_CONNS", "10"))
+	dbConnLifetime, err := time.ParseDuration(getEnv("DB_CONN_MAX_LIFETIME", "0"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid DB_CONN_MAX_LIFETIME: %w", err)
+	}
 	redisDB, _ := strconv.Atoi(getEnv("REDIS_DB", "2"))
+	halfLifeDays, _ := strconv.Atoi(getEnv("INTERACTION_HALF_LIFE_DAYS", "90"))
+	inferredGenres, _ := strconv.Atoi(getEnv("INFERRED_GENRE_COUNT", "3"))
+	minScore, _ := strconv.ParseFloat(getEnv("MIN_RECOMMENDATION_SCORE", "0"), 64)
+	enforceBlocklist, _ := strconv.ParseBool(getEnv("ENFORCE_GENRE_BLOCKLIST", "false"))
+	activityWindowDays, _ := strconv.Atoi(getEnv("ACTIVITY_WINDOW_DAYS", "7"))
+	batchConcurrency, _ := strconv.Atoi(getEnv("BATCH_RECOMMENDATION_CONCURRENCY", "4"))
+	maxSnapshots, _ := strconv.Atoi(getEnv("MAX_SNAPSHOTS_PER_USER", "50"))
+	snapshotGenerations, _ := strconv.Atoi(getEnv("SNAPSHOT_GENERATIONS", "5"))
+	poolPages, _ := strconv.Atoi(getEnv("RECO_POOL_PAGES", "3"))
+	if poolPages < 1 {
+		poolPages = 3
+	}
+	unknownRecency, err := strconv.ParseFloat(getEnv("UNKNOWN_RELEASE_RECENCY", "0.5"), 64)
+	if err != nil || unknownRecency < 0 || unknownRecency > 1 {
+		unknownRecency = 0.5
+	}
+	if snapshotGenerations < 1 {
+		snapshotGenerations = 1
+	}
+	diversityLambda, err := strconv.ParseFloat(getEnv("DIVERSITY_LAMBDA", "0.7"), 64)
+	if err != nil || diversityLambda < 0 || diversityLambda > 1 {
+		diversityLambda = 0.7
+	}
+	cacheTTLSeconds, err := strconv.Atoi(getEnv("RECO_CACHE_TTL_SECONDS", "0"))
+	if err != nil || cacheTTLSeconds < 0 {
+		cacheTTLSeconds = 0
+	}
+	upstreamTimeout, _ := strconv.Atoi(getEnv("UPSTREAM_TIMEOUT_SECONDS", "15"))
+	internalAPIToken := getEnv("INTERNAL_API_TOKEN", "")
+	requireInternalToken, _ := strconv.ParseBool(getEnv("REQUIRE_INTERNAL_TOKEN", "false"))
+
+	if requireInternalToken && internalAPIToken == "" {
+		return nil, fmt.Errorf("REQUIRE_INTERNAL_TOKEN is set but INTERNAL_API_TOKEN is empty")
+	}
+
+	shutdownTimeout, _ := strconv.Atoi(getEnv("SHUTDOWN_TIMEOUT_SECONDS", "15"))
 
 	return &Config{
 		DB: DBConfig{
-			Host:        getEnv("DB_HOST", "localhost"),
-			Port:        dbPort,
-			User:        getEnv("DB_USER", "postgres"),
-			Password:    getEnv("DB_PASSWORD", "postgres"),
-			DBName:      getEnv("DB_NAME", "recommendation_service"),
-			SSLMode:     getEnv("DB_SSLMODE", "verify-ca"),
-			SSLRootCert: getEnv("DB_SSLROOTCERT", ""),
+			Host:            getEnv("DB_HOST", "localhost"),
+			Port:            dbPort,
+			User:            getEnv("DB_USER", "postgres"),
+			Password:        getEnv("DB_PASSWORD", "postgres"),
+			DBName:          getEnv("DB_NAME", "recommendation_service"),
+			SSLMode:         getEnv("DB_SSLMODE", "verify-ca"),
+			SSLRootCert:     getEnv("DB_SSLROOTCERT", ""),
+			MaxOpenConns:    dbMaxOpen,
+			MaxIdleConns:    dbMaxIdle,
+			ConnMaxLifetime: dbConnLifetime,
 		},
 		Redis: RedisConfig{
 			Addr:     getEnv("REDIS_ADDR", "127.0.0.1:6379"),
@@ -65,8 +194,30 @@ func Load() (*Config, error) {
 			DB:       redisDB,
 		},
 		Port:                     getEnv("SERVER_PORT", "8083"),
+		ShutdownTimeout:          time.Duration(shutdownTimeout) * time.Second,
 		MovieServiceURL:          getEnv("MOVIE_SERVICE_URL", "http://localhost:8081"),
 		UserPreferenceServiceURL: getEnv("USER_PREFERENCE_SERVICE_URL", "http://localhost:8082"),
+		UpstreamTimeout:          time.Duration(upstreamTimeout) * time.Second,
+		InternalAPIToken:         internalAPIToken,
+		RequireInternalToken:     requireInternalToken,
+		AdminAPIToken:            getEnv("ADMIN_API_TOKEN", ""),
+		Recommendation: RecommendationConfig{
+			InteractionHalfLife:   time.Duration(halfLifeDays) * 24 * time.Hour,
+			InferredGenreCount:    inferredGenres,
+			MinScore:              minScore,
+			BlockedGenres:         SplitList(getEnv("BLOCKED_GENRES", "")),
+			EnforceBlocklist:      enforceBlocklist,
+			ActivityWindow:        time.Duration(activityWindowDays) * 24 * time.Hour,
+			CacheTTLTiers:         parseTTLTiers(getEnv("RECOMMENDATION_TTL_TIERS", "5:2,1:10,0:30")),
+			CacheTTL:              time.Duration(cacheTTLSeconds) * time.Second,
+			PopularityScale:       parsePopularityScale(getEnv("POPULARITY_SCALE", PopularityScaleLinear)),
+			MaxSnapshotsPerUser:   maxSnapshots,
+			SnapshotGenerations:   snapshotGenerations,
+			BatchConcurrency:      batchConcurrency,
+			PoolPages:             poolPages,
+			UnknownReleaseRecency: unknownRecency,
+			DiversityLambda:       diversityLambda,
+		},
 	}, nil
 }
 
@@ -76,3 +227,43 @@ func getEnv(key, fallback string) string {
 	}
 	return fallback
 }
+
+// SplitList parses a comma-separated list, dropping empty entries.
+func SplitList(v string) []string {
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parsePopularityScale returns the named popularity normalization, falling
+// back to linear for unknown values.
+func parsePopularityScale(v string) string {
+	if strings.EqualFold(strings.TrimSpace(v), PopularityScaleLog) {
+		return PopularityScaleLog
+	}
+	return PopularityScaleLinear
+}
+
+// parseTTLTiers parses comma-separated "min_interactions:ttl_minutes" pairs,
+// skipping malformed ones, and orders them from the most active tier down.
+func parseTTLTiers(v string) []ActivityTTLTier {
+	var tiers []ActivityTTLTier
+	for _, item := range SplitList(v) {
+		count, minutes, ok := strings.Cut(item, ":")
+		if !ok {
+			continue
+		}
+		n, err1 := strconv.Atoi(strings.TrimSpace(count))
+		m, err2 := strconv.Atoi(strings.TrimSpace(minutes))
+		if err1 != nil || err2 != nil || n < 0 || m <= 0 {
+			continue
+		}
+		tiers = append(tiers, ActivityTTLTier{MinInteractions: n, TTL: time.Duration(m) * time.Minute})
+	}
+	sort.Slice(tiers, func(i, j int) bool { return tiers[i].MinInteractions > tiers[j].MinInteractions })
+	return tiers
+}
@@ -20,8 +20,9 @@ func NewPostgres(cfg config.DBConfig) (*sql.DB, error) {
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(10)
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
 
 	slog.Info("connected to PostgreSQL", "db", cfg.DBName)
 
@@ -52,6 +53,10 @@ func runMigrations(db *sql.DB) error {
 		)`,
 		`CREATE INDEX IF NOT EXISTS idx_recommendations_user_id ON user_recommendation_snapshots(user_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_recommendations_score ON user_recommendation_snapshots(score DESC)`,
+		// Snapshots keep several generations per user, identified by
+		// generated_at, so a movie may appear once per generation
+		`ALTER TABLE user_recommendation_snapshots DROP CONSTRAINT IF EXISTS user_recommendation_snapshots_user_id_movie_id_key`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_recommendations_generation ON user_recommendation_snapshots(user_id, generated_at, movie_id)`,
 		// Seed default rules if none exist
 		`INSERT INTO recommendation_rules (name, weight, rule_type)
 		 SELECT 'Popularity Score', 0.4, 'popularity'
@@ -62,6 +67,12 @@ func runMigrations(db *sql.DB) error {
 		`INSERT INTO recommendation_rules (name, weight, rule_type)
 		 SELECT 'Genre Match', 0.3, 'genre_match'
 		 WHERE NOT EXISTS (SELECT 1 FROM recommendation_rules WHERE rule_type = 'genre_match')`,
+		`INSERT INTO recommendation_rules (name, weight, rule_type)
+		 SELECT 'Rating Score', 0.2, 'rating'
+		 WHERE NOT EXISTS (SELECT 1 FROM recommendation_rules WHERE rule_type = 'rating')`,
+		`INSERT INTO recommendation_rules (name, weight, rule_type)
+		 SELECT 'Language Match', 0.1, 'language_match'
+		 WHERE NOT EXISTS (SELECT 1 FROM recommendation_rules WHERE rule_type = 'language_match')`,
 	}
 
 	for _, m := range migrations {
@@ -0,0 +1,152 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// readinessCacheTTL lets rapid probes reuse a recent dependency check.
+	readinessCacheTTL = 2 * time.Second
+	// dependencyPingTimeout bounds each dependency ping.
+	dependencyPingTimeout = 1 * time.Second
+)
+
+// ReadinessStatus is the response body of the readiness check.
+type ReadinessStatus struct {
+	Status       string            `json:"status"`
+	Service      string            `json:"service"`
+	Dependencies map[string]string `json:"dependencies"`
+}
+
+// ReadinessHandler checks that PostgreSQL and Redis are reachable, and
+// optionally the services recommendations are generated from.
+type ReadinessHandler struct {
+	db            *sql.DB
+	rdb           *redis.Client
+	redisRequired bool
+	services      map[string]string
+	client        *http.Client
+
+	mu        sync.Mutex
+	checkedAt time.Time
+	last      ReadinessStatus
+}
+
+// NewReadinessHandler creates a new ReadinessHandler. When redisRequired is
+// false, an unreachable Redis is reported but does not fail readiness.
+func NewReadinessHandler(db *sql.DB, rdb *redis.Client, redisRequired bool) *ReadinessHandler {
+	return &ReadinessHandler{db: db, rdb: rdb, redisRequired: redisRequired}
+}
+
+// WithServices also probes the /api/v1/health endpoint of each service,
+// keyed by name. An unreachable service marks the status "degraded" but
+// keeps readiness, since stored snapshots can still be served.
+func (h *ReadinessHandler) WithServices(services map[string]string) *ReadinessHandler {
+	h.services = services
+	h.client = &http.Client{Timeout: dependencyPingTimeout}
+	return h
+}
+
+// Ready reports dependency status, returning 503 when a required
+// dependency is unreachable.
+func (h *ReadinessHandler) Ready(c fiber.Ctx) error {
+	status := h.check(c.Context())
+	if status.Status == "unavailable" {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(status)
+	}
+	return c.JSON(status)
+}
+
+// check pings dependencies, reusing the previous result within
+// readinessCacheTTL. Concurrent probes wait for the in-flight check.
+func (h *ReadinessHandler) check(ctx context.Context) ReadinessStatus {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !h.checkedAt.IsZero() && time.Since(h.checkedAt) < readinessCacheTTL {
+		return h.last
+	}
+
+	status := ReadinessStatus{
+		Status:       "ok",
+		Service:      "recommendation-service",
+		Dependencies: map[string]string{},
+	}
+
+	status.Dependencies["postgres"] = ping(ctx, h.db.PingContext)
+	if status.Dependencies["postgres"] != "up" {
+		status.Status = "unavailable"
+	}
+
+	if h.rdb == nil {
+		status.Dependencies["redis"] = "disabled"
+	} else {
+		status.Dependencies["redis"] = ping(ctx, func(ctx context.Context) error {
+			return h.rdb.Ping(ctx).Err()
+		})
+	}
+	if h.redisRequired && status.Dependencies["redis"] != "up" {
+		status.Status = "unavailable"
+	}
+
+	// Services are probed concurrently so one slow service does not add
+	// its timeout to the others
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	for name, baseURL := range h.services {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			state := ping(ctx, func(ctx context.Context) error {
+				return h.pingService(ctx, baseURL)
+			})
+			mu.Lock()
+			defer mu.Unlock()
+			status.Dependencies[name] = state
+			if state != "up" && status.Status == "ok" {
+				status.Status = "degraded"
+			}
+		}()
+	}
+	wg.Wait()
+
+	h.last = status
+	h.checkedAt = time.Now()
+	return status
+}
+
+// pingService calls a service's health endpoint, expecting a 200.
+func (h *ReadinessHandler) pingService(ctx context.Context, baseURL string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(baseURL, "/")+"/api/v1/health", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("health returned %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// ping runs fn with dependencyPingTimeout and reports "up" or "down".
+func ping(ctx context.Context, fn func(context.Context) error) string {
+	ctx, cancel := context.WithTimeout(ctx, dependencyPingTimeout)
+	defer cancel()
+	if err := fn(ctx); err != nil {
+		return "down"
+	}
+	return "up"
+}
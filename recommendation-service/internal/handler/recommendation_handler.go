@@ -1,19 +1,31 @@
 package handler
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log/slog"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v3"
 
+	"movie-discovery-recommendation-service/internal/config"
+	"movie-discovery-recommendation-service/internal/models"
 	"movie-discovery-recommendation-service/internal/service"
 )
 
+// warmTimeout bounds a background recommendation warm.
+const warmTimeout = 60 * time.Second
+
 type RecommendationHandler struct {
-	svc *service.RecommendationService
+	svc        *service.RecommendationService
+	adminToken string
 }
 
-func NewRecommendationHandler(svc *service.RecommendationService) *RecommendationHandler {
-	return &RecommendationHandler{svc: svc}
+func NewRecommendationHandler(svc *service.RecommendationService, adminToken string) *RecommendationHandler {
+	return &RecommendationHandler{svc: svc, adminToken: adminToken}
 }
 
 // Health godoc
@@ -40,23 +52,331 @@ func (h *RecommendationHandler) GetRecommendations(c fiber.Ctx) error {
 		limit = 10
 	}
 
-	resp, err := h.svc.GetRecommendations(c.Context(), userID, limit)
+	offset := fiber.Query(c, "offset", 0)
+	if offset < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid offset",
+		})
+	}
+
+	poolPages := fiber.Query(c, "pool", 0)
+	if poolPages < 0 || poolPages > models.MaxPoolPages {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("invalid pool, must be between 1 and %d", models.MaxPoolPages),
+		})
+	}
+
+	seen, err := parseSeenIDs(c.Query("seen"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	yearFrom := fiber.Query(c, "year_from", 0)
+	yearTo := fiber.Query(c, "year_to", 0)
+	if yearFrom < 0 || yearTo < 0 || (yearFrom > 0 && yearTo > 0 && yearFrom > yearTo) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid year range",
+		})
+	}
+
+	format := c.Query("format", models.FormatFull)
+	if format != models.FormatFull && format != models.FormatCompact {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid format, must be one of: full, compact",
+		})
+	}
+
+	diversifyBy := c.Query("diversify_by")
+	if diversifyBy != "" && diversifyBy != models.DiversifyByDecade {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid diversify_by, must be: decade",
+		})
+	}
+
+	displaySort := c.Query("display_sort", models.DisplaySortScore)
+	if displaySort != models.DisplaySortScore && displaySort != models.DisplaySortReleaseDate && displaySort != models.DisplaySortTitle {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid display_sort, must be one of: score, release_date, title",
+		})
+	}
+
+	params := models.RecommendationParams{
+		Limit:           limit,
+		Offset:          offset,
+		PoolPages:       poolPages,
+		Seen:            seen,
+		YearFrom:        yearFrom,
+		YearTo:          yearTo,
+		DiversifyBy:     diversifyBy,
+		Diversify:       fiber.Query(c, "diversify", false),
+		DisplaySort:     displaySort,
+		Family:          fiber.Query(c, "family", false),
+		IgnoreMinRating: !fiber.Query(c, "respect_min_rating", true),
+		OnlyNew:         fiber.Query(c, "only_new", false),
+	}
+
+	// Admins may replace the genre blocklist for a single request
+	if raw, ok := c.Queries()["blocked_genres"]; ok {
+		if !hasAdminToken(c, h.adminToken) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "blocked_genres override requires an admin token",
+			})
+		}
+		blocked := config.SplitList(raw)
+		params.BlockedGenresOverride = &blocked
+	}
+
+	// Admins may preview recommendations as computed right now; refresh is
+	// the same, spelled like the movie service's cache bypass
+	if fiber.Query(c, "fresh", false) || fiber.Query(c, "refresh", false) {
+		if !hasAdminToken(c, h.adminToken) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "fresh and refresh require an admin token",
+			})
+		}
+		params.Fresh = true
+		params.SkipCacheWrite = !fiber.Query(c, "write_cache", true)
+	}
+
+	resp, err := h.svc.GetRecommendations(c.Context(), userID, params)
 	if err != nil {
-		slog.Error("failed to generate recommendations", "user_id", userID, "error", err)
+		slog.ErrorContext(c.Context(), "failed to generate recommendations", "user_id", userID, "error", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "failed to generate recommendations",
 		})
 	}
 
+	if format == models.FormatCompact {
+		return c.JSON(resp.Compact())
+	}
+	return c.JSON(resp)
+}
+
+// BatchRecommendations godoc
+// POST /internal/recommendations/batch
+// Generates recommendations for several users, reporting each user's
+// success or failure.
+func (h *RecommendationHandler) BatchRecommendations(c fiber.Ctx) error {
+	var req models.BatchRecommendationRequest
+	if err := c.Bind().JSON(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	userIDs, err := parseBatchUserIDs(req.UserIDs)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	limit := req.Limit
+	if limit <= 0 || limit > 50 {
+		limit = 10
+	}
+
+	resp, err := h.svc.GetBatchRecommendations(c.Context(), userIDs, models.RecommendationParams{Limit: limit})
+	if err != nil {
+		slog.ErrorContext(c.Context(), "failed to generate batch recommendations", "users", len(userIDs), "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to generate recommendations",
+		})
+	}
 	return c.JSON(resp)
 }
 
+// parseBatchUserIDs validates the user IDs of a batch request, dropping
+// duplicates.
+func parseBatchUserIDs(raw []int) ([]int, error) {
+	if len(raw) == 0 {
+		return nil, errors.New("user_ids is required")
+	}
+	if len(raw) > models.MaxBatchUsers {
+		return nil, fmt.Errorf("user_ids accepts at most %d users", models.MaxBatchUsers)
+	}
+	ids := make([]int, 0, len(raw))
+	seen := make(map[int]bool, len(raw))
+	for _, id := range raw {
+		if id <= 0 {
+			return nil, fmt.Errorf("invalid user ID in user_ids: %d", id)
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// ExplainRecommendation godoc
+// GET /api/v1/users/:id/recommendations/:movieId/explain
+// Breaks a movie's score for the user down by rule.
+func (h *RecommendationHandler) ExplainRecommendation(c fiber.Ctx) error {
+	userID := fiber.Params[int](c, "id")
+	if userID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid user ID",
+		})
+	}
+	movieID := fiber.Params[int](c, "movieId")
+	if movieID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid movie ID",
+		})
+	}
+
+	explanation, err := h.svc.ExplainRecommendation(c.Context(), userID, movieID)
+	if errors.Is(err, service.ErrMovieNotInPool) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "movie is not a recommendation candidate",
+		})
+	}
+	if err != nil {
+		slog.ErrorContext(c.Context(), "failed to explain recommendation", "user_id", userID, "movie_id", movieID, "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to explain recommendation",
+		})
+	}
+	return c.JSON(explanation)
+}
+
+// GetRecommendationHistory godoc
+// GET /api/v1/users/:id/recommendations/history
+// Lists the user's stored recommendation generations, newest first.
+func (h *RecommendationHandler) GetRecommendationHistory(c fiber.Ctx) error {
+	userID := fiber.Params[int](c, "id")
+	if userID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid user ID",
+		})
+	}
+
+	history, err := h.svc.GetSnapshotHistory(userID, fiber.Query(c, "generations", 0))
+	if err != nil {
+		slog.ErrorContext(c.Context(), "failed to get recommendation history", "user_id", userID, "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to get recommendation history",
+		})
+	}
+	return c.JSON(history)
+}
+
+// GetEffectivePreferences godoc
+// GET /api/v1/admin/users/:id/effective-preferences
+func (h *RecommendationHandler) GetEffectivePreferences(c fiber.Ctx) error {
+	userID := fiber.Params[int](c, "id")
+	if userID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid user ID",
+		})
+	}
+
+	return c.JSON(h.svc.GetEffectivePreferences(c.Context(), userID))
+}
+
+// WarmRecommendations godoc
+// POST /internal/users/:id/recommendations/warm
+// Precomputes the user's default recommendations in the background.
+func (h *RecommendationHandler) WarmRecommendations(c fiber.Ctx) error {
+	userID := fiber.Params[int](c, "id")
+	if userID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid user ID",
+		})
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), warmTimeout)
+		defer cancel()
+		if err := h.svc.WarmRecommendations(ctx, userID); err != nil {
+			slog.Warn("failed to warm recommendations", "user_id", userID, "error", err)
+			return
+		}
+		slog.Info("recommendations warmed", "user_id", userID)
+	}()
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"message": "recommendation warm scheduled",
+		"user_id": userID,
+	})
+}
+
+// CatalogChanged godoc
+// POST /internal/catalog-changed
+// Invalidates the cached candidate pools after a catalog sync. Pass
+// ?recommendations=true to also drop every user's cached recommendations.
+func (h *RecommendationHandler) CatalogChanged(c fiber.Ctx) error {
+	includeRecommendations := fiber.Query(c, "recommendations", false)
+
+	var event models.CatalogEvent
+	if len(c.Body()) > 0 {
+		if err := c.Bind().JSON(&event); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "invalid event body",
+			})
+		}
+	}
+
+	deleted, err := h.svc.InvalidateCatalog(c.Context(), includeRecommendations)
+	if err != nil {
+		slog.ErrorContext(c.Context(), "failed to invalidate catalog caches", "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to invalidate caches",
+		})
+	}
+
+	slog.InfoContext(c.Context(), "catalog caches invalidated",
+		"event", event.Type,
+		"movies_synced", event.MoviesSynced,
+		"keys_deleted", deleted,
+	)
+
+	return c.JSON(fiber.Map{
+		"message":      "catalog caches invalidated",
+		"keys_deleted": deleted,
+	})
+}
+
+// parseSeenIDs parses a comma-separated list of movie IDs, dropping
+// duplicates.
+func parseSeenIDs(raw string) ([]int, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	parts := strings.Split(raw, ",")
+	if len(parts) > models.MaxSeenIDs {
+		return nil, fmt.Errorf("seen accepts at most %d movie IDs", models.MaxSeenIDs)
+	}
+	ids := make([]int, 0, len(parts))
+	seen := make(map[int]bool, len(parts))
+	for _, p := range parts {
+		id, err := strconv.Atoi(strings.TrimSpace(p))
+		if err != nil || id <= 0 {
+			return nil, fmt.Errorf("invalid movie ID in seen: %q", p)
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// Stats godoc
+// GET /internal/stats
+func (h *RecommendationHandler) Stats(c fiber.Ctx) error {
+	return c.JSON(h.svc.GetStats())
+}
+
 // GetRules godoc
 // GET /api/v1/rules
 func (h *RecommendationHandler) GetRules(c fiber.Ctx) error {
 	rules, err := h.svc.GetRules(c.Context())
 	if err != nil {
-		slog.Error("failed to fetch rules", "error", err)
+		slog.ErrorContext(c.Context(), "failed to fetch rules", "error", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "failed to fetch recommendation rules",
 		})
@@ -66,3 +386,79 @@ func (h *RecommendationHandler) GetRules(c fiber.Ctx) error {
 		"rules": rules,
 	})
 }
+
+// CreateRule godoc
+// POST /api/v1/rules
+func (h *RecommendationHandler) CreateRule(c fiber.Ctx) error {
+	var req models.RuleRequest
+	if err := c.Bind().JSON(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	rule, err := h.svc.CreateRule(c.Context(), req)
+	if err != nil {
+		return ruleError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(rule)
+}
+
+// UpdateRule godoc
+// PUT /api/v1/rules/:id
+func (h *RecommendationHandler) UpdateRule(c fiber.Ctx) error {
+	id := fiber.Params[int](c, "id")
+	if id <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid rule ID",
+		})
+	}
+
+	var req models.RuleRequest
+	if err := c.Bind().JSON(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	rule, err := h.svc.UpdateRule(c.Context(), id, req)
+	if err != nil {
+		return ruleError(c, err)
+	}
+	return c.JSON(rule)
+}
+
+// DeactivateRule godoc
+// DELETE /api/v1/rules/:id
+// Deactivates the rule rather than deleting it.
+func (h *RecommendationHandler) DeactivateRule(c fiber.Ctx) error {
+	id := fiber.Params[int](c, "id")
+	if id <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid rule ID",
+		})
+	}
+
+	if err := h.svc.DeactivateRule(c.Context(), id); err != nil {
+		return ruleError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// ruleError maps rule management errors to HTTP responses.
+func ruleError(c fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, service.ErrInvalidRule):
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, service.ErrRuleNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "rule not found",
+		})
+	}
+	slog.ErrorContext(c.Context(), "failed to manage rule", "error", err)
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error": "failed to save recommendation rule",
+	})
+}
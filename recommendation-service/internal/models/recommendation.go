@@ -1,6 +1,11 @@
 package models
 
-import "time"
+import (
+	"fmt"
+	"time"
+
+	"movie-discovery-recommendation-service/internal/cache"
+)
 
 // RecommendationRule defines a scoring rule.
 type RecommendationRule struct {
@@ -12,6 +17,25 @@ type RecommendationRule struct {
 	CreatedAt time.Time `json:"created_at"`
 }
 
+// RuleRequest is the request body for creating or replacing a rule.
+// IsActive is only honored on update; omitted, it keeps the current state.
+type RuleRequest struct {
+	Name     string  `json:"name"`
+	Weight   float64 `json:"weight"`
+	RuleType string  `json:"rule_type"`
+	IsActive *bool   `json:"is_active"`
+}
+
+// ValidRuleTypes are the rule types scoreMovies knows how to apply.
+var ValidRuleTypes = map[string]bool{
+	"popularity":           true,
+	"recency":              true,
+	"genre_match":          true,
+	"rating":               true,
+	"interaction_affinity": true,
+	"language_match":       true,
+}
+
 // RecommendationSnapshot stores a computed recommendation.
 type RecommendationSnapshot struct {
 	ID          int       `json:"id"`
@@ -21,6 +45,24 @@ type RecommendationSnapshot struct {
 	GeneratedAt time.Time `json:"generated_at"`
 }
 
+// SnapshotScore is one movie of a stored recommendation generation.
+type SnapshotScore struct {
+	MovieID int     `json:"movie_id"`
+	Score   float64 `json:"score"`
+}
+
+// SnapshotGeneration is one stored recommendation run of a user.
+type SnapshotGeneration struct {
+	GeneratedAt     time.Time       `json:"generated_at"`
+	Recommendations []SnapshotScore `json:"recommendations"`
+}
+
+// SnapshotHistoryResponse lists a user's stored generations, newest first.
+type SnapshotHistoryResponse struct {
+	UserID      int                  `json:"user_id"`
+	Generations []SnapshotGeneration `json:"generations"`
+}
+
 // MovieRecommendation is the response shape for a recommended movie.
 type MovieRecommendation struct {
 	ID          int      `json:"id"`
@@ -28,25 +70,232 @@ type MovieRecommendation struct {
 	ReleaseDate string   `json:"release_date"`
 	Genres      []string `json:"genres"`
 	Popularity  float64  `json:"popularity"`
+	// VoteAverage is the movie's rating; omitted when the movie service
+	// has none.
+	VoteAverage *float64 `json:"vote_average,omitempty"`
 	PosterURL   string   `json:"poster_url"`
 	Score       float64  `json:"score"`
 	Reason      string   `json:"reason"`
 }
 
+// RuleContribution is one rule's part of a movie's score: the rule's 0–1
+// sub-score for the movie (genre_match may go negative for disliked
+// genres) and that sub-score times the rule's weight.
+type RuleContribution struct {
+	RuleType     string  `json:"rule_type"`
+	Weight       float64 `json:"weight"`
+	SubScore     float64 `json:"sub_score"`
+	Contribution float64 `json:"contribution"`
+}
+
+// RecommendationExplanation breaks a movie's score for a user down by rule.
+type RecommendationExplanation struct {
+	UserID  int                `json:"user_id"`
+	MovieID int                `json:"movie_id"`
+	Title   string             `json:"title"`
+	Score   float64            `json:"score"`
+	Reason  string             `json:"reason"`
+	Rules   []RuleContribution `json:"rules"`
+}
+
+// AppliedRule records an active rule and the weight it was scored with.
+type AppliedRule struct {
+	ID       int     `json:"id"`
+	Name     string  `json:"name"`
+	RuleType string  `json:"rule_type"`
+	Weight   float64 `json:"weight"`
+}
+
 // RecommendationResponse wraps the recommendation list.
 type RecommendationResponse struct {
 	UserID          int                   `json:"user_id"`
 	Recommendations []MovieRecommendation `json:"recommendations"`
-	GeneratedAt     string                `json:"generated_at"`
+	MinScore        float64               `json:"min_score"`
+	// RulesApplied lists the rules the recommendations were scored with,
+	// so a cached result computed under older weights can be told apart.
+	RulesApplied []AppliedRule `json:"rules_applied"`
+	GeneratedAt  string        `json:"generated_at"`
+	// Stale marks recommendations served from the stored snapshots because
+	// they could not be generated; GeneratedAt is then the snapshots' time.
+	Stale bool `json:"stale,omitempty"`
+	// Cached reports whether the recommendations were read from the cache
+	// rather than computed for this request.
+	Cached bool `json:"cached"`
+	// Source is where the recommendations came from (SourceCache,
+	// SourceLive or SourceSnapshot) and GenerationMs how long producing
+	// the response took, cache lookups included.
+	Source       string `json:"source"`
+	GenerationMs int64  `json:"generation_ms"`
+}
+
+// RecommendationResponse Source values.
+const (
+	SourceCache    = "cache"
+	SourceLive     = "live"
+	SourceSnapshot = "snapshot"
+)
+
+// MaxBatchUsers caps how many users one batch request may ask for.
+const MaxBatchUsers = 100
+
+// BatchRecommendationRequest is the request body for generating
+// recommendations for several users at once.
+type BatchRecommendationRequest struct {
+	UserIDs []int `json:"user_ids"`
+	Limit   int   `json:"limit"`
+}
+
+// BatchRecommendationResult is one user's outcome in a batch: their
+// recommendations, or the error that prevented generating them.
+type BatchRecommendationResult struct {
+	UserID          int                   `json:"user_id"`
+	Recommendations []MovieRecommendation `json:"recommendations,omitempty"`
+	GeneratedAt     string                `json:"generated_at,omitempty"`
+	Error           string                `json:"error,omitempty"`
+}
+
+// BatchRecommendationResponse wraps the per-user batch results.
+type BatchRecommendationResponse struct {
+	Results   []BatchRecommendationResult `json:"results"`
+	Succeeded int                         `json:"succeeded"`
+	Failed    int                         `json:"failed"`
+	MinScore  float64                     `json:"min_score"`
+}
+
+// Response formats for the recommendations endpoint.
+const (
+	FormatFull    = "full"
+	FormatCompact = "compact"
+)
+
+// CompactRecommendation is the trimmed recommendation shape for
+// bandwidth-constrained clients.
+type CompactRecommendation struct {
+	ID        int     `json:"id"`
+	Title     string  `json:"title"`
+	PosterURL string  `json:"poster_url"`
+	Score     float64 `json:"score"`
+}
+
+// CompactRecommendationResponse wraps the compact recommendation list.
+type CompactRecommendationResponse struct {
+	UserID          int                     `json:"user_id"`
+	Recommendations []CompactRecommendation `json:"recommendations"`
+	GeneratedAt     string                  `json:"generated_at"`
+	Stale           bool                    `json:"stale,omitempty"`
+	Cached          bool                    `json:"cached"`
+	Source          string                  `json:"source"`
+	GenerationMs    int64                   `json:"generation_ms"`
+}
+
+// Compact returns the response reduced to the compact format.
+func (r *RecommendationResponse) Compact() *CompactRecommendationResponse {
+	recs := make([]CompactRecommendation, len(r.Recommendations))
+	for i, rec := range r.Recommendations {
+		recs[i] = CompactRecommendation{
+			ID:        rec.ID,
+			Title:     rec.Title,
+			PosterURL: rec.PosterURL,
+			Score:     rec.Score,
+		}
+	}
+	return &CompactRecommendationResponse{
+		UserID:          r.UserID,
+		Recommendations: recs,
+		GeneratedAt:     r.GeneratedAt,
+		Stale:           r.Stale,
+		Cached:          r.Cached,
+		Source:          r.Source,
+		GenerationMs:    r.GenerationMs,
+	}
+}
+
+// MaxPoolPages caps the candidate pool a request may ask for, in pages of
+// 20 movies.
+const MaxPoolPages = 10
+
+// MaxSeenIDs caps how many already-seen movie IDs a client may send.
+const MaxSeenIDs = 500
+
+// DiversifyByDecade reranks recommendations so no single release decade
+// dominates a run of consecutive results.
+const DiversifyByDecade = "decade"
+
+// Display sorts reorder the selected recommendations without changing
+// which movies are selected.
+const (
+	DisplaySortScore       = "score"
+	DisplaySortReleaseDate = "release_date"
+	DisplaySortTitle       = "title"
+)
+
+// RecommendationParams holds options for generating recommendations.
+type RecommendationParams struct {
+	Limit int
+	// Offset skips that many of the best scored movies, for paging.
+	Offset int
+	// Seen lists movie IDs the client already received in this session.
+	// They are excluded before limiting, and the cache is bypassed.
+	Seen []int
+	// PoolPages overrides the configured candidate pool size, in pages of
+	// 20 movies; 0 uses the configured size.
+	PoolPages int
+	// YearFrom and YearTo restrict candidates by release year (inclusive).
+	// Zero means unbounded.
+	YearFrom int
+	YearTo   int
+	// DiversifyBy selects an optional reranking mode; empty disables it.
+	DiversifyBy string
+	// Diversify reranks recommendations to spread them across genres.
+	Diversify bool
+	// DisplaySort orders the final list; empty keeps the score order.
+	DisplaySort string
+	// Family applies the configured genre blocklist to this request.
+	Family bool
+	// IgnoreMinRating keeps movies rated below the user's min_rating.
+	IgnoreMinRating bool
+	// OnlyNew excludes every movie the user has any interaction with.
+	OnlyNew bool
+	// BlockedGenresOverride, set only for admin requests, replaces the
+	// configured genre blocklist; an empty list disables it.
+	BlockedGenresOverride *[]string
+	// Fresh, set only for admin requests, skips the cached recommendations
+	// and recomputes them; SkipCacheWrite also keeps the result out of the
+	// cache.
+	Fresh          bool
+	SkipCacheWrite bool
+}
+
+// IsDefaultRanking reports whether the request ranks the whole default
+// candidate pool without filters, reranking or session state, so its result
+// can stand in for other requests. Display sorting only reorders the page
+// and the admin cache options do not change the ranking, so both are
+// allowed.
+func (p RecommendationParams) IsDefaultRanking() bool {
+	return len(p.Seen) == 0 && p.PoolPages == 0 && p.YearFrom == 0 && p.YearTo == 0 &&
+		p.DiversifyBy == "" && !p.Diversify && !p.Family && !p.IgnoreMinRating &&
+		!p.OnlyNew && p.BlockedGenresOverride == nil
+}
+
+// ReleaseDateRange returns the year range as movie service date filters.
+func (p RecommendationParams) ReleaseDateRange() (from, to string) {
+	if p.YearFrom > 0 {
+		from = fmt.Sprintf("%04d-01-01", p.YearFrom)
+	}
+	if p.YearTo > 0 {
+		to = fmt.Sprintf("%04d-12-31", p.YearTo)
+	}
+	return from, to
 }
 
 // MovieListItem represents a movie from the movie service.
 type MovieListItem struct {
-	ID          int     `json:"id"`
-	Title       string  `json:"title"`
-	ReleaseDate string  `json:"release_date"`
-	Popularity  float64 `json:"popularity"`
-	PosterURL   string  `json:"poster_url"`
+	ID          int      `json:"id"`
+	Title       string   `json:"title"`
+	ReleaseDate string   `json:"release_date"`
+	Popularity  float64  `json:"popularity"`
+	VoteAverage *float64 `json:"vote_average,omitempty"`
+	PosterURL   string   `json:"poster_url"`
 }
 
 // MovieListResponse represents the movie service list response.
@@ -68,6 +317,7 @@ type MovieDetail struct {
 	Language    string   `json:"language"`
 	Duration    int      `json:"duration"`
 	Popularity  float64  `json:"popularity"`
+	VoteAverage *float64 `json:"vote_average,omitempty"`
 	PosterURL   string   `json:"poster_url"`
 	BackdropURL string   `json:"backdrop_url"`
 }
@@ -76,6 +326,57 @@ type MovieDetail struct {
 type UserPreference struct {
 	UserID            int      `json:"user_id"`
 	PreferredGenres   []string `json:"preferred_genres"`
+	DislikedGenres    []string `json:"disliked_genres"`
 	PreferredLanguage string   `json:"preferred_language"`
 	MinRating         float64  `json:"min_rating"`
+	// HasPreferences is false for a user who never set preferences (cold
+	// start), as opposed to one who set and later cleared them.
+	HasPreferences bool `json:"has_preferences"`
+}
+
+// EffectivePreferences describes the preferences the recommender resolved
+// for a user, including whether it fell back to defaults.
+type EffectivePreferences struct {
+	UserID             int             `json:"user_id"`
+	Preferences        *UserPreference `json:"preferences"`
+	FellBackToDefaults bool            `json:"fell_back_to_defaults"`
+	FallbackReason     string          `json:"fallback_reason,omitempty"`
+	// InferredGenres is set when the user never set preferences and
+	// preferred genres were inferred from positive interactions instead.
+	InferredGenres []string `json:"inferred_genres,omitempty"`
+}
+
+// UserInteraction is an interaction from the user preference service.
+type UserInteraction struct {
+	MovieID         int       `json:"movie_id"`
+	InteractionType string    `json:"interaction_type"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// InteractionListResponse represents the user preference service
+// interaction history response.
+type InteractionListResponse struct {
+	UserID       int               `json:"user_id"`
+	Interactions []UserInteraction `json:"interactions"`
+}
+
+// PositiveInteractionTypes are the interactions that signal interest in a
+// movie's genres.
+var PositiveInteractionTypes = map[string]bool{
+	"like":      true,
+	"watchlist": true,
+	"watched":   true,
+}
+
+// CatalogEvent is the event the movie service posts after a catalog sync.
+type CatalogEvent struct {
+	Type         string    `json:"type"`
+	MoviesSynced int       `json:"movies_synced"`
+	At           time.Time `json:"at"`
+}
+
+// ServiceStats is the operational snapshot served on /internal/stats.
+type ServiceStats struct {
+	Service string      `json:"service"`
+	Cache   cache.Stats `json:"cache"`
 }
@@ -3,6 +3,7 @@ package repository
 import (
 	"database/sql"
 	"fmt"
+	"time"
 
 	"movie-discovery-recommendation-service/internal/models"
 )
@@ -42,29 +43,120 @@ func (r *RecommendationRepository) GetActiveRules() ([]models.RecommendationRule
 	return rules, rows.Err()
 }
 
-// UpsertSnapshot stores or updates a recommendation score snapshot.
-func (r *RecommendationRepository) UpsertSnapshot(userID, movieID int, score float64) error {
-	_, err := r.db.Exec(`
+// GetRule returns one rule by ID, active or not.
+func (r *RecommendationRepository) GetRule(id int) (*models.RecommendationRule, error) {
+	var rule models.RecommendationRule
+	err := r.db.QueryRow(`
+		SELECT id, name, weight, rule_type, is_active, created_at
+		FROM recommendation_rules
+		WHERE id = $1
+	`, id).Scan(
+		&rule.ID, &rule.Name, &rule.Weight,
+		&rule.RuleType, &rule.IsActive, &rule.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// CreateRule inserts an active rule and fills in its ID and creation time.
+func (r *RecommendationRepository) CreateRule(rule *models.RecommendationRule) error {
+	return r.db.QueryRow(`
+		INSERT INTO recommendation_rules (name, weight, rule_type, is_active)
+		VALUES ($1, $2, $3, TRUE)
+		RETURNING id, is_active, created_at
+	`, rule.Name, rule.Weight, rule.RuleType).Scan(&rule.ID, &rule.IsActive, &rule.CreatedAt)
+}
+
+// UpdateRule replaces a rule's name, weight, type and active flag. It
+// returns sql.ErrNoRows when the rule does not exist.
+func (r *RecommendationRepository) UpdateRule(rule *models.RecommendationRule) error {
+	return r.db.QueryRow(`
+		UPDATE recommendation_rules
+		SET name = $2, weight = $3, rule_type = $4, is_active = $5
+		WHERE id = $1
+		RETURNING created_at
+	`, rule.ID, rule.Name, rule.Weight, rule.RuleType, rule.IsActive).Scan(&rule.CreatedAt)
+}
+
+// DeactivateRule marks a rule inactive so scoring ignores it. It returns
+// sql.ErrNoRows when the rule does not exist.
+func (r *RecommendationRepository) DeactivateRule(id int) error {
+	res, err := r.db.Exec(`UPDATE recommendation_rules SET is_active = FALSE WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// SaveSnapshots stores one recommendation generation of a user: every
+// snapshot is written with the same generatedAt, which identifies the
+// generation.
+func (r *RecommendationRepository) SaveSnapshots(userID int, generatedAt time.Time, snapshots []models.RecommendationSnapshot) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin snapshot transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
 		INSERT INTO user_recommendation_snapshots (user_id, movie_id, score, generated_at)
-		VALUES ($1, $2, $3, NOW())
-		ON CONFLICT (user_id, movie_id)
-		DO UPDATE SET score = EXCLUDED.score, generated_at = NOW()
-	`, userID, movieID, score)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, generated_at, movie_id) DO UPDATE SET score = EXCLUDED.score
+	`)
 	if err != nil {
-		return fmt.Errorf("upsert snapshot: %w", err)
+		return fmt.Errorf("prepare snapshot insert: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, snap := range snapshots {
+		if _, err := stmt.Exec(userID, snap.MovieID, snap.Score, generatedAt); err != nil {
+			return fmt.Errorf("insert snapshot: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit snapshots: %w", err)
 	}
 	return nil
 }
 
-// GetSnapshots retrieves the top N recommendation snapshots for a user.
+// GetSnapshots retrieves the top N snapshots of a user's latest
+// generation.
 func (r *RecommendationRepository) GetSnapshots(userID, limit int) ([]models.RecommendationSnapshot, error) {
-	rows, err := r.db.Query(`
+	return r.querySnapshots(`
 		SELECT id, user_id, movie_id, score, generated_at
 		FROM user_recommendation_snapshots
-		WHERE user_id = $1
+		WHERE user_id = $1 AND generated_at = (
+			SELECT MAX(generated_at) FROM user_recommendation_snapshots WHERE user_id = $1
+		)
 		ORDER BY score DESC
 		LIMIT $2
 	`, userID, limit)
+}
+
+// GetSnapshotHistory retrieves the snapshots of a user's latest
+// generations, newest generation first and highest score first within
+// each.
+func (r *RecommendationRepository) GetSnapshotHistory(userID, generations int) ([]models.RecommendationSnapshot, error) {
+	return r.querySnapshots(`
+		SELECT id, user_id, movie_id, score, generated_at
+		FROM user_recommendation_snapshots
+		WHERE user_id = $1 AND generated_at IN (
+			SELECT DISTINCT generated_at FROM user_recommendation_snapshots
+			WHERE user_id = $1
+			ORDER BY generated_at DESC
+			LIMIT $2
+		)
+		ORDER BY generated_at DESC, score DESC
+	`, userID, generations)
+}
+
+func (r *RecommendationRepository) querySnapshots(query string, args ...any) ([]models.RecommendationSnapshot, error) {
+	rows, err := r.db.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("query snapshots: %w", err)
 	}
@@ -81,11 +173,20 @@ func (r *RecommendationRepository) GetSnapshots(userID, limit int) ([]models.Rec
 	return snapshots, rows.Err()
 }
 
-// ClearSnapshots removes all snapshots for a user (before regeneration).
-func (r *RecommendationRepository) ClearSnapshots(userID int) error {
-	_, err := r.db.Exec(`DELETE FROM user_recommendation_snapshots WHERE user_id = $1`, userID)
+// TrimSnapshotGenerations deletes all but the keep latest generations of
+// a user's snapshots.
+func (r *RecommendationRepository) TrimSnapshotGenerations(userID, keep int) error {
+	_, err := r.db.Exec(`
+		DELETE FROM user_recommendation_snapshots
+		WHERE user_id = $1 AND generated_at NOT IN (
+			SELECT DISTINCT generated_at FROM user_recommendation_snapshots
+			WHERE user_id = $1
+			ORDER BY generated_at DESC
+			LIMIT $2
+		)
+	`, userID, keep)
 	if err != nil {
-		return fmt.Errorf("clear snapshots: %w", err)
+		return fmt.Errorf("trim snapshot generations: %w", err)
 	}
 	return nil
 }
@@ -0,0 +1,24 @@
+package repository
+
+import (
+	"time"
+
+	"movie-discovery-recommendation-service/internal/models"
+)
+
+// RecommendationStore is the persistence contract the recommendation
+// service depends on. RecommendationRepository is the Postgres
+// implementation; tests may supply a fake.
+type RecommendationStore interface {
+	GetActiveRules() ([]models.RecommendationRule, error)
+	GetRule(id int) (*models.RecommendationRule, error)
+	CreateRule(rule *models.RecommendationRule) error
+	UpdateRule(rule *models.RecommendationRule) error
+	DeactivateRule(id int) error
+	SaveSnapshots(userID int, generatedAt time.Time, snapshots []models.RecommendationSnapshot) error
+	GetSnapshots(userID, limit int) ([]models.RecommendationSnapshot, error)
+	GetSnapshotHistory(userID, generations int) ([]models.RecommendationSnapshot, error)
+	TrimSnapshotGenerations(userID, keep int) error
+}
+
+var _ RecommendationStore = (*RecommendationRepository)(nil)
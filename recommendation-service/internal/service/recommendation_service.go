@@ -1,219 +1,1211 @@
 package service
 
 import (
+	"bytes"
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"math"
 	"net/http"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/redis/go-redis/v9"
-
+	"movie-discovery-recommendation-service/internal/cache"
+	"movie-discovery-recommendation-service/internal/config"
 	"movie-discovery-recommendation-service/internal/models"
 	"movie-discovery-recommendation-service/internal/repository"
+	"movie-discovery-recommendation-service/internal/requestid"
 )
 
+// defaultRecommendationLimit is the feed size clients request by default,
+// and therefore the one precomputed when warming the cache.
+const defaultRecommendationLimit = 10
+
+// defaultRecommendationTTL caches recommendations when no activity tier
+// applies or the user's activity could not be checked.
+const defaultRecommendationTTL = 10 * time.Minute
+
+// defaultUpstreamTimeout bounds upstream service calls unless
+// WithUpstreamTimeout sets another limit.
+const defaultUpstreamTimeout = 15 * time.Second
+
+// candidatePoolTTL bounds how long a fetched candidate pool is reused
+// when no catalog-changed event arrives to invalidate it.
+const candidatePoolTTL = 30 * time.Minute
+
+// affinityCacheTTL bounds how long a user's genre affinity, derived from
+// their interactions, is reused.
+const affinityCacheTTL = 30 * time.Minute
+
+// interactionHistoryLimit is how many recent interactions are read when
+// inferring preferred genres. It stays within the movie service's default
+// MAX_FILTER_VALUES so the batch genre lookup is never rejected.
+const interactionHistoryLimit = 50
+
+// onlyNewHistoryLimit is how many interactions are read to exclude every
+// movie the user has interacted with in only-new mode.
+const onlyNewHistoryLimit = 1000
+
+// maxSameDecadeRun caps consecutive results from one release decade
+// when diversifying by decade.
+const maxSameDecadeRun = 2
+
+// HTTPDoer is the subset of *http.Client used to call the movie and user
+// preference services, so tests can substitute canned responses.
+type HTTPDoer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Option configures a RecommendationService.
+type Option func(*RecommendationService)
+
+// WithInternalToken sets the shared secret sent as X-Internal-Token on
+// upstream service calls.
+func WithInternalToken(token string) Option {
+	return func(s *RecommendationService) {
+		s.internalAPIToken = token
+	}
+}
+
+// WithUpstreamTimeout bounds each upstream service call; non-positive keeps
+// the default of defaultUpstreamTimeout.
+func WithUpstreamTimeout(timeout time.Duration) Option {
+	return func(s *RecommendationService) {
+		if timeout > 0 {
+			s.httpClient = &http.Client{Timeout: timeout}
+		}
+	}
+}
+
+// WithHTTPDoer replaces the HTTP client used for upstream service calls.
+func WithHTTPDoer(doer HTTPDoer) Option {
+	return func(s *RecommendationService) {
+		if doer != nil {
+			s.httpClient = doer
+		}
+	}
+}
+
 type RecommendationService struct {
-	repo                     *repository.RecommendationRepository
-	rdb                      *redis.Client
+	repo                     repository.RecommendationStore
+	cache                    cache.Cache
 	movieServiceURL          string
 	userPreferenceServiceURL string
-	httpClient               *http.Client
+	httpClient               HTTPDoer
+	internalAPIToken         string
+	cfg                      config.RecommendationConfig
 }
 
 func NewRecommendationService(
-	repo *repository.RecommendationRepository,
-	rdb *redis.Client,
+	repo repository.RecommendationStore,
+	c cache.Cache,
 	movieServiceURL, userPreferenceServiceURL string,
+	cfg config.RecommendationConfig,
+	opts ...Option,
 ) *RecommendationService {
-	return &RecommendationService{
+	s := &RecommendationService{
 		repo:                     repo,
-		rdb:                      rdb,
+		cache:                    c,
+		cfg:                      cfg,
 		movieServiceURL:          strings.TrimRight(movieServiceURL, "/"),
 		userPreferenceServiceURL: strings.TrimRight(userPreferenceServiceURL, "/"),
-		httpClient:               &http.Client{Timeout: 15 * time.Second},
+		httpClient:               &http.Client{Timeout: defaultUpstreamTimeout},
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// candidatePool is the scoring input shared by every user: the candidate
+// movies and the active rules.
+type candidatePool struct {
+	movies []models.MovieDetail
+	rules  []models.RecommendationRule
 }
 
 // GetRecommendations generates personalized recommendations for a user.
-func (s *RecommendationService) GetRecommendations(ctx context.Context, userID, limit int) (*models.RecommendationResponse, error) {
+// Requests carrying a seen list depend on client session state, so they
+// neither read from nor write to the cache. Fresh requests skip the cache
+// read only. When generation fails, the user's stored snapshots are served
+// as stale recommendations; it only errors when there are none either.
+// DisplaySort only reorders the selected recommendations, so it applies to
+// cached results alike. The response records its source and how long it
+// took to produce.
+func (s *RecommendationService) GetRecommendations(ctx context.Context, userID int, params models.RecommendationParams) (*models.RecommendationResponse, error) {
+	start := time.Now()
+	resp, source, err := s.getRecommendations(ctx, userID, params)
+	if err != nil {
+		return nil, err
+	}
+	resp.Recommendations = sortForDisplay(resp.Recommendations, params.DisplaySort)
+	resp.Source = source
+	resp.GenerationMs = time.Since(start).Milliseconds()
+	return resp, nil
+}
+
+func (s *RecommendationService) getRecommendations(ctx context.Context, userID int, params models.RecommendationParams) (*models.RecommendationResponse, string, error) {
 	// Check Redis cache first
-	cacheKey := fmt.Sprintf("recommendations:%d:%d", userID, limit)
-	if cached, err := s.rdb.Get(ctx, cacheKey).Result(); err == nil {
-		var resp models.RecommendationResponse
-		if json.Unmarshal([]byte(cached), &resp) == nil {
-			slog.Debug("recommendations cache hit", "user_id", userID)
-			return &resp, nil
+	if !params.Fresh {
+		if resp, ok := s.cachedRecommendations(ctx, userID, params); ok {
+			return resp, models.SourceCache, nil
 		}
 	}
 
-	// Fetch user preferences
-	prefs, err := s.fetchUserPreferences(ctx, userID)
+	pool, err := s.loadPool(ctx, params)
 	if err != nil {
-		slog.Warn("could not fetch user preferences, using defaults", "user_id", userID, "error", err)
-		prefs = &models.UserPreference{
-			UserID:          userID,
-			PreferredGenres: []string{},
+		if resp, ok := s.snapshotRecommendations(ctx, userID, params); ok {
+			slog.WarnContext(ctx, "serving stale recommendations from snapshots", "user_id", userID, "error", err)
+			return resp, models.SourceSnapshot, nil
+		}
+		return nil, "", err
+	}
+	return s.recommend(ctx, userID, params, pool), models.SourceLive, nil
+}
+
+// snapshotRecommendations builds a stale response from the user's stored
+// snapshots. Snapshots hold the default ranking, so the request's own
+// filters (blocklist, years, minimum rating, only_new, seen) are applied to
+// them here. Movie details come from a cached candidate pool when one holds
+// the movie, since the movie service may be what failed; a filter that
+// needs a movie's details drops the movie when they are not cached. When a
+// filter cannot be checked at all no stale response is served.
+func (s *RecommendationService) snapshotRecommendations(ctx context.Context, userID int, params models.RecommendationParams) (*models.RecommendationResponse, bool) {
+	snapshots, err := s.repo.GetSnapshots(userID, s.cfg.MaxSnapshotsPerUser)
+	if err != nil {
+		slog.WarnContext(ctx, "could not read recommendation snapshots", "user_id", userID, "error", err)
+		return nil, false
+	}
+	if len(snapshots) == 0 {
+		return nil, false
+	}
+
+	details := s.cachedCandidateDetails(ctx, params)
+	movies := make([]models.MovieDetail, len(snapshots))
+	for i, snap := range snapshots {
+		if m, ok := details[snap.MovieID]; ok {
+			movies[i] = m
+		} else {
+			movies[i] = models.MovieDetail{ID: snap.MovieID}
+		}
+	}
+
+	movies, ok := s.filterSnapshotMovies(ctx, userID, params, movies)
+	if !ok {
+		return nil, false
+	}
+	kept := make(map[int]bool, len(movies))
+	for _, m := range movies {
+		kept[m.ID] = true
+	}
+
+	recs := make([]models.MovieRecommendation, 0, params.Limit)
+	var generatedAt time.Time
+	skip := params.Offset
+	for _, snap := range snapshots {
+		if len(recs) == params.Limit {
+			break
+		}
+		if !kept[snap.MovieID] {
+			continue
+		}
+		if skip > 0 {
+			skip--
+			continue
+		}
+		rec := models.MovieRecommendation{
+			ID:     snap.MovieID,
+			Genres: []string{},
+			Score:  snap.Score,
+			Reason: "from your last recommendations",
+		}
+		if m, ok := details[snap.MovieID]; ok {
+			rec.Title = m.Title
+			rec.ReleaseDate = m.ReleaseDate
+			rec.Genres = m.Genres
+			rec.Popularity = m.Popularity
+			rec.VoteAverage = m.VoteAverage
+			rec.PosterURL = m.PosterURL
+		}
+		recs = append(recs, rec)
+		if snap.GeneratedAt.After(generatedAt) {
+			generatedAt = snap.GeneratedAt
+		}
+	}
+	if len(recs) == 0 {
+		return nil, false
+	}
+
+	return &models.RecommendationResponse{
+		UserID:          userID,
+		Recommendations: recs,
+		MinScore:        s.cfg.MinScore,
+		RulesApplied:    []models.AppliedRule{},
+		GeneratedAt:     generatedAt.UTC().Format(time.RFC3339),
+		Stale:           true,
+	}, true
+}
+
+// filterSnapshotMovies applies the request's filters to snapshot movies, as
+// recommend applies them to the candidate pool. It returns false when the
+// user's minimum rating or interaction history is needed but cannot be
+// fetched.
+func (s *RecommendationService) filterSnapshotMovies(ctx context.Context, userID int, params models.RecommendationParams, movies []models.MovieDetail) ([]models.MovieDetail, bool) {
+	if len(params.Seen) > 0 {
+		movies = excludeSeen(movies, params.Seen)
+	}
+	if params.OnlyNew {
+		interactions, err := s.fetchInteractions(ctx, userID, onlyNewHistoryLimit)
+		if err != nil {
+			slog.WarnContext(ctx, "could not fetch interactions for only_new on snapshots", "user_id", userID, "error", err)
+			return nil, false
+		}
+		movies = excludeSeen(movies, interactedMovieIDs(interactions))
+	}
+	if blocked := s.blockedGenres(params); len(blocked) > 0 {
+		movies = excludeGenres(movies, blocked)
+	}
+	if params.YearFrom > 0 || params.YearTo > 0 {
+		movies = excludeOutsideYears(movies, params.YearFrom, params.YearTo)
+	}
+	if !params.IgnoreMinRating {
+		prefs, err := s.fetchUserPreferences(ctx, userID)
+		if err != nil {
+			slog.WarnContext(ctx, "could not fetch min_rating for snapshots", "user_id", userID, "error", err)
+			return nil, false
+		}
+		if prefs.MinRating > 0 {
+			movies = excludeBelowRating(movies, prefs.MinRating)
 		}
 	}
+	return movies, true
+}
+
+// cachedCandidateDetails returns the movies of the cached candidate pools
+// for the request's year range and for the default ranking by ID, without
+// calling the movie service.
+func (s *RecommendationService) cachedCandidateDetails(ctx context.Context, params models.RecommendationParams) map[int]models.MovieDetail {
+	details := map[int]models.MovieDetail{}
+	keys := []string{s.candidatePoolCacheKey(params)}
+	if defaultKey := s.candidatePoolCacheKey(models.RecommendationParams{}); defaultKey != keys[0] {
+		keys = append(keys, defaultKey)
+	}
+	for _, key := range keys {
+		cached, err := s.cache.Get(ctx, key)
+		if err != nil {
+			continue
+		}
+		var movies []models.MovieDetail
+		if json.Unmarshal([]byte(cached), &movies) != nil {
+			continue
+		}
+		for _, m := range movies {
+			details[m.ID] = m
+		}
+	}
+	return details
+}
+
+// GetBatchRecommendations generates recommendations for several users,
+// scoring them all against one candidate pool with at most BatchConcurrency
+// users in flight. Results keep the order of userIDs; a user whose
+// recommendations fail is reported in its result without failing the batch.
+// It only fails when ctx is done.
+func (s *RecommendationService) GetBatchRecommendations(ctx context.Context, userIDs []int, params models.RecommendationParams) (*models.BatchRecommendationResponse, error) {
+	results := make([]models.BatchRecommendationResult, len(userIDs))
+	var pool *candidatePool
+	var poolErr error
+	var poolOnce sync.Once
+
+	concurrency := s.cfg.BatchConcurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for i, userID := range userIDs {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return nil, ctx.Err()
+		}
+
+		wg.Add(1)
+		go func(i, userID int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			results[i].UserID = userID
+			resp, ok := s.cachedRecommendations(ctx, userID, params)
+			if !ok {
+				// Loaded by the first user that misses the cache
+				poolOnce.Do(func() { pool, poolErr = s.loadPool(ctx, params) })
+				if poolErr != nil {
+					results[i].Error = poolErr.Error()
+					return
+				}
+				resp = s.recommend(ctx, userID, params, pool)
+			}
+			results[i].Recommendations = resp.Recommendations
+			results[i].GeneratedAt = resp.GeneratedAt
+		}(i, userID)
+	}
+	wg.Wait()
 
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if poolErr != nil {
+		slog.WarnContext(ctx, "could not load candidate pool for batch", "error", poolErr)
+	}
+
+	batch := &models.BatchRecommendationResponse{
+		Results:  results,
+		MinScore: s.cfg.MinScore,
+	}
+	for _, r := range results {
+		if r.Error == "" {
+			batch.Succeeded++
+		} else {
+			batch.Failed++
+		}
+	}
+	return batch, nil
+}
+
+// recommendationCacheKey identifies a user's cached recommendations for the
+// options that change the result.
+func (s *RecommendationService) recommendationCacheKey(userID int, params models.RecommendationParams) string {
+	return fmt.Sprintf("recommendations:%d:%d:%d:%d:%d-%d:%s:%t:%s:%t:%t", userID, params.Limit, params.Offset, s.poolPages(params), params.YearFrom, params.YearTo,
+		params.DiversifyBy, params.Diversify, strings.ToLower(strings.Join(s.blockedGenres(params), ",")), params.IgnoreMinRating, params.OnlyNew)
+}
+
+// cachedRecommendations returns a user's cached recommendations, if any.
+func (s *RecommendationService) cachedRecommendations(ctx context.Context, userID int, params models.RecommendationParams) (*models.RecommendationResponse, bool) {
+	if len(params.Seen) > 0 {
+		return nil, false
+	}
+	cached, err := s.cache.Get(ctx, s.recommendationCacheKey(userID, params))
+	if err != nil {
+		return nil, false
+	}
+	var resp models.RecommendationResponse
+	if json.Unmarshal([]byte(cached), &resp) != nil {
+		return nil, false
+	}
+	slog.Debug("recommendations cache hit", "user_id", userID)
+	resp.Cached = true
+	return &resp, true
+}
+
+// loadPool loads the candidate movies and, when there are any, the active
+// scoring rules.
+func (s *RecommendationService) loadPool(ctx context.Context, params models.RecommendationParams) (*candidatePool, error) {
 	// Fetch movies from movie service (multiple pages for better pool)
-	allMovies, err := s.fetchMovies(ctx, 3)
+	movies, err := s.loadCandidates(ctx, params)
 	if err != nil {
 		return nil, fmt.Errorf("fetch movies: %w", err)
 	}
+	if len(movies) == 0 {
+		return &candidatePool{}, nil
+	}
 
-	if len(allMovies) == 0 {
+	// Fetch active scoring rules
+	rules, err := s.repo.GetActiveRules()
+	if err != nil {
+		return nil, fmt.Errorf("get rules: %w", err)
+	}
+	return &candidatePool{movies: movies, rules: rules}, nil
+}
+
+// recommend scores the pool for a user and caches the result. The pool is
+// only read, so it may be shared between concurrent calls.
+func (s *RecommendationService) recommend(ctx context.Context, userID int, params models.RecommendationParams, pool *candidatePool) *models.RecommendationResponse {
+	limit := params.Limit
+	useCache := len(params.Seen) == 0 && !params.SkipCacheWrite
+
+	if len(pool.movies) == 0 {
 		return &models.RecommendationResponse{
 			UserID:          userID,
 			Recommendations: []models.MovieRecommendation{},
+			MinScore:        s.cfg.MinScore,
+			RulesApplied:    []models.AppliedRule{},
 			GeneratedAt:     time.Now().UTC().Format(time.RFC3339),
-		}, nil
+		}
 	}
 
-	// Fetch active scoring rules
-	rules, err := s.repo.GetActiveRules()
-	if err != nil {
-		return nil, fmt.Errorf("get rules: %w", err)
+	// Fetch user preferences
+	prefs := s.resolveUserPreferences(ctx, userID).Preferences
+
+	// Genre affinity from the user's interactions, only when a rule uses it
+	var affinity map[string]float64
+	if hasRuleType(pool.rules, "interaction_affinity") {
+		affinity = s.scoringAffinity(ctx, userID, prefs.DislikedGenres)
+	}
+
+	// Drop movies the client already received in this session
+	allMovies := pool.movies
+	if len(params.Seen) > 0 {
+		allMovies = excludeSeen(allMovies, params.Seen)
+	}
+
+	// In only-new mode drop every movie the user has interacted with in any
+	// way; without the history the list is served unfiltered
+	if params.OnlyNew {
+		if interactions, err := s.fetchInteractions(ctx, userID, onlyNewHistoryLimit); err != nil {
+			slog.WarnContext(ctx, "could not fetch interactions for only_new, not filtering", "user_id", userID, "error", err)
+		} else {
+			allMovies = excludeSeen(allMovies, interactedMovieIDs(interactions))
+		}
+	}
+
+	// Enforce the genre blocklist; it takes precedence over preferences
+	if blocked := s.blockedGenres(params); len(blocked) > 0 {
+		allMovies = excludeGenres(allMovies, blocked)
+	}
+
+	// The user's minimum rating is a hard filter, not a weighted rule; a
+	// short list is returned as is rather than padded with lower-rated movies
+	if prefs.MinRating > 0 && !params.IgnoreMinRating {
+		allMovies = excludeBelowRating(allMovies, prefs.MinRating)
+		if len(allMovies) < limit {
+			slog.WarnContext(ctx, "few movies meet the user's minimum rating",
+				"user_id", userID, "min_rating", prefs.MinRating, "remaining", len(allMovies), "limit", limit)
+		}
 	}
 
 	// Score each movie
-	scored := s.scoreMovies(allMovies, prefs, rules)
+	scored := s.scoreMovies(allMovies, prefs, affinity, pool.rules)
 
 	// Sort by score descending
 	sort.Slice(scored, func(i, j int) bool {
 		return scored[i].Score > scored[j].Score
 	})
 
-	// Limit results
-	if len(scored) > limit {
-		scored = scored[:limit]
+	// Drop movies below the relevance floor
+	scored = applyMinScore(scored, s.cfg.MinScore)
+
+	// Spread results across genres when requested
+	if params.Diversify {
+		scored = diversify(scored, s.cfg.DiversityLambda, params.Offset+limit)
+	}
+
+	// Spread results across release decades when requested
+	if params.DiversifyBy == models.DiversifyByDecade {
+		scored = diversifyByDecade(scored, maxSameDecadeRun)
+	}
+
+	// Persist the ranking as a new snapshot generation asynchronously.
+	// Only the default ranking is stored, since snapshots stand in for any
+	// request during an outage; later pages are the same ranking and would
+	// only add duplicates
+	if s.cfg.MaxSnapshotsPerUser > 0 && params.Offset == 0 && params.IsDefaultRanking() {
+		go s.saveSnapshots(userID, scored)
+	}
+
+	resp := &models.RecommendationResponse{
+		UserID:          userID,
+		Recommendations: page(scored, params.Offset, limit),
+		MinScore:        s.cfg.MinScore,
+		RulesApplied:    appliedRules(pool.rules),
+		GeneratedAt:     time.Now().UTC().Format(time.RFC3339),
+	}
+
+	// Cache for a TTL matching the user's recent activity
+	if useCache {
+		if data, err := json.Marshal(resp); err == nil {
+			s.cache.Set(ctx, s.recommendationCacheKey(userID, params), string(data), s.recommendationTTL(ctx, userID))
+		}
+	}
+
+	return resp
+}
+
+// sortForDisplay returns recommendations reordered by the display sort
+// field: release date oldest first or title A-Z. Ties and the default score
+// sort keep the ranking order. The input is not modified, as it may still
+// be read by the snapshot writer.
+func sortForDisplay(recs []models.MovieRecommendation, field string) []models.MovieRecommendation {
+	var less func(a, b models.MovieRecommendation) bool
+	switch field {
+	case models.DisplaySortReleaseDate:
+		less = func(a, b models.MovieRecommendation) bool { return a.ReleaseDate < b.ReleaseDate }
+	case models.DisplaySortTitle:
+		less = func(a, b models.MovieRecommendation) bool { return strings.ToLower(a.Title) < strings.ToLower(b.Title) }
+	default:
+		return recs
+	}
+	sorted := append([]models.MovieRecommendation(nil), recs...)
+	sort.SliceStable(sorted, func(i, j int) bool { return less(sorted[i], sorted[j]) })
+	return sorted
+}
+
+// page returns the limit recommendations after the first offset ones.
+func page(recs []models.MovieRecommendation, offset, limit int) []models.MovieRecommendation {
+	if offset >= len(recs) {
+		return []models.MovieRecommendation{}
+	}
+	return recs[offset:min(offset+limit, len(recs))]
+}
+
+// saveSnapshots stores the first MaxSnapshotsPerUser recommendations as a
+// new snapshot generation, then drops the user's generations beyond the
+// latest SnapshotGenerations.
+func (s *RecommendationService) saveSnapshots(userID int, recs []models.MovieRecommendation) {
+	if len(recs) > s.cfg.MaxSnapshotsPerUser {
+		recs = recs[:s.cfg.MaxSnapshotsPerUser]
+	}
+	snapshots := make([]models.RecommendationSnapshot, len(recs))
+	for i, rec := range recs {
+		snapshots[i] = models.RecommendationSnapshot{UserID: userID, MovieID: rec.ID, Score: rec.Score}
+	}
+	// Postgres keeps microseconds; truncating keeps the generation key
+	// identical to what is read back
+	if err := s.repo.SaveSnapshots(userID, time.Now().UTC().Truncate(time.Microsecond), snapshots); err != nil {
+		slog.Warn("could not save recommendation snapshots", "user_id", userID, "error", err)
+		return
+	}
+	if err := s.repo.TrimSnapshotGenerations(userID, s.cfg.SnapshotGenerations); err != nil {
+		slog.Warn("could not trim recommendation snapshots", "user_id", userID, "error", err)
+	}
+}
+
+// GetSnapshotHistory returns up to generations of the user's stored
+// recommendation generations, newest first; 0 returns all that are kept.
+func (s *RecommendationService) GetSnapshotHistory(userID, generations int) (*models.SnapshotHistoryResponse, error) {
+	if generations <= 0 || generations > s.cfg.SnapshotGenerations {
+		generations = s.cfg.SnapshotGenerations
+	}
+	snapshots, err := s.repo.GetSnapshotHistory(userID, generations)
+	if err != nil {
+		return nil, err
+	}
+
+	history := &models.SnapshotHistoryResponse{UserID: userID, Generations: []models.SnapshotGeneration{}}
+	for _, snap := range snapshots {
+		n := len(history.Generations)
+		if n == 0 || !history.Generations[n-1].GeneratedAt.Equal(snap.GeneratedAt) {
+			history.Generations = append(history.Generations, models.SnapshotGeneration{GeneratedAt: snap.GeneratedAt})
+			n++
+		}
+		gen := &history.Generations[n-1]
+		gen.Recommendations = append(gen.Recommendations, models.SnapshotScore{MovieID: snap.MovieID, Score: snap.Score})
+	}
+	return history, nil
+}
+
+// appliedRules summarizes the rules a recommendation list was scored with.
+func appliedRules(rules []models.RecommendationRule) []models.AppliedRule {
+	applied := make([]models.AppliedRule, len(rules))
+	for i, r := range rules {
+		applied[i] = models.AppliedRule{
+			ID:       r.ID,
+			Name:     r.Name,
+			RuleType: r.RuleType,
+			Weight:   r.Weight,
+		}
+	}
+	return applied
+}
+
+// recommendationTTL picks the cache TTL of a user's recommendations from the
+// first activity tier their recent interaction count reaches, unless a fixed
+// CacheTTL is configured. Only the last interactionHistoryLimit interactions
+// are counted, which is plenty to tell active users from dormant ones.
+func (s *RecommendationService) recommendationTTL(ctx context.Context, userID int) time.Duration {
+	if s.cfg.CacheTTL > 0 {
+		return s.cfg.CacheTTL
+	}
+	if len(s.cfg.CacheTTLTiers) == 0 {
+		return defaultRecommendationTTL
+	}
+
+	interactions, err := s.fetchInteractions(ctx, userID, interactionHistoryLimit)
+	if err != nil {
+		slog.Warn("could not check user activity, using default TTL", "user_id", userID, "error", err)
+		return defaultRecommendationTTL
+	}
+
+	since := time.Now().Add(-s.cfg.ActivityWindow)
+	recent := 0
+	for _, in := range interactions {
+		if in.CreatedAt.After(since) {
+			recent++
+		}
+	}
+
+	for _, tier := range s.cfg.CacheTTLTiers {
+		if recent >= tier.MinInteractions {
+			return tier.TTL
+		}
+	}
+	return defaultRecommendationTTL
+}
+
+// applyMinScore keeps only recommendations scoring at least minScore.
+// The input must already be sorted by score descending.
+func applyMinScore(scored []models.MovieRecommendation, minScore float64) []models.MovieRecommendation {
+	if minScore <= 0 {
+		return scored
+	}
+	for i, rec := range scored {
+		if rec.Score < minScore {
+			return scored[:i]
+		}
+	}
+	return scored
+}
+
+// diversifyByDecade reorders score-sorted recommendations so that at most
+// maxRun consecutive movies share a release decade. It picks the highest
+// scored movie that keeps the constraint, falling back to the next movie
+// when every remaining one is from the same decade.
+func diversifyByDecade(scored []models.MovieRecommendation, maxRun int) []models.MovieRecommendation {
+	remaining := append([]models.MovieRecommendation(nil), scored...)
+	result := make([]models.MovieRecommendation, 0, len(scored))
+	lastDecade, run := -1, 0
+
+	for len(remaining) > 0 {
+		pick := 0
+		if run >= maxRun {
+			for i, rec := range remaining {
+				if releaseDecade(rec.ReleaseDate) != lastDecade {
+					pick = i
+					break
+				}
+			}
+		}
+
+		rec := remaining[pick]
+		remaining = append(remaining[:pick], remaining[pick+1:]...)
+		if decade := releaseDecade(rec.ReleaseDate); decade == lastDecade {
+			run++
+		} else {
+			lastDecade, run = decade, 1
+		}
+		result = append(result, rec)
+	}
+	return result
+}
+
+// diversityReason is appended to the reason of a movie that was ranked
+// above a higher scored one because it adds genre variety.
+const diversityReason = "ranked up for genre variety"
+
+// diversify picks up to limit recommendations from score-sorted ones by
+// maximal marginal relevance: each step takes the movie maximizing
+// lambda*score - (1-lambda)*similarity, where similarity is the movie's
+// highest genre overlap (Jaccard) with any movie already picked. lambda 1
+// keeps the score order, lower values trade relevance for variety.
+func diversify(scored []models.MovieRecommendation, lambda float64, limit int) []models.MovieRecommendation {
+	remaining := append([]models.MovieRecommendation(nil), scored...)
+	similarity := make([]float64, len(remaining))
+	result := make([]models.MovieRecommendation, 0, min(limit, len(remaining)))
+
+	for len(remaining) > 0 && len(result) < limit {
+		pick, best := 0, math.Inf(-1)
+		for i, rec := range remaining {
+			if mmr := lambda*rec.Score - (1-lambda)*similarity[i]; mmr > best {
+				pick, best = i, mmr
+			}
+		}
+
+		rec := remaining[pick]
+		// remaining stays score-sorted, so any pick but the first skipped
+		// a higher scored movie
+		if pick > 0 && rec.Score < remaining[0].Score {
+			rec.Reason += ", " + diversityReason
+		}
+		result = append(result, rec)
+		remaining = append(remaining[:pick], remaining[pick+1:]...)
+		similarity = append(similarity[:pick], similarity[pick+1:]...)
+
+		for i := range remaining {
+			similarity[i] = math.Max(similarity[i], genreSimilarity(rec.Genres, remaining[i].Genres))
+		}
+	}
+	return result
+}
+
+// genreSimilarity is the Jaccard index of two genre lists
+// (case-insensitive); movies without genres are similar to nothing.
+func genreSimilarity(a, b []string) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	set := make(map[string]bool, len(a))
+	for _, g := range a {
+		set[strings.ToLower(g)] = true
+	}
+	shared, union := 0, len(set)
+	seen := make(map[string]bool, len(b))
+	for _, g := range b {
+		g = strings.ToLower(g)
+		if seen[g] {
+			continue
+		}
+		seen[g] = true
+		if set[g] {
+			shared++
+		} else {
+			union++
+		}
+	}
+	return float64(shared) / float64(union)
+}
+
+// releaseDecade returns the decade of a YYYY-MM-DD release date, e.g. 1990,
+// or 0 when the date cannot be parsed.
+func releaseDecade(releaseDate string) int {
+	t, err := time.Parse("2006-01-02", releaseDate)
+	if err != nil {
+		return 0
+	}
+	return t.Year() / 10 * 10
+}
+
+// WarmRecommendations drops a user's cached recommendations and recomputes
+// the default feed so the next read is served from cache.
+func (s *RecommendationService) WarmRecommendations(ctx context.Context, userID int) error {
+	if err := s.invalidateUserCache(ctx, userID); err != nil {
+		return fmt.Errorf("invalidate cache: %w", err)
+	}
+	_, err := s.GetRecommendations(ctx, userID, models.RecommendationParams{Limit: defaultRecommendationLimit})
+	return err
+}
+
+// invalidateUserCache deletes every cached recommendation list for a user,
+// and their genre affinity.
+func (s *RecommendationService) invalidateUserCache(ctx context.Context, userID int) error {
+	if err := s.cache.Del(ctx, affinityCacheKey(userID)); err != nil {
+		return err
+	}
+	_, err := s.cache.Invalidate(ctx, fmt.Sprintf("recommendations:%d:*", userID))
+	return err
+}
+
+// InvalidateCatalog drops the cached candidate pools so the next request
+// refetches movies. When includeRecommendations is set, every user's cached
+// recommendation lists are dropped too. It returns the number of keys deleted.
+func (s *RecommendationService) InvalidateCatalog(ctx context.Context, includeRecommendations bool) (int, error) {
+	deleted, err := s.cache.Invalidate(ctx, "candidates:*")
+	if err != nil {
+		return deleted, err
+	}
+	if includeRecommendations {
+		n, err := s.cache.Invalidate(ctx, "recommendations:*")
+		deleted += n
+		if err != nil {
+			return deleted, err
+		}
+	}
+	return deleted, nil
+}
+
+// blockedGenres returns the genre blocklist that applies to a request: an
+// admin override if given, otherwise the configured list when it is
+// enforced or the request asks for family filtering.
+func (s *RecommendationService) blockedGenres(params models.RecommendationParams) []string {
+	if params.BlockedGenresOverride != nil {
+		return *params.BlockedGenresOverride
+	}
+	if s.cfg.EnforceBlocklist || params.Family {
+		return s.cfg.BlockedGenres
+	}
+	return nil
+}
+
+// excludeGenres removes movies having any of the given genres
+// (case-insensitive). Movies without genre data are removed too, since
+// they cannot be checked against the blocklist.
+func excludeGenres(movies []models.MovieDetail, genres []string) []models.MovieDetail {
+	blocked := make(map[string]bool, len(genres))
+	for _, g := range genres {
+		blocked[strings.ToLower(g)] = true
+	}
+	filtered := make([]models.MovieDetail, 0, len(movies))
+	for _, m := range movies {
+		keep := len(m.Genres) > 0
+		for _, g := range m.Genres {
+			if blocked[strings.ToLower(g)] {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			filtered = append(filtered, m)
+		}
+	}
+	return filtered
+}
+
+// excludeOutsideYears removes movies released outside yearFrom–yearTo
+// (inclusive; zero is unbounded). Movies without a release year are removed
+// too, as they cannot be shown to fall inside the range.
+func excludeOutsideYears(movies []models.MovieDetail, yearFrom, yearTo int) []models.MovieDetail {
+	filtered := make([]models.MovieDetail, 0, len(movies))
+	for _, m := range movies {
+		if len(m.ReleaseDate) < 4 {
+			continue
+		}
+		year, err := strconv.Atoi(m.ReleaseDate[:4])
+		if err != nil || (yearFrom > 0 && year < yearFrom) || (yearTo > 0 && year > yearTo) {
+			continue
+		}
+		filtered = append(filtered, m)
+	}
+	return filtered
+}
+
+// excludeBelowRating removes movies whose vote average is below minRating.
+// Unrated movies are removed too, as they cannot be shown to meet it.
+func excludeBelowRating(movies []models.MovieDetail, minRating float64) []models.MovieDetail {
+	filtered := make([]models.MovieDetail, 0, len(movies))
+	for _, m := range movies {
+		if m.VoteAverage != nil && *m.VoteAverage >= minRating {
+			filtered = append(filtered, m)
+		}
+	}
+	return filtered
+}
+
+// interactedMovieIDs returns the IDs of the movies in interactions.
+func interactedMovieIDs(interactions []models.UserInteraction) []int {
+	ids := make([]int, len(interactions))
+	for i, in := range interactions {
+		ids[i] = in.MovieID
+	}
+	return ids
+}
+
+// excludeSeen removes movies whose IDs appear in the seen list.
+func excludeSeen(movies []models.MovieDetail, seen []int) []models.MovieDetail {
+	seenSet := make(map[int]bool, len(seen))
+	for _, id := range seen {
+		seenSet[id] = true
+	}
+	filtered := make([]models.MovieDetail, 0, len(movies))
+	for _, m := range movies {
+		if !seenSet[m.ID] {
+			filtered = append(filtered, m)
+		}
+	}
+	return filtered
+}
+
+// scoreMovies applies weighted scoring rules to each movie. affinity maps
+// lowercase genres to the user's 0–1 interaction affinity; it may be nil.
+func (s *RecommendationService) scoreMovies(
+	movies []models.MovieDetail,
+	prefs *models.UserPreference,
+	affinity map[string]float64,
+	rules []models.RecommendationRule,
+) []models.MovieRecommendation {
+	scorer := s.newMovieScorer(movies, prefs, affinity, rules)
+	results := make([]models.MovieRecommendation, 0, len(movies))
+	for _, m := range movies {
+		score := scorer.score(m)
+		results = append(results, models.MovieRecommendation{
+			ID:          m.ID,
+			Title:       m.Title,
+			ReleaseDate: m.ReleaseDate,
+			Genres:      m.Genres,
+			Popularity:  m.Popularity,
+			VoteAverage: m.VoteAverage,
+			PosterURL:   m.PosterURL,
+			Score:       score.total,
+			Reason:      score.reason,
+		})
+	}
+	return results
+}
+
+// ExplainRecommendation scores one movie of the default candidate pool for
+// a user and returns each active rule's contribution. It returns
+// ErrMovieNotInPool when the movie is not a candidate.
+func (s *RecommendationService) ExplainRecommendation(ctx context.Context, userID, movieID int) (*models.RecommendationExplanation, error) {
+	pool, err := s.loadPool(ctx, models.RecommendationParams{})
+	if err != nil {
+		return nil, err
 	}
-
-	// Persist snapshots asynchronously
-	go func() {
-		_ = s.repo.ClearSnapshots(userID)
-		for _, rec := range scored {
-			_ = s.repo.UpsertSnapshot(userID, rec.ID, rec.Score)
+	var movie *models.MovieDetail
+	for i := range pool.movies {
+		if pool.movies[i].ID == movieID {
+			movie = &pool.movies[i]
+			break
 		}
-	}()
+	}
+	if movie == nil {
+		return nil, ErrMovieNotInPool
+	}
 
-	resp := &models.RecommendationResponse{
-		UserID:          userID,
-		Recommendations: scored,
-		GeneratedAt:     time.Now().UTC().Format(time.RFC3339),
+	prefs := s.resolveUserPreferences(ctx, userID).Preferences
+	var affinity map[string]float64
+	if hasRuleType(pool.rules, "interaction_affinity") {
+		affinity = s.scoringAffinity(ctx, userID, prefs.DislikedGenres)
 	}
 
-	// Cache for 10 minutes
-	if data, err := json.Marshal(resp); err == nil {
-		s.rdb.Set(ctx, cacheKey, data, 10*time.Minute)
+	score := s.newMovieScorer(pool.movies, prefs, affinity, pool.rules).score(*movie)
+	contributions := score.contributions
+	if contributions == nil {
+		contributions = []models.RuleContribution{}
 	}
+	return &models.RecommendationExplanation{
+		UserID:  userID,
+		MovieID: movieID,
+		Title:   movie.Title,
+		Score:   score.total,
+		Reason:  score.reason,
+		Rules:   contributions,
+	}, nil
+}
 
-	return resp, nil
+// movieScorer holds what scoring a movie depends on beyond the movie
+// itself: the rule weights, the pool's popularity scale and the user's
+// genre and language preferences.
+type movieScorer struct {
+	ruleWeights map[string]float64
+	logScale    bool
+	maxPop      float64
+	prefGenres  map[string]bool
+	disliked    map[string]bool
+	language    string
+	affinity    map[string]float64
+	// unknownRecency scores movies without a usable release date
+	unknownRecency float64
 }
 
-// scoreMovies applies weighted scoring rules to each movie.
-func (s *RecommendationService) scoreMovies(
+// movieScore is a movie's total score with the per-rule contributions it
+// is the sum of.
+type movieScore struct {
+	total         float64
+	reason        string
+	contributions []models.RuleContribution
+}
+
+// newMovieScorer prepares scoring of movies from a candidate pool; the
+// pool's highest popularity normalizes every movie's popularity.
+func (s *RecommendationService) newMovieScorer(
 	movies []models.MovieDetail,
 	prefs *models.UserPreference,
+	affinity map[string]float64,
 	rules []models.RecommendationRule,
-) []models.MovieRecommendation {
-	ruleWeights := make(map[string]float64)
+) *movieScorer {
+	sc := &movieScorer{
+		ruleWeights:    make(map[string]float64),
+		logScale:       s.cfg.PopularityScale == config.PopularityScaleLog,
+		prefGenres:     make(map[string]bool),
+		disliked:       make(map[string]bool),
+		language:       prefs.PreferredLanguage,
+		affinity:       affinity,
+		unknownRecency: s.cfg.UnknownReleaseRecency,
+	}
 	for _, r := range rules {
-		ruleWeights[r.RuleType] = r.Weight
+		sc.ruleWeights[r.RuleType] = r.Weight
 	}
 
 	// Find max popularity for normalization
-	var maxPop float64
 	for _, m := range movies {
-		if m.Popularity > maxPop {
-			maxPop = m.Popularity
+		if p := scalePopularity(m.Popularity, sc.logScale); p > sc.maxPop {
+			sc.maxPop = p
 		}
 	}
-	if maxPop == 0 {
-		maxPop = 1
+	if sc.maxPop == 0 {
+		sc.maxPop = 1
 	}
 
-	prefGenreSet := make(map[string]bool)
+	// A genre that is both preferred and disliked counts as disliked only.
+	for _, g := range prefs.DislikedGenres {
+		sc.disliked[strings.ToLower(g)] = true
+	}
 	for _, g := range prefs.PreferredGenres {
-		prefGenreSet[strings.ToLower(g)] = true
+		if !sc.disliked[strings.ToLower(g)] {
+			sc.prefGenres[strings.ToLower(g)] = true
+		}
 	}
+	return sc
+}
 
-	var results []models.MovieRecommendation
-	for _, m := range movies {
-		var totalScore float64
-		var reasons []string
-
-		// Popularity score (0–1 normalized)
-		if w, ok := ruleWeights["popularity"]; ok {
-			popScore := m.Popularity / maxPop
-			totalScore += popScore * w
-			if popScore > 0.7 {
-				reasons = append(reasons, "highly popular")
-			}
+// score computes a movie's score. Each active rule contributes its
+// normalized sub-score times its weight.
+func (sc *movieScorer) score(m models.MovieDetail) movieScore {
+	var result movieScore
+	var reasons []string
+	add := func(ruleType string, subScore float64) {
+		w := sc.ruleWeights[ruleType]
+		result.total += subScore * w
+		result.contributions = append(result.contributions, models.RuleContribution{
+			RuleType:     ruleType,
+			Weight:       w,
+			SubScore:     math.Round(subScore*10000) / 10000,
+			Contribution: math.Round(subScore*w*10000) / 10000,
+		})
+	}
+
+	// Popularity score (0–1 normalized)
+	if _, ok := sc.ruleWeights["popularity"]; ok {
+		popScore := scalePopularity(m.Popularity, sc.logScale) / sc.maxPop
+		add("popularity", popScore)
+		if popScore > 0.7 {
+			reasons = append(reasons, "highly popular")
 		}
+	}
 
-		// Recency bonus (movies within the last 2 years get higher score)
-		if w, ok := ruleWeights["recency"]; ok {
-			recencyScore := computeRecencyScore(m.ReleaseDate)
-			totalScore += recencyScore * w
-			if recencyScore > 0.7 {
-				reasons = append(reasons, "recently released")
-			}
+	// Recency bonus (movies within the last 2 years get higher score). A
+	// missing or unparseable release date says nothing about age, so it
+	// gets the configured neutral score rather than that of an old movie
+	if _, ok := sc.ruleWeights["recency"]; ok {
+		recencyScore, known := computeRecencyScore(m.ReleaseDate)
+		if !known {
+			recencyScore = sc.unknownRecency
+		}
+		add("recency", recencyScore)
+		if known && recencyScore > 0.7 {
+			reasons = append(reasons, "recently released")
+		}
+	}
+
+	// Rating score (TMDB vote average 0–10 normalized); movies without
+	// a rating contribute nothing
+	if _, ok := sc.ruleWeights["rating"]; ok {
+		ratingScore := computeRatingScore(m.VoteAverage)
+		add("rating", ratingScore)
+		if ratingScore > 0.7 {
+			reasons = append(reasons, "highly rated")
 		}
+	}
 
-		// Genre match
-		if w, ok := ruleWeights["genre_match"]; ok && len(prefGenreSet) > 0 {
-			genreScore := computeGenreMatchScore(m.Genres, prefGenreSet)
-			totalScore += genreScore * w
-			if genreScore > 0 {
+	// Genre match: preferred genres add to the score, disliked genres
+	// subtract from it by the same weight
+	if _, ok := sc.ruleWeights["genre_match"]; ok {
+		var genreScore float64
+		if len(sc.prefGenres) > 0 {
+			matched := computeGenreMatchScore(m.Genres, sc.prefGenres)
+			genreScore += matched
+			if matched > 0 {
 				reasons = append(reasons, "matches your preferred genres")
 			}
 		}
+		if len(sc.disliked) > 0 {
+			genreScore -= computeGenreMatchScore(m.Genres, sc.disliked)
+		}
+		add("genre_match", genreScore)
+	}
 
-		// Round score to 4 decimal places
-		totalScore = math.Round(totalScore*10000) / 10000
+	// Language match: a flat bonus for movies in the preferred language
+	if _, ok := sc.ruleWeights["language_match"]; ok {
+		var languageScore float64
+		if sc.language != "" && strings.EqualFold(m.Language, sc.language) {
+			languageScore = 1
+			reasons = append(reasons, "in your preferred language")
+		}
+		add("language_match", languageScore)
+	}
 
-		reason := "recommended for you"
-		if len(reasons) > 0 {
-			reason = strings.Join(reasons, ", ")
+	// Interaction affinity: genres of movies the user interacted with
+	if _, ok := sc.ruleWeights["interaction_affinity"]; ok {
+		var affinityScore float64
+		if len(sc.affinity) > 0 {
+			affinityScore = computeAffinityScore(m.Genres, sc.affinity)
+		}
+		add("interaction_affinity", affinityScore)
+		if affinityScore > 0.5 {
+			reasons = append(reasons, "similar to movies you liked")
 		}
+	}
 
-		results = append(results, models.MovieRecommendation{
-			ID:          m.ID,
-			Title:       m.Title,
-			ReleaseDate: m.ReleaseDate,
-			Genres:      m.Genres,
-			Popularity:  m.Popularity,
-			PosterURL:   m.PosterURL,
-			Score:       totalScore,
-			Reason:      reason,
-		})
+	// Round score to 4 decimal places
+	result.total = math.Round(result.total*10000) / 10000
+
+	result.reason = "recommended for you"
+	if len(reasons) > 0 {
+		result.reason = strings.Join(reasons, ", ")
 	}
+	return result
+}
 
-	return results
+// scalePopularity returns popularity as is, or log(1+popularity) when
+// logScale is set. Negative popularity counts as zero.
+func scalePopularity(popularity float64, logScale bool) float64 {
+	popularity = math.Max(popularity, 0)
+	if logScale {
+		return math.Log1p(popularity)
+	}
+	return popularity
 }
 
-func computeRecencyScore(releaseDate string) float64 {
+// computeRecencyScore scores a YYYY-MM-DD release date from 1 (today) to
+// 0 (two or more years ago). known is false when the date is missing or
+// cannot be parsed.
+func computeRecencyScore(releaseDate string) (score float64, known bool) {
 	t, err := time.Parse("2006-01-02", releaseDate)
 	if err != nil {
-		return 0.0
+		return 0, false
 	}
 	daysSince := time.Since(t).Hours() / 24
 	if daysSince < 0 {
 		daysSince = 0
 	}
 	// Score decays linearly over 730 days (2 years)
-	score := 1.0 - (daysSince / 730.0)
+	score = 1.0 - (daysSince / 730.0)
 	if score < 0 {
 		score = 0
 	}
-	return score
+	return score, true
+}
+
+// computeRatingScore normalizes a 0–10 vote average to 0–1. A missing
+// rating scores 0.
+func computeRatingScore(voteAverage *float64) float64 {
+	if voteAverage == nil {
+		return 0.0
+	}
+	return math.Max(0, math.Min(*voteAverage/10.0, 1.0))
+}
+
+// interactionDecay returns the weight of an interaction made at createdAt,
+// halving every halfLife so recent activity outweighs old history.
+// A non-positive halfLife disables decay.
+func interactionDecay(createdAt time.Time, halfLife time.Duration) float64 {
+	if halfLife <= 0 {
+		return 1.0
+	}
+	age := time.Since(createdAt)
+	if age < 0 {
+		age = 0
+	}
+	return math.Pow(0.5, float64(age)/float64(halfLife))
+}
+
+// computeAffinityScore averages the user's affinity over a movie's genres.
+func computeAffinityScore(movieGenres []string, affinity map[string]float64) float64 {
+	if len(movieGenres) == 0 {
+		return 0.0
+	}
+	var total float64
+	for _, g := range movieGenres {
+		total += affinity[strings.ToLower(g)]
+	}
+	return total / float64(len(movieGenres))
+}
+
+// hasRuleType reports whether an active rule of the given type exists.
+func hasRuleType(rules []models.RecommendationRule, ruleType string) bool {
+	for _, r := range rules {
+		if r.RuleType == ruleType {
+			return true
+		}
+	}
+	return false
 }
 
 func computeGenreMatchScore(movieGenres []string, preferredGenres map[string]bool) float64 {
@@ -229,11 +1221,252 @@ func computeGenreMatchScore(movieGenres []string, preferredGenres map[string]boo
 	return float64(matches) / float64(len(movieGenres))
 }
 
+// GetEffectivePreferences returns the preferences the recommender would use
+// for a user, for diagnosing unexpected recommendations.
+func (s *RecommendationService) GetEffectivePreferences(ctx context.Context, userID int) *models.EffectivePreferences {
+	return s.resolveUserPreferences(ctx, userID)
+}
+
+// resolveUserPreferences fetches user preferences, falling back to defaults
+// when the user preference service cannot be reached or returns an error.
+// Users without preferred genres get genres inferred from their positive
+// interactions; explicit preferred genres always win.
+func (s *RecommendationService) resolveUserPreferences(ctx context.Context, userID int) *models.EffectivePreferences {
+	prefs, err := s.fetchUserPreferences(ctx, userID)
+	if err != nil {
+		slog.Warn("could not fetch user preferences, using defaults", "user_id", userID, "error", err)
+		return &models.EffectivePreferences{
+			UserID: userID,
+			Preferences: &models.UserPreference{
+				UserID:          userID,
+				PreferredGenres: []string{},
+				DislikedGenres:  []string{},
+			},
+			FellBackToDefaults: true,
+			FallbackReason:     err.Error(),
+		}
+	}
+	effective := &models.EffectivePreferences{
+		UserID:      userID,
+		Preferences: prefs,
+	}
+	// Cold start only: a user who cleared their preferred genres asked for
+	// no genre bias
+	if !prefs.HasPreferences && len(prefs.PreferredGenres) == 0 && s.cfg.InferredGenreCount > 0 {
+		inferred, err := s.inferPreferredGenres(ctx, userID, prefs.DislikedGenres)
+		if err != nil {
+			slog.Warn("could not infer preferred genres", "user_id", userID, "error", err)
+		} else if len(inferred) > 0 {
+			prefs.PreferredGenres = inferred
+			effective.InferredGenres = inferred
+		}
+	}
+	return effective
+}
+
+// inferPreferredGenres ranks genres by the user's genre affinity and
+// returns the top InferredGenreCount. Genres the user explicitly dislikes
+// are never inferred.
+func (s *RecommendationService) inferPreferredGenres(ctx context.Context, userID int, disliked []string) ([]string, error) {
+	affinity, err := s.genreAffinity(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	dislikedSet := make(map[string]bool, len(disliked))
+	for _, g := range disliked {
+		dislikedSet[strings.ToLower(g)] = true
+	}
+	genreScores := make(map[string]float64)
+	for g, w := range affinity {
+		if !dislikedSet[strings.ToLower(g)] {
+			genreScores[g] = w
+		}
+	}
+
+	ranked := make([]string, 0, len(genreScores))
+	for g := range genreScores {
+		ranked = append(ranked, g)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if genreScores[ranked[i]] != genreScores[ranked[j]] {
+			return genreScores[ranked[i]] > genreScores[ranked[j]]
+		}
+		return ranked[i] < ranked[j]
+	})
+	if len(ranked) > s.cfg.InferredGenreCount {
+		ranked = ranked[:s.cfg.InferredGenreCount]
+	}
+	return ranked, nil
+}
+
+// scoringAffinity returns the user's genre affinity for the
+// interaction_affinity rule: lowercase genres scaled so the strongest is 1,
+// without the genres the user dislikes. It returns nil when the affinity
+// cannot be derived.
+func (s *RecommendationService) scoringAffinity(ctx context.Context, userID int, disliked []string) map[string]float64 {
+	affinity, err := s.genreAffinity(ctx, userID)
+	if err != nil {
+		slog.Warn("could not derive genre affinity", "user_id", userID, "error", err)
+		return nil
+	}
+
+	dislikedSet := make(map[string]bool, len(disliked))
+	for _, g := range disliked {
+		dislikedSet[strings.ToLower(g)] = true
+	}
+	var strongest float64
+	for _, w := range affinity {
+		strongest = math.Max(strongest, w)
+	}
+	if strongest == 0 {
+		return nil
+	}
+
+	scaled := make(map[string]float64, len(affinity))
+	for g, w := range affinity {
+		if g = strings.ToLower(g); !dislikedSet[g] {
+			scaled[g] += w / strongest
+		}
+	}
+	return scaled
+}
+
+// genreAffinity weights each genre by the user's positive interactions with
+// movies of that genre, each decayed by its age. It is cached per user for
+// affinityCacheTTL.
+func (s *RecommendationService) genreAffinity(ctx context.Context, userID int) (map[string]float64, error) {
+	cacheKey := affinityCacheKey(userID)
+	if cached, err := s.cache.Get(ctx, cacheKey); err == nil {
+		var affinity map[string]float64
+		if json.Unmarshal([]byte(cached), &affinity) == nil {
+			return affinity, nil
+		}
+	}
+
+	interactions, err := s.fetchInteractions(ctx, userID, interactionHistoryLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	weights := make(map[int]float64)
+	for _, in := range interactions {
+		if models.PositiveInteractionTypes[in.InteractionType] {
+			weights[in.MovieID] += interactionDecay(in.CreatedAt, s.cfg.InteractionHalfLife)
+		}
+	}
+
+	affinity := make(map[string]float64)
+	if len(weights) > 0 {
+		ids := make([]int, 0, len(weights))
+		for id := range weights {
+			ids = append(ids, id)
+		}
+		movieGenres, err := s.fetchMovieGenres(ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+		for id, genres := range movieGenres {
+			for _, g := range genres {
+				affinity[g] += weights[id]
+			}
+		}
+	}
+
+	if data, err := json.Marshal(affinity); err == nil {
+		s.cache.Set(ctx, cacheKey, string(data), affinityCacheTTL)
+	}
+	return affinity, nil
+}
+
+// affinityCacheKey is the cache key of a user's genre affinity.
+func affinityCacheKey(userID int) string {
+	return fmt.Sprintf("affinity:%d", userID)
+}
+
+// newUpstreamRequest builds a GET to another service carrying the request
+// ID and, when configured, the internal token.
+func (s *RecommendationService) newUpstreamRequest(ctx context.Context, url string) (*http.Request, error) {
+	return s.newUpstreamRequestWithBody(ctx, http.MethodGet, url, nil)
+}
+
+// newUpstreamRequestWithBody is newUpstreamRequest for any method and body.
+func (s *RecommendationService) newUpstreamRequestWithBody(ctx context.Context, method, url string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	requestid.Propagate(req)
+	if s.internalAPIToken != "" {
+		req.Header.Set("X-Internal-Token", s.internalAPIToken)
+	}
+	return req, nil
+}
+
+// fetchInteractions calls the user preference service for the user's
+// most recent interactions, up to limit.
+func (s *RecommendationService) fetchInteractions(ctx context.Context, userID, limit int) ([]models.UserInteraction, error) {
+	url := fmt.Sprintf("%s/api/v1/users/%d/interactions?limit=%d", s.userPreferenceServiceURL, userID, limit)
+
+	req, err := s.newUpstreamRequest(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request to user-preference-service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("user-preference-service returned %d", resp.StatusCode)
+	}
+
+	var result models.InteractionListResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decode interactions: %w", err)
+	}
+	return result.Interactions, nil
+}
+
+// fetchMovieGenres calls the movie service batch genre endpoint.
+func (s *RecommendationService) fetchMovieGenres(ctx context.Context, movieIDs []int) (map[int][]string, error) {
+	ids := make([]string, len(movieIDs))
+	for i, id := range movieIDs {
+		ids[i] = strconv.Itoa(id)
+	}
+	url := fmt.Sprintf("%s/api/v1/movies/genres?ids=%s", s.movieServiceURL, strings.Join(ids, ","))
+
+	req, err := s.newUpstreamRequest(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request to movie-service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("movie-service returned %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Data map[int][]string `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decode movie genres: %w", err)
+	}
+	return result.Data, nil
+}
+
 // fetchUserPreferences calls the user preference service.
 func (s *RecommendationService) fetchUserPreferences(ctx context.Context, userID int) (*models.UserPreference, error) {
 	url := fmt.Sprintf("%s/api/v1/users/%d/preferences", s.userPreferenceServiceURL, userID)
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	req, err := s.newUpstreamRequest(ctx, url)
 	if err != nil {
 		return nil, err
 	}
@@ -256,14 +1489,60 @@ func (s *RecommendationService) fetchUserPreferences(ctx context.Context, userID
 	return &prefs, nil
 }
 
-// fetchMovies retrieves movies from the movie service.
-func (s *RecommendationService) fetchMovies(ctx context.Context, pages int) ([]models.MovieDetail, error) {
+// poolPages is the request's candidate pool size in pages.
+func (s *RecommendationService) poolPages(params models.RecommendationParams) int {
+	if params.PoolPages > 0 {
+		return params.PoolPages
+	}
+	return s.cfg.PoolPages
+}
+
+// candidatePoolCacheKey is the cache key of the candidate pool for the
+// request's year range and pool size.
+func (s *RecommendationService) candidatePoolCacheKey(params models.RecommendationParams) string {
+	return fmt.Sprintf("candidates:%d-%d:%d", params.YearFrom, params.YearTo, s.poolPages(params))
+}
+
+// loadCandidates returns the candidate pool for the given year range,
+// serving it from Redis when available.
+func (s *RecommendationService) loadCandidates(ctx context.Context, params models.RecommendationParams) ([]models.MovieDetail, error) {
+	cacheKey := s.candidatePoolCacheKey(params)
+	if cached, err := s.cache.Get(ctx, cacheKey); err == nil {
+		var movies []models.MovieDetail
+		if json.Unmarshal([]byte(cached), &movies) == nil {
+			slog.Debug("candidate pool cache hit", "key", cacheKey)
+			return movies, nil
+		}
+	}
+
+	movies, err := s.fetchMovies(ctx, s.poolPages(params), params)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(movies) > 0 {
+		if data, err := json.Marshal(movies); err == nil {
+			s.cache.Set(ctx, cacheKey, string(data), candidatePoolTTL)
+		}
+	}
+	return movies, nil
+}
+
+// fetchMovies retrieves movies from the movie service, applying the
+// release year range from params as movie service date filters.
+func (s *RecommendationService) fetchMovies(ctx context.Context, pages int, params models.RecommendationParams) ([]models.MovieDetail, error) {
+	start := time.Now()
 	var allMovies []models.MovieDetail
 
+	dateFilter := ""
+	if from, to := params.ReleaseDateRange(); from != "" || to != "" {
+		dateFilter = fmt.Sprintf("&release_date_from=%s&release_date_to=%s", from, to)
+	}
+
 	for page := 1; page <= pages; page++ {
-		url := fmt.Sprintf("%s/api/v1/movies?page=%d&page_size=20&sort_by=popularity&order=desc", s.movieServiceURL, page)
+		url := fmt.Sprintf("%s/api/v1/movies?page=%d&page_size=20&sort_by=popularity&order=desc%s", s.movieServiceURL, page, dateFilter)
 
-		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		req, err := s.newUpstreamRequest(ctx, url)
 		if err != nil {
 			return nil, err
 		}
@@ -286,42 +1565,88 @@ func (s *RecommendationService) fetchMovies(ctx context.Context, pages int) ([]m
 		}
 		resp.Body.Close()
 
-		// Fetch details for each movie to get genres
-		for _, item := range listResp.Data {
-			detail, err := s.fetchMovieDetail(ctx, item.ID)
-			if err != nil {
-				slog.Warn("could not fetch movie detail, using list data", "movie_id", item.ID, "error", err)
-				allMovies = append(allMovies, models.MovieDetail{
-					ID:          item.ID,
-					Title:       item.Title,
-					ReleaseDate: item.ReleaseDate,
-					Popularity:  item.Popularity,
-					PosterURL:   item.PosterURL,
-				})
-				continue
-			}
-			allMovies = append(allMovies, *detail)
+		// Fetch details for the page's movies to get genres
+		details, err := s.fetchMovieDetails(ctx, listResp.Data)
+		if err != nil {
+			return nil, err
 		}
+		allMovies = append(allMovies, details...)
 
 		if page >= listResp.TotalPages {
 			break
 		}
 	}
 
+	slog.Info("fetched candidate movies", "pages", pages, "movies", len(allMovies),
+		"duration_ms", time.Since(start).Milliseconds())
 	return allMovies, nil
 }
 
-func (s *RecommendationService) fetchMovieDetail(ctx context.Context, movieID int) (*models.MovieDetail, error) {
-	url := fmt.Sprintf("%s/api/v1/movies/%d", s.movieServiceURL, movieID)
+// fetchMovieDetails fetches the details of the listed movies with one
+// batch request. Results keep the list order; a movie missing from the batch,
+// or every movie when the batch request fails, falls back to its list data.
+// It only fails when ctx is done.
+func (s *RecommendationService) fetchMovieDetails(ctx context.Context, items []models.MovieListItem) ([]models.MovieDetail, error) {
+	ids := make([]int, len(items))
+	for i, item := range items {
+		ids[i] = item.ID
+	}
+
+	byID := make(map[int]models.MovieDetail, len(items))
+	batch, err := s.fetchMovieBatch(ctx, ids)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		slog.Warn("could not fetch movie details, using list data", "movies", len(items), "error", err)
+	}
+	for _, detail := range batch {
+		// Recommendations copy the genres; keep them a JSON array, never null
+		if detail.Genres == nil {
+			detail.Genres = []string{}
+		}
+		byID[detail.ID] = detail
+	}
+
+	details := make([]models.MovieDetail, len(items))
+	for i, item := range items {
+		if detail, ok := byID[item.ID]; ok {
+			details[i] = detail
+			continue
+		}
+		details[i] = models.MovieDetail{
+			ID:          item.ID,
+			Title:       item.Title,
+			ReleaseDate: item.ReleaseDate,
+			Genres:      []string{},
+			Popularity:  item.Popularity,
+			VoteAverage: item.VoteAverage,
+			PosterURL:   item.PosterURL,
+		}
+	}
+	return details, nil
+}
+
+// fetchMovieBatch calls the movie service batch detail endpoint.
+func (s *RecommendationService) fetchMovieBatch(ctx context.Context, movieIDs []int) ([]models.MovieDetail, error) {
+	if len(movieIDs) == 0 {
+		return nil, nil
+	}
+	body, err := json.Marshal(movieIDs)
+	if err != nil {
+		return nil, err
+	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	url := fmt.Sprintf("%s/api/v1/movies/batch", s.movieServiceURL)
+	req, err := s.newUpstreamRequestWithBody(ctx, http.MethodPost, url, bytes.NewReader(body))
 	if err != nil {
 		return nil, err
 	}
+	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := s.httpClient.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("request to movie-service: %w", err)
 	}
 	defer resp.Body.Close()
 
@@ -329,14 +1654,131 @@ func (s *RecommendationService) fetchMovieDetail(ctx context.Context, movieID in
 		return nil, fmt.Errorf("movie-service returned %d", resp.StatusCode)
 	}
 
-	var detail models.MovieDetail
-	if err := json.NewDecoder(resp.Body).Decode(&detail); err != nil {
-		return nil, err
+	var result struct {
+		Data []models.MovieDetail `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decode movie batch: %w", err)
+	}
+	return result.Data, nil
+}
+
+// GetStats returns cache effectiveness for ops dashboards.
+func (s *RecommendationService) GetStats() *models.ServiceStats {
+	return &models.ServiceStats{
+		Service: "recommendation-service",
+		Cache:   s.cache.Stats(),
 	}
-	return &detail, nil
 }
 
 // GetRules returns all recommendation rules.
 func (s *RecommendationService) GetRules(ctx context.Context) ([]models.RecommendationRule, error) {
 	return s.repo.GetActiveRules()
 }
+
+// Rule management errors.
+var (
+	ErrInvalidRule  = errors.New("invalid rule")
+	ErrRuleNotFound = errors.New("rule not found")
+	// ErrMovieNotInPool is returned when explaining a movie that is not a
+	// recommendation candidate.
+	ErrMovieNotInPool = errors.New("movie not in candidate pool")
+)
+
+// CreateRule validates and stores a new active rule, then drops cached
+// recommendations so the new weights apply immediately.
+func (s *RecommendationService) CreateRule(ctx context.Context, req models.RuleRequest) (*models.RecommendationRule, error) {
+	rule := &models.RecommendationRule{
+		Name:     strings.TrimSpace(req.Name),
+		Weight:   req.Weight,
+		RuleType: req.RuleType,
+		IsActive: true,
+	}
+	if err := s.validateRule(rule); err != nil {
+		return nil, err
+	}
+	if err := s.repo.CreateRule(rule); err != nil {
+		return nil, fmt.Errorf("create rule: %w", err)
+	}
+	s.invalidateRecommendations(ctx)
+	return rule, nil
+}
+
+// UpdateRule replaces an existing rule. An omitted is_active keeps the
+// rule's current state.
+func (s *RecommendationService) UpdateRule(ctx context.Context, id int, req models.RuleRequest) (*models.RecommendationRule, error) {
+	rule, err := s.repo.GetRule(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRuleNotFound
+		}
+		return nil, fmt.Errorf("get rule: %w", err)
+	}
+
+	rule.Name = strings.TrimSpace(req.Name)
+	rule.Weight = req.Weight
+	rule.RuleType = req.RuleType
+	if req.IsActive != nil {
+		rule.IsActive = *req.IsActive
+	}
+	if err := s.validateRule(rule); err != nil {
+		return nil, err
+	}
+	if err := s.repo.UpdateRule(rule); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRuleNotFound
+		}
+		return nil, fmt.Errorf("update rule: %w", err)
+	}
+	s.invalidateRecommendations(ctx)
+	return rule, nil
+}
+
+// DeactivateRule stops a rule from contributing to scores. The row is kept
+// so the rule can be reactivated with UpdateRule.
+func (s *RecommendationService) DeactivateRule(ctx context.Context, id int) error {
+	if err := s.repo.DeactivateRule(id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrRuleNotFound
+		}
+		return fmt.Errorf("deactivate rule: %w", err)
+	}
+	s.invalidateRecommendations(ctx)
+	return nil
+}
+
+// validateRule checks the rule's fields and that an active rule does not
+// share its type with another active rule, since scoring keys weights by
+// rule type.
+func (s *RecommendationService) validateRule(rule *models.RecommendationRule) error {
+	if rule.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidRule)
+	}
+	if rule.Weight < 0 || rule.Weight > 1 {
+		return fmt.Errorf("%w: weight must be between 0 and 1", ErrInvalidRule)
+	}
+	if !models.ValidRuleTypes[rule.RuleType] {
+		return fmt.Errorf("%w: unknown rule_type %q", ErrInvalidRule, rule.RuleType)
+	}
+	if !rule.IsActive {
+		return nil
+	}
+
+	active, err := s.repo.GetActiveRules()
+	if err != nil {
+		return fmt.Errorf("get rules: %w", err)
+	}
+	for _, r := range active {
+		if r.RuleType == rule.RuleType && r.ID != rule.ID {
+			return fmt.Errorf("%w: an active %s rule already exists (id %d)", ErrInvalidRule, rule.RuleType, r.ID)
+		}
+	}
+	return nil
+}
+
+// invalidateRecommendations drops every cached recommendation list.
+func (s *RecommendationService) invalidateRecommendations(ctx context.Context) {
+	if _, err := s.cache.Invalidate(ctx, "recommendations:*"); err != nil {
+		slog.Error("failed to invalidate recommendations cache", "error", err)
+	}
+}
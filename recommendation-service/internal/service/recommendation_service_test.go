@@ -0,0 +1,279 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"movie-discovery-recommendation-service/internal/cache"
+	"movie-discovery-recommendation-service/internal/config"
+	"movie-discovery-recommendation-service/internal/models"
+	"movie-discovery-recommendation-service/internal/repository"
+)
+
+func newTestService(cfg config.RecommendationConfig) *RecommendationService {
+	return NewRecommendationService(nil, cache.Noop{}, "", "", cfg)
+}
+
+// subScore returns the sub-score rule contributed to s.
+func subScore(t *testing.T, s movieScore, rule string) float64 {
+	t.Helper()
+	for _, c := range s.contributions {
+		if c.RuleType == rule {
+			return c.SubScore
+		}
+	}
+	t.Fatalf("no %s contribution in %+v", rule, s.contributions)
+	return 0
+}
+
+func TestGenreMatchDislikeWins(t *testing.T) {
+	rules := []models.RecommendationRule{{RuleType: "genre_match", Weight: 1}}
+	cases := []struct {
+		name      string
+		preferred []string
+		disliked  []string
+		genres    []string
+		want      float64
+		// wantReason is whether the movie is said to match preferred genres
+		wantReason bool
+	}{
+		{"preferred", []string{"Action"}, nil, []string{"Action", "Drama"}, 0.5, true},
+		{"disliked", nil, []string{"Horror"}, []string{"Horror"}, -1, false},
+		{"preferred and disliked", []string{"Horror"}, []string{"Horror"}, []string{"Horror"}, -1, false},
+		{"preferred and disliked in another case", []string{"horror"}, []string{"HORROR"}, []string{"Horror", "Drama"}, -0.5, false},
+		{"one of each", []string{"Action"}, []string{"Horror"}, []string{"Action", "Horror"}, 0, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			movie := models.MovieDetail{ID: 1, Genres: tc.genres}
+			prefs := &models.UserPreference{PreferredGenres: tc.preferred, DislikedGenres: tc.disliked}
+			sc := newTestService(config.RecommendationConfig{}).newMovieScorer([]models.MovieDetail{movie}, prefs, nil, rules)
+
+			s := sc.score(movie)
+			if got := subScore(t, s, "genre_match"); got != tc.want {
+				t.Errorf("genre_match sub-score %v, want %v", got, tc.want)
+			}
+			if got := strings.Contains(s.reason, "preferred genres"); got != tc.wantReason {
+				t.Errorf("reason %q, want preferred-genre match %v", s.reason, tc.wantReason)
+			}
+		})
+	}
+}
+
+func TestComputeRecencyScore(t *testing.T) {
+	day := func(daysAgo int) string {
+		return time.Now().AddDate(0, 0, -daysAgo).Format("2006-01-02")
+	}
+	cases := []struct {
+		name      string
+		date      string
+		want      float64
+		wantKnown bool
+	}{
+		{"empty", "", 0, false},
+		{"unparseable", "not-a-date", 0, false},
+		{"wrong layout", "2024/01/02", 0, false},
+		{"today", day(0), 1, true},
+		{"a year ago", day(365), 0.5, true},
+		{"long ago", day(5 * 365), 0, true},
+		{"future", day(-30), 1, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, known := computeRecencyScore(tc.date)
+			if known != tc.wantKnown {
+				t.Fatalf("known = %v, want %v", known, tc.wantKnown)
+			}
+			// Allow for the fraction of a day elapsed since midnight
+			if math.Abs(got-tc.want) > 0.01 {
+				t.Errorf("score %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestUnknownReleaseDateGetsNeutralRecency(t *testing.T) {
+	const neutral = 0.4
+	rules := []models.RecommendationRule{{RuleType: "recency", Weight: 1}}
+	svc := newTestService(config.RecommendationConfig{UnknownReleaseRecency: neutral})
+
+	cases := []struct {
+		date string
+		want float64
+	}{
+		{"", neutral},
+		{"sometime in 1999", neutral},
+		{time.Now().Format("2006-01-02"), 1},
+	}
+	for _, tc := range cases {
+		t.Run(tc.date, func(t *testing.T) {
+			movie := models.MovieDetail{ID: 1, ReleaseDate: tc.date}
+			sc := svc.newMovieScorer([]models.MovieDetail{movie}, &models.UserPreference{}, nil, rules)
+
+			s := sc.score(movie)
+			if got := subScore(t, s, "recency"); math.Abs(got-tc.want) > 0.01 {
+				t.Errorf("recency sub-score %v, want %v", got, tc.want)
+			}
+			if tc.want == neutral && strings.Contains(s.reason, "recently released") {
+				t.Errorf("reason %q for a movie without a usable date", s.reason)
+			}
+		})
+	}
+}
+
+func TestFetchMovieDetailsNeverReturnsNullGenres(t *testing.T) {
+	// Movie 1 has null genres, movie 2 omits them and movie 3 is missing
+	// from the batch, so it falls back to its list data
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"data":[{"id":1,"title":"A","genres":null},{"id":2,"title":"B"}]}`)
+	}))
+	defer srv.Close()
+	svc := NewRecommendationService(nil, cache.Noop{}, srv.URL, "", config.RecommendationConfig{})
+
+	details, err := svc.fetchMovieDetails(context.Background(), []models.MovieListItem{{ID: 1}, {ID: 2}, {ID: 3}})
+	if err != nil {
+		t.Fatalf("fetchMovieDetails: %v", err)
+	}
+	if len(details) != 3 {
+		t.Fatalf("got %d details, want 3", len(details))
+	}
+	for _, d := range details {
+		data, err := json.Marshal(d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(data), `"genres":[]`) {
+			t.Errorf("movie %d serialized as %s, want \"genres\":[]", d.ID, data)
+		}
+	}
+}
+
+// snapshotStore serves fixed snapshots. Methods the tests do not use are
+// left to the embedded interface.
+type snapshotStore struct {
+	repository.RecommendationStore
+	snapshots []models.RecommendationSnapshot
+}
+
+func (f snapshotStore) GetSnapshots(userID, limit int) ([]models.RecommendationSnapshot, error) {
+	return f.snapshots[:min(limit, len(f.snapshots))], nil
+}
+
+// mapCache is a read-only cache backed by a map.
+type mapCache struct {
+	cache.Noop
+	values map[string]string
+}
+
+func (c mapCache) Get(ctx context.Context, key string) (string, error) {
+	if v, ok := c.values[key]; ok {
+		return v, nil
+	}
+	return "", cache.ErrMiss
+}
+
+func TestSnapshotFallbackAppliesRequestFilters(t *testing.T) {
+	rating := func(v float64) *float64 { return &v }
+	pool := []models.MovieDetail{
+		{ID: 1, Genres: []string{"Horror"}, ReleaseDate: "2021-05-01", VoteAverage: rating(8)},
+		{ID: 2, Genres: []string{"Drama"}, ReleaseDate: "1995-05-01", VoteAverage: rating(8)},
+		{ID: 3, Genres: []string{"Drama"}, ReleaseDate: "2020-05-01", VoteAverage: rating(5)},
+		{ID: 4, Genres: []string{"Comedy"}, ReleaseDate: "2019-05-01", VoteAverage: rating(9)},
+	}
+	data, err := json.Marshal(pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Movie 5 is not in any cached pool, so nothing is known about it
+	var snapshots []models.RecommendationSnapshot
+	for i, id := range []int{1, 2, 3, 4, 5} {
+		snapshots = append(snapshots, models.RecommendationSnapshot{MovieID: id, Score: 1 - float64(i)/10})
+	}
+
+	prefsUp := true
+	prefs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !prefsUp {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		io.WriteString(w, `{"min_rating":7}`)
+	}))
+	defer prefs.Close()
+
+	cfg := config.RecommendationConfig{PoolPages: 1, MaxSnapshotsPerUser: 10, BlockedGenres: []string{"Horror"}}
+	svc := NewRecommendationService(snapshotStore{snapshots: snapshots},
+		mapCache{values: map[string]string{"candidates:0-0:1": string(data)}}, "", prefs.URL, cfg)
+
+	cases := []struct {
+		name   string
+		params models.RecommendationParams
+		want   []int
+	}{
+		{"min rating only", models.RecommendationParams{Limit: 10}, []int{1, 2, 4}},
+		{"blocklist", models.RecommendationParams{Limit: 10, Family: true}, []int{2, 4}},
+		{"years", models.RecommendationParams{Limit: 10, YearFrom: 2000}, []int{1, 4}},
+		{"seen", models.RecommendationParams{Limit: 10, Seen: []int{1}}, []int{2, 4}},
+		{"ignore min rating", models.RecommendationParams{Limit: 10, IgnoreMinRating: true}, []int{1, 2, 3, 4, 5}},
+		{"offset and limit", models.RecommendationParams{Limit: 1, Offset: 1}, []int{2}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, ok := svc.snapshotRecommendations(context.Background(), 1, tc.params)
+			if !ok {
+				t.Fatal("no stale response")
+			}
+			var got []int
+			for _, rec := range resp.Recommendations {
+				got = append(got, rec.ID)
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("got movies %v, want %v", got, tc.want)
+			}
+			if !resp.Stale {
+				t.Error("response not marked stale")
+			}
+		})
+	}
+
+	// min_rating cannot be checked, so no stale response is served
+	prefsUp = false
+	if _, ok := svc.snapshotRecommendations(context.Background(), 1, models.RecommendationParams{Limit: 10}); ok {
+		t.Error("stale response served without the user's min_rating")
+	}
+}
+
+func TestIsDefaultRanking(t *testing.T) {
+	none := []string{}
+	cases := []struct {
+		name   string
+		params models.RecommendationParams
+		want   bool
+	}{
+		{"default", models.RecommendationParams{Limit: 20}, true},
+		{"display sort and cache options", models.RecommendationParams{Limit: 20, DisplaySort: models.DisplaySortTitle, Fresh: true, SkipCacheWrite: true}, true},
+		{"years", models.RecommendationParams{YearFrom: 2000}, false},
+		{"pool pages", models.RecommendationParams{PoolPages: 3}, false},
+		{"diversify", models.RecommendationParams{Diversify: true}, false},
+		{"diversify by", models.RecommendationParams{DiversifyBy: "genre"}, false},
+		{"family", models.RecommendationParams{Family: true}, false},
+		{"ignore min rating", models.RecommendationParams{IgnoreMinRating: true}, false},
+		{"only new", models.RecommendationParams{OnlyNew: true}, false},
+		{"blocklist override", models.RecommendationParams{BlockedGenresOverride: &none}, false},
+		{"seen", models.RecommendationParams{Seen: []int{1}}, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.params.IsDefaultRanking(); got != tc.want {
+				t.Errorf("IsDefaultRanking() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"log/slog"
 	"os"
 	"os/signal"
@@ -12,15 +13,18 @@ import (
 	"github.com/gofiber/fiber/v3/middleware/logger"
 	"github.com/gofiber/fiber/v3/middleware/recover"
 
+	"movie-discovery-user-preference-service/internal/cache"
 	"movie-discovery-user-preference-service/internal/config"
 	"movie-discovery-user-preference-service/internal/database"
 	"movie-discovery-user-preference-service/internal/handler"
+	"movie-discovery-user-preference-service/internal/metrics"
 	"movie-discovery-user-preference-service/internal/repository"
+	"movie-discovery-user-preference-service/internal/requestid"
 	"movie-discovery-user-preference-service/internal/service"
 )
 
 func main() {
-	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo})))
+	slog.SetDefault(slog.New(requestid.NewLogHandler(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))))
 
 	cfg, err := config.Load()
 	if err != nil {
@@ -40,7 +44,7 @@ func main() {
 	}
 
 	repo := repository.NewUserRepository(db)
-	svc := service.NewUserService(repo, rdb)
+	svc := service.NewUserService(repo, cache.New(rdb), cfg.RecommendationServiceURL, cfg.MovieServiceURL, cfg.InternalAPIToken, cfg.MaxPreferenceGenres, cfg.UpstreamTimeout)
 	h := handler.NewUserHandler(svc)
 
 	app := fiber.New(fiber.Config{
@@ -56,7 +60,9 @@ func main() {
 	})
 
 	app.Use(recover.New())
-	app.Use(logger.New())
+	app.Use(requestid.Middleware())
+	app.Use(metrics.Middleware())
+	app.Use(logger.New(logger.Config{Format: requestid.LogFormat}))
 	app.Use(cors.New())
 
 	swaggerYAML, err := os.ReadFile("docs/swagger.yaml")
@@ -66,20 +72,49 @@ func main() {
 		handler.RegisterSwagger(app, swaggerYAML)
 	}
 
+	// Prometheus metrics
+	app.Get(metrics.Path, metrics.Handler())
+
 	api := app.Group("/api/v1")
 	api.Get("/health", h.Health)
+	readiness := handler.NewReadinessHandler(db, rdb, false)
+	api.Get("/health/ready", readiness.Ready)
+
+	// Kubernetes-style probes: liveness never checks dependencies,
+	// readiness pings them
+	app.Get("/healthz", h.Health)
+	app.Get("/readyz", readiness.Ready)
+
+	// With REQUIRE_INTERNAL_TOKEN every route registered below also needs
+	// the internal token; health, metrics and docs above stay open.
+	if cfg.RequireInternalToken {
+		app.Use(handler.RequireInternalToken(cfg.InternalAPIToken))
+	}
 
 	// User management
 	api.Post("/users", h.CreateUser)
+	api.Post("/users/exists", h.CheckUsersExist)
 	api.Get("/users/:id", h.GetUser)
+	api.Patch("/users/:id", h.UpdateUser)
+	api.Delete("/users/:id", h.DeleteUser)
 
 	// Preferences
 	api.Post("/users/:id/preferences", h.SetPreference)
+	api.Patch("/users/:id/preferences", h.PatchPreference)
 	api.Get("/users/:id/preferences", h.GetPreference)
 
 	// Interactions
 	api.Post("/users/:id/interactions", h.RecordInteraction)
 	api.Get("/users/:id/interactions", h.GetInteractions)
+	api.Get("/users/:id/interactions/stats", h.GetInteractionStats)
+	api.Delete("/users/:id/interactions/all", h.ClearInteractions)
+
+	// Aggregates across all users
+	api.Get("/movies/popular-among-users", h.GetPopularMovies)
+
+	// Internal service-to-service routes (not exposed via the gateway)
+	internal := app.Group("/internal", handler.RequireInternalToken(cfg.InternalAPIToken))
+	internal.Get("/stats", h.Stats)
 
 	// Graceful shutdown
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
@@ -96,8 +131,14 @@ func main() {
 	<-ctx.Done()
 	slog.Info("shutting down user preference service...")
 
-	// Shutdown HTTP server first (stop accepting new requests)
-	if err := app.Shutdown(); err != nil {
+	// Stop accepting new requests and drain in-flight ones, bounded by
+	// the shutdown timeout
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := app.ShutdownWithContext(shutdownCtx); errors.Is(err, context.DeadlineExceeded) {
+		slog.Warn("shutdown deadline reached with requests still in flight",
+			"timeout", cfg.ShutdownTimeout, "open_connections", app.Server().GetOpenConnectionsCount())
+	} else if err != nil {
 		slog.Error("error shutting down HTTP server", "error", err)
 	}
 	slog.Info("HTTP server stopped")
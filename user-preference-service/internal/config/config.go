@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -12,6 +13,23 @@ type Config struct {
 	DB    DBConfig
 	Redis RedisConfig
 	Port  string
+	// RecommendationServiceURL receives preference-change notifications;
+	// empty disables them.
+	RecommendationServiceURL string
+	// MovieServiceURL is used to enrich aggregate results with movie titles.
+	MovieServiceURL string
+	// UpstreamTimeout bounds each call to the recommendation and movie
+	// services.
+	UpstreamTimeout  time.Duration
+	InternalAPIToken string
+	// RequireInternalToken extends the internal token check to every route
+	// except health, metrics and docs.
+	RequireInternalToken bool
+	// MaxPreferenceGenres caps the preferred and disliked genre lists.
+	MaxPreferenceGenres int
+	// ShutdownTimeout bounds graceful shutdown, including draining
+	// in-flight requests.
+	ShutdownTimeout time.Duration
 }
 
 type DBConfig struct {
@@ -22,6 +40,11 @@ type DBConfig struct {
 	DBName      string
 	SSLMode     string
 	SSLRootCert string
+	// MaxOpenConns and MaxIdleConns size the connection pool;
+	// ConnMaxLifetime recycles connections after that long (0 = never).
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
 }
 
 func (d DBConfig) DSN() string {
@@ -45,24 +68,50 @@ func Load() (*Config, error) {
 	_ = godotenv.Load()
 
 	dbPort, _ := strconv.Atoi(getEnv("DB_PORT", "5432"))
+	dbMaxOpen, _ := strconv.Atoi(getEnv("DB_MAX_OPEN_CONNS", "25"))
+	dbMaxIdle, _ := strconv.Atoi(getEnv("DB_MAX_IDLE_CONNS", "10"))
+	dbConnLifetime, err := time.ParseDuration(getEnv("DB_CONN_MAX_LIFETIME", "0"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid DB_CONN_MAX_LIFETIME: %w", err)
+	}
 	redisDB, _ := strconv.Atoi(getEnv("REDIS_DB", "1"))
+	maxGenres, _ := strconv.Atoi(getEnv("MAX_PREFERENCE_GENRES", "50"))
+	upstreamTimeout, _ := strconv.Atoi(getEnv("UPSTREAM_TIMEOUT_SECONDS", "5"))
+	internalAPIToken := getEnv("INTERNAL_API_TOKEN", "")
+	requireInternalToken, _ := strconv.ParseBool(getEnv("REQUIRE_INTERNAL_TOKEN", "false"))
+
+	if requireInternalToken && internalAPIToken == "" {
+		return nil, fmt.Errorf("REQUIRE_INTERNAL_TOKEN is set but INTERNAL_API_TOKEN is empty")
+	}
+
+	shutdownTimeout, _ := strconv.Atoi(getEnv("SHUTDOWN_TIMEOUT_SECONDS", "15"))
 
 	return &Config{
 		DB: DBConfig{
-			Host:        getEnv("DB_HOST", "localhost"),
-			Port:        dbPort,
-			User:        getEnv("DB_USER", "postgres"),
-			Password:    getEnv("DB_PASSWORD", "postgres"),
-			DBName:      getEnv("DB_NAME", "user_preference_service"),
-			SSLMode:     getEnv("DB_SSLMODE", "verify-ca"),
-			SSLRootCert: getEnv("DB_SSLROOTCERT", ""),
+			Host:            getEnv("DB_HOST", "localhost"),
+			Port:            dbPort,
+			User:            getEnv("DB_USER", "postgres"),
+			Password:        getEnv("DB_PASSWORD", "postgres"),
+			DBName:          getEnv("DB_NAME", "user_preference_service"),
+			SSLMode:         getEnv("DB_SSLMODE", "verify-ca"),
+			SSLRootCert:     getEnv("DB_SSLROOTCERT", ""),
+			MaxOpenConns:    dbMaxOpen,
+			MaxIdleConns:    dbMaxIdle,
+			ConnMaxLifetime: dbConnLifetime,
 		},
 		Redis: RedisConfig{
 			Addr:     getEnv("REDIS_ADDR", "127.0.0.1:6379"),
 			Password: getEnv("REDIS_PASSWORD", ""),
 			DB:       redisDB,
 		},
-		Port: getEnv("SERVER_PORT", "8082"),
+		Port:                     getEnv("SERVER_PORT", "8082"),
+		ShutdownTimeout:          time.Duration(shutdownTimeout) * time.Second,
+		RecommendationServiceURL: getEnv("RECOMMENDATION_SERVICE_URL", ""),
+		MovieServiceURL:          getEnv("MOVIE_SERVICE_URL", "http://localhost:8081"),
+		UpstreamTimeout:          time.Duration(upstreamTimeout) * time.Second,
+		InternalAPIToken:         internalAPIToken,
+		RequireInternalToken:     requireInternalToken,
+		MaxPreferenceGenres:      maxGenres,
 	}, nil
 }
 
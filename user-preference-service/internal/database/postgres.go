@@ -20,8 +20,9 @@ func NewPostgres(cfg config.DBConfig) (*sql.DB, error) {
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(10)
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
 
 	slog.Info("connected to PostgreSQL", "db", cfg.DBName)
 
@@ -59,6 +60,14 @@ func runMigrations(db *sql.DB) error {
 		`CREATE INDEX IF NOT EXISTS idx_user_interactions_user_id ON user_interactions(user_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_user_interactions_movie_id ON user_interactions(movie_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_user_preferences_user_id ON user_preferences(user_id)`,
+		`ALTER TABLE user_preferences ADD COLUMN IF NOT EXISTS disliked_genres TEXT[] DEFAULT '{}'`,
+		// Collapse duplicate interactions (keeping the latest) so the
+		// unique index below can be built
+		`DELETE FROM user_interactions a USING user_interactions b
+		 WHERE a.user_id = b.user_id AND a.movie_id = b.movie_id
+		   AND a.interaction_type = b.interaction_type AND a.id < b.id`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_user_interactions_unique
+		 ON user_interactions(user_id, movie_id, interaction_type)`,
 	}
 
 	for _, m := range migrations {
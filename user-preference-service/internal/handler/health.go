@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// readinessCacheTTL lets rapid probes reuse a recent dependency check.
+	readinessCacheTTL = 2 * time.Second
+	// dependencyPingTimeout bounds each dependency ping.
+	dependencyPingTimeout = 1 * time.Second
+)
+
+// ReadinessStatus is the response body of the readiness check.
+type ReadinessStatus struct {
+	Status       string            `json:"status"`
+	Service      string            `json:"service"`
+	Dependencies map[string]string `json:"dependencies"`
+}
+
+// ReadinessHandler checks that PostgreSQL and Redis are reachable.
+type ReadinessHandler struct {
+	db            *sql.DB
+	rdb           *redis.Client
+	redisRequired bool
+
+	mu        sync.Mutex
+	checkedAt time.Time
+	last      ReadinessStatus
+}
+
+// NewReadinessHandler creates a new ReadinessHandler. When redisRequired is
+// false, an unreachable Redis is reported but does not fail readiness.
+func NewReadinessHandler(db *sql.DB, rdb *redis.Client, redisRequired bool) *ReadinessHandler {
+	return &ReadinessHandler{db: db, rdb: rdb, redisRequired: redisRequired}
+}
+
+// Ready reports dependency status, returning 503 when a required
+// dependency is unreachable.
+func (h *ReadinessHandler) Ready(c fiber.Ctx) error {
+	status := h.check(c.Context())
+	if status.Status != "ok" {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(status)
+	}
+	return c.JSON(status)
+}
+
+// check pings dependencies, reusing the previous result within
+// readinessCacheTTL. Concurrent probes wait for the in-flight check.
+func (h *ReadinessHandler) check(ctx context.Context) ReadinessStatus {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !h.checkedAt.IsZero() && time.Since(h.checkedAt) < readinessCacheTTL {
+		return h.last
+	}
+
+	status := ReadinessStatus{
+		Status:       "ok",
+		Service:      "user-preference-service",
+		Dependencies: map[string]string{},
+	}
+
+	status.Dependencies["postgres"] = ping(ctx, h.db.PingContext)
+	if status.Dependencies["postgres"] != "up" {
+		status.Status = "unavailable"
+	}
+
+	if h.rdb == nil {
+		status.Dependencies["redis"] = "disabled"
+	} else {
+		status.Dependencies["redis"] = ping(ctx, func(ctx context.Context) error {
+			return h.rdb.Ping(ctx).Err()
+		})
+	}
+	if h.redisRequired && status.Dependencies["redis"] != "up" {
+		status.Status = "unavailable"
+	}
+
+	h.last = status
+	h.checkedAt = time.Now()
+	return status
+}
+
+// ping runs fn with dependencyPingTimeout and reports "up" or "down".
+func ping(ctx context.Context, fn func(context.Context) error) string {
+	ctx, cancel := context.WithTimeout(ctx, dependencyPingTimeout)
+	defer cancel()
+	if err := fn(ctx); err != nil {
+		return "down"
+	}
+	return "up"
+}
@@ -0,0 +1,24 @@
+package handler
+
+import (
+	"crypto/subtle"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// InternalTokenHeader carries the shared secret on service-to-service calls.
+const InternalTokenHeader = "X-Internal-Token"
+
+// RequireInternalToken rejects requests whose internal token header does not
+// match token. An empty token disables the check (local development).
+func RequireInternalToken(token string) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		if token == "" {
+			return c.Next()
+		}
+		if subtle.ConstantTimeCompare([]byte(c.Get(InternalTokenHeader)), []byte(token)) != 1 {
+			return c.Status(fiber.StatusUnauthorized).JSON(ErrorResponse{Error: "invalid internal token"})
+		}
+		return c.Next()
+	}
+}
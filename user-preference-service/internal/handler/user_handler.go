@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"errors"
 	"log/slog"
 	"strconv"
 
@@ -30,6 +31,17 @@ func (h *UserHandler) Health(c fiber.Ctx) error {
 	})
 }
 
+// Stats returns cache and user statistics.
+// GET /internal/stats
+func (h *UserHandler) Stats(c fiber.Ctx) error {
+	stats, err := h.svc.GetStats()
+	if err != nil {
+		slog.Error("failed to get stats", "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{Error: "failed to get stats"})
+	}
+	return c.JSON(stats)
+}
+
 // CreateUser creates a new user.
 func (h *UserHandler) CreateUser(c fiber.Ctx) error {
 	var req models.CreateUserRequest
@@ -39,8 +51,14 @@ func (h *UserHandler) CreateUser(c fiber.Ctx) error {
 
 	user, err := h.svc.CreateUser(req)
 	if err != nil {
+		if errors.Is(err, service.ErrInvalidUser) {
+			return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{Error: err.Error()})
+		}
+		if errors.Is(err, service.ErrUserConflict) {
+			return c.Status(fiber.StatusConflict).JSON(ErrorResponse{Error: err.Error()})
+		}
 		slog.Error("failed to create user", "error", err)
-		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{Error: err.Error()})
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{Error: "failed to create user"})
 	}
 
 	return c.Status(fiber.StatusCreated).JSON(user)
@@ -64,6 +82,76 @@ func (h *UserHandler) GetUser(c fiber.Ctx) error {
 	return c.JSON(user)
 }
 
+// UpdateUser changes a user's username and/or email.
+// PATCH /api/v1/users/:id
+func (h *UserHandler) UpdateUser(c fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{Error: "invalid user ID"})
+	}
+
+	var req models.UpdateUserRequest
+	if err := c.Bind().JSON(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{Error: "invalid request body"})
+	}
+
+	user, err := h.svc.UpdateUser(id, req)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidUser) {
+			return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{Error: err.Error()})
+		}
+		if errors.Is(err, service.ErrUserConflict) {
+			return c.Status(fiber.StatusConflict).JSON(ErrorResponse{Error: err.Error()})
+		}
+		if err.Error() == "user not found" {
+			return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{Error: "user not found"})
+		}
+		slog.Error("failed to update user", "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{Error: "failed to update user"})
+	}
+
+	return c.JSON(user)
+}
+
+// DeleteUser deletes a user with their preferences and interactions.
+// DELETE /api/v1/users/:id
+func (h *UserHandler) DeleteUser(c fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{Error: "invalid user ID"})
+	}
+
+	if err := h.svc.DeleteUser(id); err != nil {
+		if err.Error() == "user not found" {
+			return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{Error: "user not found"})
+		}
+		slog.Error("failed to delete user", "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{Error: "failed to delete user"})
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// CheckUsersExist reports which of a batch of user IDs exist.
+// POST /api/v1/users/exists
+func (h *UserHandler) CheckUsersExist(c fiber.Ctx) error {
+	var req models.UserExistsRequest
+	if err := c.Bind().JSON(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{Error: "invalid request body"})
+	}
+
+	resp, err := h.svc.CheckUsersExist(req.IDs)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidUserIDs) {
+			return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{Error: err.Error()})
+		}
+		slog.Error("failed to check users exist", "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{Error: "failed to check users"})
+	}
+
+	return c.JSON(resp)
+}
+
 // SetPreference sets or updates user preferences.
 func (h *UserHandler) SetPreference(c fiber.Ctx) error {
 	id, err := strconv.Atoi(c.Params("id"))
@@ -78,6 +166,9 @@ func (h *UserHandler) SetPreference(c fiber.Ctx) error {
 
 	pref, err := h.svc.SetPreference(id, req)
 	if err != nil {
+		if errors.Is(err, service.ErrInvalidPreference) {
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(ErrorResponse{Error: err.Error()})
+		}
 		if err.Error() == "user not found" {
 			return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{Error: "user not found"})
 		}
@@ -88,6 +179,33 @@ func (h *UserHandler) SetPreference(c fiber.Ctx) error {
 	return c.JSON(pref)
 }
 
+// PatchPreference updates only the preference fields present in the body.
+func (h *UserHandler) PatchPreference(c fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{Error: "invalid user ID"})
+	}
+
+	var req models.PatchPreferenceRequest
+	if err := c.Bind().JSON(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{Error: "invalid request body"})
+	}
+
+	pref, err := h.svc.PatchPreference(id, req)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidPreference) {
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(ErrorResponse{Error: err.Error()})
+		}
+		if err.Error() == "user not found" {
+			return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{Error: "user not found"})
+		}
+		slog.Error("failed to patch preference", "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{Error: "failed to update preferences"})
+	}
+
+	return c.JSON(pref)
+}
+
 // GetPreference returns user preferences.
 func (h *UserHandler) GetPreference(c fiber.Ctx) error {
 	id, err := strconv.Atoi(c.Params("id"))
@@ -137,7 +255,15 @@ func (h *UserHandler) GetInteractions(c fiber.Ctx) error {
 
 	limit := fiber.Query(c, "limit", 50)
 
-	interactions, err := h.svc.GetInteractions(id, limit)
+	movieID := 0
+	if v := c.Query("movie_id"); v != "" {
+		movieID, err = strconv.Atoi(v)
+		if err != nil || movieID <= 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{Error: "invalid movie_id"})
+		}
+	}
+
+	interactions, err := h.svc.GetInteractions(id, movieID, limit)
 	if err != nil {
 		slog.Error("failed to get interactions", "error", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{Error: "failed to get interactions"})
@@ -152,3 +278,58 @@ func (h *UserHandler) GetInteractions(c fiber.Ctx) error {
 		"interactions": interactions,
 	})
 }
+
+// ClearInteractions deletes a user's whole interaction history.
+// DELETE /api/v1/users/:id/interactions/all
+func (h *UserHandler) ClearInteractions(c fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{Error: "invalid user ID"})
+	}
+
+	deleted, err := h.svc.ClearInteractions(id)
+	if err != nil {
+		if err.Error() == "user not found" {
+			return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{Error: "user not found"})
+		}
+		slog.Error("failed to clear interactions", "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{Error: "failed to clear interactions"})
+	}
+
+	return c.JSON(fiber.Map{
+		"user_id": id,
+		"deleted": deleted,
+	})
+}
+
+// GetInteractionStats summarizes a user's interactions.
+// GET /api/v1/users/:id/interactions/stats
+func (h *UserHandler) GetInteractionStats(c fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{Error: "invalid user ID"})
+	}
+
+	stats, err := h.svc.GetInteractionStats(id)
+	if err != nil {
+		slog.Error("failed to get interaction stats", "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{Error: "failed to get interaction stats"})
+	}
+
+	return c.JSON(stats)
+}
+
+// GetPopularMovies returns the movies most interacted with across all users.
+func (h *UserHandler) GetPopularMovies(c fiber.Ctx) error {
+	limit := fiber.Query(c, "limit", 20)
+
+	movies, err := h.svc.GetPopularMovies(limit)
+	if err != nil {
+		slog.Error("failed to get popular movies", "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{Error: "failed to get popular movies"})
+	}
+
+	return c.JSON(fiber.Map{
+		"data": movies,
+	})
+}
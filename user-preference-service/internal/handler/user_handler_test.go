@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v3"
+
+	"movie-discovery-user-preference-service/internal/cache"
+	"movie-discovery-user-preference-service/internal/models"
+	"movie-discovery-user-preference-service/internal/repository"
+	"movie-discovery-user-preference-service/internal/service"
+)
+
+// prefStore is a UserStore holding one user, 1, who dislikes Horror.
+// Methods the tests do not use are left to the embedded interface.
+type prefStore struct {
+	repository.UserStore
+}
+
+func (prefStore) GetUser(id int) (*models.User, error) {
+	if id != 1 {
+		return nil, sql.ErrNoRows
+	}
+	return &models.User{ID: 1, Username: "jane", Email: "jane@example.com"}, nil
+}
+
+func (prefStore) GetPreference(userID int) (*models.UserPreference, error) {
+	return &models.UserPreference{UserID: userID, PreferredGenres: []string{}, DislikedGenres: []string{"Horror"}}, nil
+}
+
+func TestPreferenceGenreOverlapIsUnprocessable(t *testing.T) {
+	h := NewUserHandler(service.NewUserService(prefStore{}, cache.Noop{}, "", "", "", 0, 0))
+	app := fiber.New()
+	app.Post("/users/:id/preferences", h.SetPreference)
+	app.Patch("/users/:id/preferences", h.PatchPreference)
+
+	cases := []struct {
+		name   string
+		method string
+		body   string
+	}{
+		{"set", http.MethodPost, `{"preferred_genres":["Action","Horror"],"disliked_genres":["Horror"]}`},
+		{"set case-insensitive", http.MethodPost, `{"preferred_genres":["horror"],"disliked_genres":["HORROR"]}`},
+		{"patch both", http.MethodPatch, `{"preferred_genres":["Drama"],"disliked_genres":["drama"]}`},
+		{"patch against stored", http.MethodPatch, `{"preferred_genres":["horror"]}`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, "/users/1/preferences", strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != fiber.StatusUnprocessableEntity {
+				t.Errorf("status %d, want 422", resp.StatusCode)
+			}
+		})
+	}
+}
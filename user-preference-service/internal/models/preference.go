@@ -1,6 +1,10 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"movie-discovery-user-preference-service/internal/cache"
+)
 
 // User represents a registered user.
 type User struct {
@@ -16,21 +20,61 @@ type CreateUserRequest struct {
 	Email    string `json:"email"`
 }
 
+// UpdateUserRequest is the request body for updating a user. Omitted
+// fields keep their current value.
+type UpdateUserRequest struct {
+	Username *string `json:"username"`
+	Email    *string `json:"email"`
+}
+
+// MaxUserExistsBatch caps the IDs accepted by one existence check.
+const MaxUserExistsBatch = 500
+
+// UserExistsRequest is the request body for a batch existence check.
+type UserExistsRequest struct {
+	IDs []int `json:"ids"`
+}
+
+// UserExistsResponse splits the requested IDs into those that exist and
+// those that don't, each sorted and without duplicates.
+type UserExistsResponse struct {
+	Found   []int `json:"found"`
+	Missing []int `json:"missing"`
+}
+
 // UserPreference stores user preferences for movie recommendations.
 type UserPreference struct {
 	ID                int       `json:"id"`
 	UserID            int       `json:"user_id"`
 	PreferredGenres   []string  `json:"preferred_genres"`
+	DislikedGenres    []string  `json:"disliked_genres"`
 	PreferredLanguage string    `json:"preferred_language"`
 	MinRating         float64   `json:"min_rating"`
 	UpdatedAt         time.Time `json:"updated_at"`
+	// HasPreferences is false when the user never set preferences and the
+	// values above are defaults, as opposed to preferences that were set
+	// and later cleared.
+	HasPreferences bool `json:"has_preferences"`
 }
 
-// SetPreferenceRequest is the request body for setting preferences.
+// SetPreferenceRequest is the request body for setting preferences. It
+// replaces the stored preferences in full, except that an omitted MinRating
+// keeps the stored value rather than resetting it to 0.
+// A genre may not appear in both PreferredGenres and DislikedGenres.
 type SetPreferenceRequest struct {
 	PreferredGenres   []string `json:"preferred_genres"`
+	DislikedGenres    []string `json:"disliked_genres"`
 	PreferredLanguage string   `json:"preferred_language"`
-	MinRating         float64  `json:"min_rating"`
+	MinRating         *float64 `json:"min_rating"`
+}
+
+// PatchPreferenceRequest is the request body for partially updating
+// preferences. Omitted (nil) fields keep their current value.
+type PatchPreferenceRequest struct {
+	PreferredGenres   *[]string `json:"preferred_genres"`
+	DislikedGenres    *[]string `json:"disliked_genres"`
+	PreferredLanguage *string   `json:"preferred_language"`
+	MinRating         *float64  `json:"min_rating"`
 }
 
 // UserInteraction records user activity with a movie.
@@ -48,6 +92,41 @@ type CreateInteractionRequest struct {
 	InteractionType string `json:"interaction_type"`
 }
 
+// PopularMovie is a movie ranked by positive interactions across all users.
+// Title and PosterURL come from the movie service and are empty when it
+// could not be reached.
+type PopularMovie struct {
+	MovieID          int    `json:"movie_id"`
+	Title            string `json:"title"`
+	PosterURL        string `json:"poster_url"`
+	UserCount        int    `json:"user_count"`
+	InteractionCount int    `json:"interaction_count"`
+}
+
+// InteractionStats summarizes a user's interactions. ByType lists every
+// interaction type, with zero for types the user never used. TopGenres is
+// empty when the movie service could not be reached.
+type InteractionStats struct {
+	UserID    int                     `json:"user_id"`
+	Total     int                     `json:"total"`
+	ByType    map[string]int          `json:"by_type"`
+	TopMovies []MovieInteractionCount `json:"top_movies"`
+	TopGenres []GenreInteractionCount `json:"top_genres"`
+}
+
+// MovieInteractionCount is how many times a user interacted with a movie.
+type MovieInteractionCount struct {
+	MovieID          int `json:"movie_id"`
+	InteractionCount int `json:"interaction_count"`
+}
+
+// GenreInteractionCount is how many of a user's interactions were with
+// movies of a genre.
+type GenreInteractionCount struct {
+	Genre            string `json:"genre"`
+	InteractionCount int    `json:"interaction_count"`
+}
+
 // Valid interaction types
 var ValidInteractionTypes = map[string]bool{
 	"like":      true,
@@ -55,3 +134,10 @@ var ValidInteractionTypes = map[string]bool{
 	"watchlist": true,
 	"watched":   true,
 }
+
+// ServiceStats is the operational snapshot served on /internal/stats.
+type ServiceStats struct {
+	Service   string      `json:"service"`
+	Cache     cache.Stats `json:"cache"`
+	UserCount int         `json:"user_count"`
+}
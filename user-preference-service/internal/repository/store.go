@@ -0,0 +1,24 @@
+package repository
+
+import "movie-discovery-user-preference-service/internal/models"
+
+// UserStore is the persistence contract the user service depends on.
+// UserRepository is the Postgres implementation; tests may supply a fake.
+type UserStore interface {
+	CreateUser(req models.CreateUserRequest) (*models.User, error)
+	GetUser(id int) (*models.User, error)
+	UpdateUser(id int, req models.UpdateUserRequest) (*models.User, error)
+	DeleteUser(id int) error
+	ExistingUserIDs(ids []int) (map[int]bool, error)
+	CountUsers() (int, error)
+	UpsertPreference(userID int, req models.SetPreferenceRequest) (*models.UserPreference, error)
+	PatchPreference(userID int, req models.PatchPreferenceRequest) (*models.UserPreference, error)
+	GetPreference(userID int) (*models.UserPreference, error)
+	CreateInteraction(userID int, req models.CreateInteractionRequest) (*models.UserInteraction, error)
+	GetInteractions(userID, movieID, limit int) ([]models.UserInteraction, error)
+	ClearInteractions(userID int) (int, error)
+	GetInteractionStats(userID, movieLimit int) (map[string]int, []models.MovieInteractionCount, error)
+	GetPopularMovies(limit int) ([]models.PopularMovie, error)
+}
+
+var _ UserStore = (*UserRepository)(nil)
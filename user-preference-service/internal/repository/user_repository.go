@@ -2,13 +2,23 @@ package repository
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/lib/pq"
 
 	"movie-discovery-user-preference-service/internal/models"
 )
 
+// ErrDuplicateUser is returned, prefixed with the conflicting field, when a
+// username or email is already taken.
+var ErrDuplicateUser = errors.New("already exists")
+
+// uniqueViolation is the Postgres error code for a unique constraint
+// violation.
+const uniqueViolation = "23505"
+
 type UserRepository struct {
 	db *sql.DB
 }
@@ -25,11 +35,41 @@ func (r *UserRepository) CreateUser(req models.CreateUserRequest) (*models.User,
 		RETURNING id, username, email, created_at
 	`, req.Username, req.Email).Scan(&user.ID, &user.Username, &user.Email, &user.CreatedAt)
 	if err != nil {
+		if dupErr := duplicateUserError(err); dupErr != nil {
+			return nil, dupErr
+		}
 		return nil, fmt.Errorf("failed to create user: %w", err)
 	}
 	return &user, nil
 }
 
+// duplicateUserError translates a unique violation on the users table into
+// ErrDuplicateUser naming the conflicting field. It returns nil for any
+// other error.
+func duplicateUserError(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) || pqErr.Code != uniqueViolation {
+		return nil
+	}
+	field := "username or email"
+	switch pqErr.Constraint {
+	case "users_username_key":
+		field = "username"
+	case "users_email_key":
+		field = "email"
+	}
+	return fmt.Errorf("%s %w", field, ErrDuplicateUser)
+}
+
+// CountUsers returns the number of registered users.
+func (r *UserRepository) CountUsers() (int, error) {
+	var count int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM users`).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count users: %w", err)
+	}
+	return count, nil
+}
+
 // GetUser returns a user by ID.
 func (r *UserRepository) GetUser(id int) (*models.User, error) {
 	var user models.User
@@ -42,25 +82,151 @@ func (r *UserRepository) GetUser(id int) (*models.User, error) {
 	return &user, nil
 }
 
-// UpsertPreference creates or updates user preferences.
+// UpdateUser changes only the fields set in req. It returns sql.ErrNoRows
+// when the user does not exist.
+func (r *UserRepository) UpdateUser(id int, req models.UpdateUserRequest) (*models.User, error) {
+	sets := []string{}
+	args := []interface{}{id}
+	if req.Username != nil {
+		args = append(args, *req.Username)
+		sets = append(sets, fmt.Sprintf("username = $%d", len(args)))
+	}
+	if req.Email != nil {
+		args = append(args, *req.Email)
+		sets = append(sets, fmt.Sprintf("email = $%d", len(args)))
+	}
+	if len(sets) == 0 {
+		return r.GetUser(id)
+	}
+
+	var user models.User
+	err := r.db.QueryRow(fmt.Sprintf(`
+		UPDATE users SET %s WHERE id = $1
+		RETURNING id, username, email, created_at
+	`, strings.Join(sets, ", ")), args...).Scan(&user.ID, &user.Username, &user.Email, &user.CreatedAt)
+	if err != nil {
+		if dupErr := duplicateUserError(err); dupErr != nil {
+			return nil, dupErr
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to update user: %w", err)
+	}
+	return &user, nil
+}
+
+// DeleteUser deletes a user; their preferences and interactions go with it
+// through ON DELETE CASCADE. It returns sql.ErrNoRows when the user does
+// not exist.
+func (r *UserRepository) DeleteUser(id int) error {
+	res, err := r.db.Exec(`DELETE FROM users WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ExistingUserIDs returns which of ids belong to a user, in one query.
+func (r *UserRepository) ExistingUserIDs(ids []int) (map[int]bool, error) {
+	rows, err := r.db.Query(`SELECT id FROM users WHERE id = ANY($1)`, pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query users: %w", err)
+	}
+	defer rows.Close()
+
+	existing := make(map[int]bool, len(ids))
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan user id: %w", err)
+		}
+		existing[id] = true
+	}
+	return existing, rows.Err()
+}
+
+// UpsertPreference creates or updates user preferences. A nil MinRating
+// keeps the stored value (0 for a new row).
 func (r *UserRepository) UpsertPreference(userID int, req models.SetPreferenceRequest) (*models.UserPreference, error) {
 	var pref models.UserPreference
 	err := r.db.QueryRow(`
-		INSERT INTO user_preferences (user_id, preferred_genres, preferred_language, min_rating, updated_at)
-		VALUES ($1, $2, $3, $4, NOW())
+		INSERT INTO user_preferences (user_id, preferred_genres, disliked_genres, preferred_language, min_rating, updated_at)
+		VALUES ($1, $2, $3, $4, COALESCE($5::double precision, 0), NOW())
 		ON CONFLICT (user_id) DO UPDATE SET
 			preferred_genres = EXCLUDED.preferred_genres,
+			disliked_genres = EXCLUDED.disliked_genres,
 			preferred_language = EXCLUDED.preferred_language,
-			min_rating = EXCLUDED.min_rating,
+			min_rating = COALESCE($5, user_preferences.min_rating),
 			updated_at = NOW()
-		RETURNING id, user_id, preferred_genres, preferred_language, min_rating, updated_at
-	`, userID, pq.Array(req.PreferredGenres), req.PreferredLanguage, req.MinRating).Scan(
-		&pref.ID, &pref.UserID, pq.Array(&pref.PreferredGenres),
+		RETURNING id, user_id, preferred_genres, disliked_genres, preferred_language, min_rating, updated_at
+	`, userID, pq.Array(req.PreferredGenres), pq.Array(req.DislikedGenres), req.PreferredLanguage, req.MinRating).Scan(
+		&pref.ID, &pref.UserID, pq.Array(&pref.PreferredGenres), pq.Array(&pref.DislikedGenres),
 		&pref.PreferredLanguage, &pref.MinRating, &pref.UpdatedAt,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to upsert preference: %w", err)
 	}
+	pref.HasPreferences = true
+	fillEmptyGenres(&pref)
+	return &pref, nil
+}
+
+// PatchPreference updates only the preference fields set in req, creating
+// the row with column defaults for the rest if it does not exist yet.
+func (r *UserRepository) PatchPreference(userID int, req models.PatchPreferenceRequest) (*models.UserPreference, error) {
+	columns := []string{"user_id"}
+	args := []interface{}{userID}
+	if req.PreferredGenres != nil {
+		columns = append(columns, "preferred_genres")
+		args = append(args, pq.Array(*req.PreferredGenres))
+	}
+	if req.DislikedGenres != nil {
+		columns = append(columns, "disliked_genres")
+		args = append(args, pq.Array(*req.DislikedGenres))
+	}
+	if req.PreferredLanguage != nil {
+		columns = append(columns, "preferred_language")
+		args = append(args, *req.PreferredLanguage)
+	}
+	if req.MinRating != nil {
+		columns = append(columns, "min_rating")
+		args = append(args, *req.MinRating)
+	}
+
+	placeholders := make([]string, len(columns))
+	updates := []string{"updated_at = NOW()"}
+	for i, col := range columns {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+		if col != "user_id" {
+			updates = append(updates, fmt.Sprintf("%s = EXCLUDED.%s", col, col))
+		}
+	}
+
+	query := fmt.Sprintf(`
+		INSERT INTO user_preferences (%s, updated_at)
+		VALUES (%s, NOW())
+		ON CONFLICT (user_id) DO UPDATE SET %s
+		RETURNING id, user_id, preferred_genres, COALESCE(disliked_genres, '{}'), preferred_language, min_rating, updated_at
+	`, strings.Join(columns, ", "), strings.Join(placeholders, ", "), strings.Join(updates, ", "))
+
+	var pref models.UserPreference
+	err := r.db.QueryRow(query, args...).Scan(
+		&pref.ID, &pref.UserID, pq.Array(&pref.PreferredGenres), pq.Array(&pref.DislikedGenres),
+		&pref.PreferredLanguage, &pref.MinRating, &pref.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to patch preference: %w", err)
+	}
+	pref.HasPreferences = true
+	fillEmptyGenres(&pref)
 	return &pref, nil
 }
 
@@ -68,24 +234,39 @@ func (r *UserRepository) UpsertPreference(userID int, req models.SetPreferenceRe
 func (r *UserRepository) GetPreference(userID int) (*models.UserPreference, error) {
 	var pref models.UserPreference
 	err := r.db.QueryRow(`
-		SELECT id, user_id, preferred_genres, preferred_language, min_rating, updated_at
+		SELECT id, user_id, preferred_genres, COALESCE(disliked_genres, '{}'), preferred_language, min_rating, updated_at
 		FROM user_preferences WHERE user_id = $1
 	`, userID).Scan(
-		&pref.ID, &pref.UserID, pq.Array(&pref.PreferredGenres),
+		&pref.ID, &pref.UserID, pq.Array(&pref.PreferredGenres), pq.Array(&pref.DislikedGenres),
 		&pref.PreferredLanguage, &pref.MinRating, &pref.UpdatedAt,
 	)
 	if err != nil {
 		return nil, err
 	}
+	pref.HasPreferences = true
+	fillEmptyGenres(&pref)
 	return &pref, nil
 }
 
-// CreateInteraction records a user interaction.
+// fillEmptyGenres replaces genre lists scanned from NULL columns with empty
+// ones, so they serialize as [] rather than null.
+func fillEmptyGenres(pref *models.UserPreference) {
+	if pref.PreferredGenres == nil {
+		pref.PreferredGenres = []string{}
+	}
+	if pref.DislikedGenres == nil {
+		pref.DislikedGenres = []string{}
+	}
+}
+
+// CreateInteraction records a user interaction. Recording one that already
+// exists only refreshes its created_at, so retried requests are idempotent.
 func (r *UserRepository) CreateInteraction(userID int, req models.CreateInteractionRequest) (*models.UserInteraction, error) {
 	var inter models.UserInteraction
 	err := r.db.QueryRow(`
 		INSERT INTO user_interactions (user_id, movie_id, interaction_type)
 		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, movie_id, interaction_type) DO UPDATE SET created_at = NOW()
 		RETURNING id, user_id, movie_id, interaction_type, created_at
 	`, userID, req.MovieID, req.InteractionType).Scan(
 		&inter.ID, &inter.UserID, &inter.MovieID, &inter.InteractionType, &inter.CreatedAt,
@@ -96,15 +277,17 @@ func (r *UserRepository) CreateInteraction(userID int, req models.CreateInteract
 	return &inter, nil
 }
 
-// GetInteractions returns interactions for a user.
-func (r *UserRepository) GetInteractions(userID int, limit int) ([]models.UserInteraction, error) {
+// GetInteractions returns interactions for a user, limited to one movie when
+// movieID is positive.
+func (r *UserRepository) GetInteractions(userID, movieID, limit int) ([]models.UserInteraction, error) {
 	rows, err := r.db.Query(`
 		SELECT id, user_id, movie_id, interaction_type, created_at
 		FROM user_interactions
 		WHERE user_id = $1
+		  AND ($2 = 0 OR movie_id = $2)
 		ORDER BY created_at DESC
-		LIMIT $2
-	`, userID, limit)
+		LIMIT $3
+	`, userID, movieID, limit)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query interactions: %w", err)
 	}
@@ -120,3 +303,95 @@ func (r *UserRepository) GetInteractions(userID int, limit int) ([]models.UserIn
 	}
 	return interactions, nil
 }
+
+// ClearInteractions deletes all of a user's interactions and returns how
+// many were deleted.
+func (r *UserRepository) ClearInteractions(userID int) (int, error) {
+	res, err := r.db.Exec(`DELETE FROM user_interactions WHERE user_id = $1`, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to clear interactions: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to clear interactions: %w", err)
+	}
+	return int(n), nil
+}
+
+// GetInteractionStats returns a user's interaction counts per type and their
+// movieLimit most interacted-with movies.
+func (r *UserRepository) GetInteractionStats(userID, movieLimit int) (map[string]int, []models.MovieInteractionCount, error) {
+	rows, err := r.db.Query(`
+		SELECT interaction_type, COUNT(*)
+		FROM user_interactions
+		WHERE user_id = $1
+		GROUP BY interaction_type
+	`, userID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to count interactions: %w", err)
+	}
+	defer rows.Close()
+
+	byType := make(map[string]int)
+	for rows.Next() {
+		var t string
+		var n int
+		if err := rows.Scan(&t, &n); err != nil {
+			return nil, nil, fmt.Errorf("failed to scan interaction count: %w", err)
+		}
+		byType[t] = n
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	movieRows, err := r.db.Query(`
+		SELECT movie_id, COUNT(*) AS interaction_count
+		FROM user_interactions
+		WHERE user_id = $1
+		GROUP BY movie_id
+		ORDER BY interaction_count DESC, movie_id
+		LIMIT $2
+	`, userID, movieLimit)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query top movies: %w", err)
+	}
+	defer movieRows.Close()
+
+	movies := make([]models.MovieInteractionCount, 0, movieLimit)
+	for movieRows.Next() {
+		var m models.MovieInteractionCount
+		if err := movieRows.Scan(&m.MovieID, &m.InteractionCount); err != nil {
+			return nil, nil, fmt.Errorf("failed to scan movie count: %w", err)
+		}
+		movies = append(movies, m)
+	}
+	return byType, movies, movieRows.Err()
+}
+
+// GetPopularMovies returns the movies with the most distinct users having a
+// positive (non-dislike) interaction with them.
+func (r *UserRepository) GetPopularMovies(limit int) ([]models.PopularMovie, error) {
+	rows, err := r.db.Query(`
+		SELECT movie_id, COUNT(DISTINCT user_id) AS user_count, COUNT(*) AS interaction_count
+		FROM user_interactions
+		WHERE interaction_type <> 'dislike'
+		GROUP BY movie_id
+		ORDER BY user_count DESC, interaction_count DESC, movie_id
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query popular movies: %w", err)
+	}
+	defer rows.Close()
+
+	movies := make([]models.PopularMovie, 0, limit)
+	for rows.Next() {
+		var m models.PopularMovie
+		if err := rows.Scan(&m.MovieID, &m.UserCount, &m.InteractionCount); err != nil {
+			continue
+		}
+		movies = append(movies, m)
+	}
+	return movies, rows.Err()
+}
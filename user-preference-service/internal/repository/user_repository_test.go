@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/lib/pq"
+
+	"movie-discovery-user-preference-service/internal/models"
+)
+
+func TestDuplicateUserError(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want string // "" means not a duplicate
+	}{
+		{"username", &pq.Error{Code: uniqueViolation, Constraint: "users_username_key"}, "username already exists"},
+		{"email", &pq.Error{Code: uniqueViolation, Constraint: "users_email_key"}, "email already exists"},
+		{"wrapped", fmt.Errorf("insert: %w", &pq.Error{Code: uniqueViolation, Constraint: "users_email_key"}), "email already exists"},
+		{"unknown constraint", &pq.Error{Code: uniqueViolation, Constraint: "users_pkey"}, "username or email already exists"},
+		{"other pq error", &pq.Error{Code: "23503", Constraint: "users_email_key"}, ""},
+		{"not a pq error", errors.New("connection refused"), ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := duplicateUserError(tc.err)
+			if tc.want == "" {
+				if err != nil {
+					t.Errorf("got %v, want nil", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrDuplicateUser) {
+				t.Fatalf("got %v, want ErrDuplicateUser", err)
+			}
+			if err.Error() != tc.want {
+				t.Errorf("got %q, want %q", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestFillEmptyGenresSerializesNullAsEmptyArray(t *testing.T) {
+	var pref models.UserPreference
+	// What scanning NULL genre columns leaves behind
+	if err := pq.Array(&pref.PreferredGenres).Scan(nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := pq.Array(&pref.DislikedGenres).Scan(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	fillEmptyGenres(&pref)
+	data, err := json.Marshal(pref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range []string{`"preferred_genres":[]`, `"disliked_genres":[]`} {
+		if !strings.Contains(string(data), field) {
+			t.Errorf("%s missing from %s", field, data)
+		}
+	}
+
+	// Stored genres are left alone
+	pref = models.UserPreference{PreferredGenres: []string{"Drama"}}
+	fillEmptyGenres(&pref)
+	if len(pref.PreferredGenres) != 1 || pref.PreferredGenres[0] != "Drama" {
+		t.Errorf("preferred genres changed to %q", pref.PreferredGenres)
+	}
+}
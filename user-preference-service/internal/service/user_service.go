@@ -4,37 +4,112 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"net/http"
+	"net/mail"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
-	"github.com/redis/go-redis/v9"
-
+	"movie-discovery-user-preference-service/internal/cache"
 	"movie-discovery-user-preference-service/internal/models"
 	"movie-discovery-user-preference-service/internal/repository"
 )
 
 const (
-	prefCacheTTL = 10 * time.Minute
+	prefCacheTTL          = 10 * time.Minute
+	popularMoviesCacheTTL = 5 * time.Minute
+	// userCacheTTL keeps the existence check on the write paths off the
+	// database for bursts of writes by the same user.
+	userCacheTTL = 1 * time.Minute
+	// defaultUpstreamTimeout bounds calls to the recommendation and movie
+	// services when no timeout is configured.
+	defaultUpstreamTimeout = 5 * time.Second
+	// statsGenreMovies is how many of a user's most interacted-with movies
+	// are looked up to rank genres. It stays within the movie service's
+	// default MAX_FILTER_VALUES so the batch genre lookup is never rejected.
+	statsGenreMovies = 50
+	// statsTopMovies and statsTopGenres size the interaction stats lists.
+	statsTopMovies = 10
+	statsTopGenres = 5
 )
 
+// ErrInvalidPreference is returned when a preference update fails validation.
+var ErrInvalidPreference = errors.New("invalid preference")
+
+// ErrInvalidUser is returned when a user create or update fails validation.
+var ErrInvalidUser = errors.New("invalid user")
+
+// ErrUserConflict is returned, prefixed with the conflicting field, when a
+// create or update would give a user the username or email of another user.
+var ErrUserConflict = repository.ErrDuplicateUser
+
+// ErrInvalidUserIDs is returned when a batch existence check is empty, too
+// large or contains a non-positive ID.
+var ErrInvalidUserIDs = errors.New("invalid user ids")
+
 type UserService struct {
-	repo  *repository.UserRepository
-	redis *redis.Client
+	repo                     repository.UserStore
+	cache                    cache.Cache
+	recommendationServiceURL string
+	movieServiceURL          string
+	internalAPIToken         string
+	httpClient               *http.Client
+	// maxGenres caps each of preferred_genres and disliked_genres.
+	maxGenres int
 }
 
-func NewUserService(repo *repository.UserRepository, rdb *redis.Client) *UserService {
-	return &UserService{repo: repo, redis: rdb}
+func NewUserService(repo repository.UserStore, c cache.Cache, recommendationServiceURL, movieServiceURL, internalAPIToken string, maxGenres int, upstreamTimeout time.Duration) *UserService {
+	if upstreamTimeout <= 0 {
+		upstreamTimeout = defaultUpstreamTimeout
+	}
+	return &UserService{
+		repo:                     repo,
+		cache:                    c,
+		maxGenres:                maxGenres,
+		recommendationServiceURL: strings.TrimRight(recommendationServiceURL, "/"),
+		movieServiceURL:          strings.TrimRight(movieServiceURL, "/"),
+		internalAPIToken:         internalAPIToken,
+		httpClient:               &http.Client{Timeout: upstreamTimeout},
+	}
 }
 
 func (s *UserService) CreateUser(req models.CreateUserRequest) (*models.User, error) {
 	if req.Username == "" || req.Email == "" {
-		return nil, fmt.Errorf("username and email are required")
+		return nil, fmt.Errorf("%w: username and email are required", ErrInvalidUser)
+	}
+	if err := validateEmail(req.Email); err != nil {
+		return nil, err
 	}
 	return s.repo.CreateUser(req)
 }
 
+// validateEmail accepts a bare address such as "jane@example.com"; display
+// names ("Jane <jane@example.com>") are rejected.
+func validateEmail(email string) error {
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return fmt.Errorf("%w: invalid email address", ErrInvalidUser)
+	}
+	return nil
+}
+
+// GetUser returns a user by ID. Only found users are cached, so a user
+// created right after a failed lookup is seen immediately; updates and
+// deletes drop the cached copy.
 func (s *UserService) GetUser(id int) (*models.User, error) {
+	cacheKey := userCacheKey(id)
+	if cached, err := s.getFromCache(cacheKey); err == nil {
+		var user models.User
+		if json.Unmarshal([]byte(cached), &user) == nil {
+			return &user, nil
+		}
+	}
+
 	user, err := s.repo.GetUser(id)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -42,29 +117,188 @@ func (s *UserService) GetUser(id int) (*models.User, error) {
 		}
 		return nil, err
 	}
+
+	if data, err := json.Marshal(user); err == nil {
+		s.setCache(cacheKey, string(data), userCacheTTL)
+	}
 	return user, nil
 }
 
-func (s *UserService) SetPreference(userID int, req models.SetPreferenceRequest) (*models.UserPreference, error) {
-	// Verify user exists
-	if _, err := s.repo.GetUser(userID); err != nil {
+// UpdateUser changes the username and/or email of a user.
+func (s *UserService) UpdateUser(id int, req models.UpdateUserRequest) (*models.User, error) {
+	if req.Username == nil && req.Email == nil {
+		return nil, fmt.Errorf("%w: username or email is required", ErrInvalidUser)
+	}
+	if req.Username != nil && strings.TrimSpace(*req.Username) == "" {
+		return nil, fmt.Errorf("%w: username cannot be empty", ErrInvalidUser)
+	}
+	if req.Email != nil {
+		if strings.TrimSpace(*req.Email) == "" {
+			return nil, fmt.Errorf("%w: email cannot be empty", ErrInvalidUser)
+		}
+		if err := validateEmail(*req.Email); err != nil {
+			return nil, err
+		}
+	}
+
+	user, err := s.repo.UpdateUser(id, req)
+	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("user not found")
 		}
 		return nil, err
 	}
 
+	s.delCache(userCacheKey(id))
+	return user, nil
+}
+
+// DeleteUser deletes a user together with their preferences and
+// interactions, and drops their cached data.
+func (s *UserService) DeleteUser(id int) error {
+	if err := s.repo.DeleteUser(id); err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("user not found")
+		}
+		return err
+	}
+
+	s.delCache(userCacheKey(id))
+	s.delCache(fmt.Sprintf("user:pref:%d", id))
+	return nil
+}
+
+// userCacheKey is the cache key of a user looked up by GetUser.
+func userCacheKey(id int) string {
+	return fmt.Sprintf("user:%d", id)
+}
+
+// CheckUsersExist reports which of ids belong to a user, with a single
+// query regardless of how many IDs are checked.
+func (s *UserService) CheckUsersExist(ids []int) (*models.UserExistsResponse, error) {
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("%w: ids is required", ErrInvalidUserIDs)
+	}
+	if len(ids) > models.MaxUserExistsBatch {
+		return nil, fmt.Errorf("%w: at most %d ids per request", ErrInvalidUserIDs, models.MaxUserExistsBatch)
+	}
+
+	unique := make([]int, 0, len(ids))
+	seen := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		if id <= 0 {
+			return nil, fmt.Errorf("%w: %d is not a valid user id", ErrInvalidUserIDs, id)
+		}
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	sort.Ints(unique)
+
+	existing, err := s.repo.ExistingUserIDs(unique)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &models.UserExistsResponse{Found: []int{}, Missing: []int{}}
+	for _, id := range unique {
+		if existing[id] {
+			resp.Found = append(resp.Found, id)
+		} else {
+			resp.Missing = append(resp.Missing, id)
+		}
+	}
+	return resp, nil
+}
+
+func (s *UserService) SetPreference(userID int, req models.SetPreferenceRequest) (*models.UserPreference, error) {
+	if err := validateGenreCount("preferred_genres", req.PreferredGenres, s.maxGenres); err != nil {
+		return nil, err
+	}
+	if err := validateGenreCount("disliked_genres", req.DislikedGenres, s.maxGenres); err != nil {
+		return nil, err
+	}
+	if err := validateGenreOverlap(req.PreferredGenres, req.DislikedGenres); err != nil {
+		return nil, err
+	}
+	if req.PreferredGenres == nil {
+		req.PreferredGenres = []string{}
+	}
+	if req.DislikedGenres == nil {
+		req.DislikedGenres = []string{}
+	}
+
+	// Verify user exists
+	if _, err := s.GetUser(userID); err != nil {
+		return nil, err
+	}
+
 	pref, err := s.repo.UpsertPreference(userID, req)
 	if err != nil {
 		return nil, err
 	}
 
-	// Invalidate cache
-	s.delCache(fmt.Sprintf("user:pref:%d", userID))
+	s.preferenceChanged(userID)
+
+	return pref, nil
+}
+
+// PatchPreference updates only the fields present in req, leaving the rest
+// of the stored preferences unchanged.
+func (s *UserService) PatchPreference(userID int, req models.PatchPreferenceRequest) (*models.UserPreference, error) {
+	if req.PreferredGenres != nil {
+		if err := validateGenreCount("preferred_genres", *req.PreferredGenres, s.maxGenres); err != nil {
+			return nil, err
+		}
+	}
+	if req.DislikedGenres != nil {
+		if err := validateGenreCount("disliked_genres", *req.DislikedGenres, s.maxGenres); err != nil {
+			return nil, err
+		}
+	}
+
+	// Verify user exists
+	if _, err := s.GetUser(userID); err != nil {
+		return nil, err
+	}
+
+	// Check genre overlap against the preferences as they will be stored
+	preferred, disliked := []string{}, []string{}
+	current, err := s.repo.GetPreference(userID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	if current != nil {
+		preferred, disliked = current.PreferredGenres, current.DislikedGenres
+	}
+	if req.PreferredGenres != nil {
+		preferred = *req.PreferredGenres
+	}
+	if req.DislikedGenres != nil {
+		disliked = *req.DislikedGenres
+	}
+	if err := validateGenreOverlap(preferred, disliked); err != nil {
+		return nil, err
+	}
+
+	pref, err := s.repo.PatchPreference(userID, req)
+	if err != nil {
+		return nil, err
+	}
+
+	s.preferenceChanged(userID)
 
 	return pref, nil
 }
 
+// preferenceChanged invalidates the cached preferences and lets the
+// recommendation service precompute with the new ones.
+func (s *UserService) preferenceChanged(userID int) {
+	s.delCache(fmt.Sprintf("user:pref:%d", userID))
+	go s.notifyPreferenceChange(userID)
+}
+
 func (s *UserService) GetPreference(userID int) (*models.UserPreference, error) {
 	// Try cache
 	cacheKey := fmt.Sprintf("user:pref:%d", userID)
@@ -78,10 +312,11 @@ func (s *UserService) GetPreference(userID int) (*models.UserPreference, error)
 	pref, err := s.repo.GetPreference(userID)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			// Return default preferences
+			// Return default preferences; HasPreferences stays false
 			return &models.UserPreference{
 				UserID:            userID,
 				PreferredGenres:   []string{},
+				DislikedGenres:    []string{},
 				PreferredLanguage: "en",
 				MinRating:         0,
 			}, nil
@@ -106,44 +341,318 @@ func (s *UserService) RecordInteraction(userID int, req models.CreateInteraction
 	}
 
 	// Verify user exists
-	if _, err := s.repo.GetUser(userID); err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("user not found")
-		}
+	if _, err := s.GetUser(userID); err != nil {
 		return nil, err
 	}
 
 	return s.repo.CreateInteraction(userID, req)
 }
 
-func (s *UserService) GetInteractions(userID, limit int) ([]models.UserInteraction, error) {
+// GetInteractions returns a user's interactions, newest first. A positive
+// movieID restricts them to that movie.
+func (s *UserService) GetInteractions(userID, movieID, limit int) ([]models.UserInteraction, error) {
 	if limit <= 0 {
 		limit = 50
 	}
-	return s.repo.GetInteractions(userID, limit)
+	return s.repo.GetInteractions(userID, movieID, limit)
 }
 
-// Redis helpers
+// ClearInteractions deletes a user's whole interaction history, keeping the
+// account and preferences, and returns how many interactions were deleted.
+// Cross-user aggregates are dropped from the cache and the recommendation
+// service is asked to rebuild the user's recommendations without them.
+func (s *UserService) ClearInteractions(userID int) (int, error) {
+	// Verify user exists
+	if _, err := s.GetUser(userID); err != nil {
+		return 0, err
+	}
 
-func (s *UserService) getFromCache(key string) (string, error) {
-	if s.redis == nil {
-		return "", fmt.Errorf("redis not available")
+	deleted, err := s.repo.ClearInteractions(userID)
+	if err != nil {
+		return 0, err
+	}
+
+	if deleted > 0 {
+		if _, err := s.cache.Invalidate(context.Background(), "movies:popular:*"); err != nil {
+			slog.Error("failed to invalidate popular movies cache", "error", err)
+		}
+		go s.notifyPreferenceChange(userID)
 	}
-	return s.redis.Get(context.Background(), key).Result()
+	return deleted, nil
 }
 
-func (s *UserService) setCache(key, value string, ttl time.Duration) {
-	if s.redis == nil {
+// GetInteractionStats summarizes a user's interactions: counts per type, the
+// movies they interacted with most, and the genres of those movies ranked by
+// interaction count. A user without interactions gets zero counts and empty
+// lists.
+func (s *UserService) GetInteractionStats(userID int) (*models.InteractionStats, error) {
+	byType, movies, err := s.repo.GetInteractionStats(userID, statsGenreMovies)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &models.InteractionStats{
+		UserID:    userID,
+		ByType:    make(map[string]int, len(models.ValidInteractionTypes)),
+		TopMovies: movies,
+		TopGenres: []models.GenreInteractionCount{},
+	}
+	for t := range models.ValidInteractionTypes {
+		stats.ByType[t] = 0
+	}
+	for t, n := range byType {
+		stats.ByType[t] = n
+		stats.Total += n
+	}
+
+	if len(movies) > 0 {
+		genres, err := s.fetchMovieGenres(movies)
+		if err != nil {
+			slog.Warn("could not rank interacted genres", "user_id", userID, "error", err)
+		} else {
+			stats.TopGenres = rankGenres(movies, genres, statsTopGenres)
+		}
+	}
+	if len(stats.TopMovies) > statsTopMovies {
+		stats.TopMovies = stats.TopMovies[:statsTopMovies]
+	}
+	return stats, nil
+}
+
+// rankGenres credits each movie's interaction count to its genres and
+// returns the top limit genres.
+func rankGenres(movies []models.MovieInteractionCount, movieGenres map[int][]string, limit int) []models.GenreInteractionCount {
+	counts := make(map[string]int)
+	for _, m := range movies {
+		for _, g := range movieGenres[m.MovieID] {
+			counts[g] += m.InteractionCount
+		}
+	}
+
+	ranked := make([]models.GenreInteractionCount, 0, len(counts))
+	for g, n := range counts {
+		ranked = append(ranked, models.GenreInteractionCount{Genre: g, InteractionCount: n})
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].InteractionCount != ranked[j].InteractionCount {
+			return ranked[i].InteractionCount > ranked[j].InteractionCount
+		}
+		return ranked[i].Genre < ranked[j].Genre
+	})
+	if len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+	return ranked
+}
+
+// fetchMovieGenres calls the movie service batch genre endpoint.
+func (s *UserService) fetchMovieGenres(movies []models.MovieInteractionCount) (map[int][]string, error) {
+	if s.movieServiceURL == "" {
+		return nil, fmt.Errorf("movie service URL not configured")
+	}
+
+	ids := make([]string, len(movies))
+	for i, m := range movies {
+		ids[i] = strconv.Itoa(m.MovieID)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.httpClient.Timeout)
+	defer cancel()
+
+	url := fmt.Sprintf("%s/api/v1/movies/genres?ids=%s", s.movieServiceURL, strings.Join(ids, ","))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if s.internalAPIToken != "" {
+		req.Header.Set("X-Internal-Token", s.internalAPIToken)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("movie-service returned %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Data map[int][]string `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	return result.Data, nil
+}
+
+// GetPopularMovies returns the movies most interacted with across all
+// users, enriched with titles and posters from the movie service.
+func (s *UserService) GetPopularMovies(limit int) ([]models.PopularMovie, error) {
+	if limit <= 0 || limit > 50 {
+		limit = 20
+	}
+
+	cacheKey := fmt.Sprintf("movies:popular:%d", limit)
+	if cached, err := s.getFromCache(cacheKey); err == nil {
+		var movies []models.PopularMovie
+		if json.Unmarshal([]byte(cached), &movies) == nil {
+			return movies, nil
+		}
+	}
+
+	movies, err := s.repo.GetPopularMovies(limit)
+	if err != nil {
+		return nil, err
+	}
+	s.enrichPopularMovies(movies)
+
+	if data, err := json.Marshal(movies); err == nil {
+		s.setCache(cacheKey, string(data), popularMoviesCacheTTL)
+	}
+
+	return movies, nil
+}
+
+// enrichPopularMovies fills in title and poster from the movie service.
+// Movies that cannot be fetched are left without them.
+func (s *UserService) enrichPopularMovies(movies []models.PopularMovie) {
+	if s.movieServiceURL == "" {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.httpClient.Timeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for i := range movies {
+		wg.Add(1)
+		go func(m *models.PopularMovie) {
+			defer wg.Done()
+			if err := s.fetchMovieSummary(ctx, m); err != nil {
+				slog.Warn("could not enrich popular movie", "movie_id", m.MovieID, "error", err)
+			}
+		}(&movies[i])
+	}
+	wg.Wait()
+}
+
+func (s *UserService) fetchMovieSummary(ctx context.Context, m *models.PopularMovie) error {
+	url := fmt.Sprintf("%s/api/v1/movies/%d", s.movieServiceURL, m.MovieID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	if s.internalAPIToken != "" {
+		req.Header.Set("X-Internal-Token", s.internalAPIToken)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("movie-service returned %d", resp.StatusCode)
+	}
+
+	var detail struct {
+		Title     string `json:"title"`
+		PosterURL string `json:"poster_url"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&detail); err != nil {
+		return err
+	}
+	m.Title, m.PosterURL = detail.Title, detail.PosterURL
+	return nil
+}
+
+// GetStats returns cache effectiveness and user count for ops dashboards.
+func (s *UserService) GetStats() (*models.ServiceStats, error) {
+	count, err := s.repo.CountUsers()
+	if err != nil {
+		return nil, err
+	}
+	return &models.ServiceStats{
+		Service:   "user-preference-service",
+		Cache:     s.cache.Stats(),
+		UserCount: count,
+	}, nil
+}
+
+// notifyPreferenceChange asks the recommendation service to rebuild the
+// user's cached recommendations. It is best-effort: failures are logged.
+func (s *UserService) notifyPreferenceChange(userID int) {
+	if s.recommendationServiceURL == "" {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.httpClient.Timeout)
+	defer cancel()
+
+	url := fmt.Sprintf("%s/internal/users/%d/recommendations/warm", s.recommendationServiceURL, userID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, nil)
+	if err != nil {
+		slog.Warn("failed to build recommendation warm request", "user_id", userID, "error", err)
+		return
+	}
+	if s.internalAPIToken != "" {
+		req.Header.Set("X-Internal-Token", s.internalAPIToken)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		slog.Warn("failed to notify recommendation service", "user_id", userID, "error", err)
 		return
 	}
-	if err := s.redis.Set(context.Background(), key, value, ttl).Err(); err != nil {
+	resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		slog.Warn("recommendation service rejected warm request", "user_id", userID, "status", resp.StatusCode)
+	}
+}
+
+// validateGenreCount rejects genre lists longer than max. A max of zero
+// or less disables the check.
+func validateGenreCount(field string, genres []string, max int) error {
+	if max > 0 && len(genres) > max {
+		return fmt.Errorf("%w: %s accepts at most %d genres", ErrInvalidPreference, field, max)
+	}
+	return nil
+}
+
+// validateGenreOverlap rejects a genre that is both preferred and disliked.
+// Genres are compared case-insensitively, matching how the recommender
+// matches them against movie genres.
+func validateGenreOverlap(preferred, disliked []string) error {
+	dislikedSet := make(map[string]bool, len(disliked))
+	for _, g := range disliked {
+		dislikedSet[strings.ToLower(strings.TrimSpace(g))] = true
+	}
+	for _, g := range preferred {
+		if dislikedSet[strings.ToLower(strings.TrimSpace(g))] {
+			return fmt.Errorf("%w: genre %q cannot be both preferred and disliked", ErrInvalidPreference, g)
+		}
+	}
+	return nil
+}
+
+// Cache helpers
+
+func (s *UserService) getFromCache(key string) (string, error) {
+	return s.cache.Get(context.Background(), key)
+}
+
+func (s *UserService) setCache(key, value string, ttl time.Duration) {
+	if err := s.cache.Set(context.Background(), key, value, ttl); err != nil {
 		slog.Error("failed to set cache", "key", key, "error", err)
 	}
 }
 
 func (s *UserService) delCache(key string) {
-	if s.redis == nil {
-		return
+	if err := s.cache.Del(context.Background(), key); err != nil {
+		slog.Error("failed to delete cache", "key", key, "error", err)
 	}
-	s.redis.Del(context.Background(), key)
 }
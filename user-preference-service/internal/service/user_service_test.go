@@ -0,0 +1,324 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"movie-discovery-user-preference-service/internal/cache"
+	"movie-discovery-user-preference-service/internal/models"
+	"movie-discovery-user-preference-service/internal/repository"
+)
+
+// fakeUserStore is an in-memory UserStore. Like the Postgres repository it
+// keeps usernames and emails unique. Methods the tests do not use are left
+// to the embedded interface.
+type fakeUserStore struct {
+	repository.UserStore
+	users map[int]*models.User
+	prefs map[int]models.UserPreference
+	// patches records every request PatchPreference received
+	patches []models.PatchPreferenceRequest
+}
+
+func newFakeUserStore() *fakeUserStore {
+	return &fakeUserStore{users: make(map[int]*models.User), prefs: make(map[int]models.UserPreference)}
+}
+
+func (f *fakeUserStore) CreateUser(req models.CreateUserRequest) (*models.User, error) {
+	for _, u := range f.users {
+		switch {
+		case u.Username == req.Username:
+			return nil, fmt.Errorf("username %w", repository.ErrDuplicateUser)
+		case u.Email == req.Email:
+			return nil, fmt.Errorf("email %w", repository.ErrDuplicateUser)
+		}
+	}
+	user := &models.User{ID: len(f.users) + 1, Username: req.Username, Email: req.Email}
+	f.users[user.ID] = user
+	return user, nil
+}
+
+func (f *fakeUserStore) GetUser(id int) (*models.User, error) {
+	user, ok := f.users[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return user, nil
+}
+
+func (f *fakeUserStore) GetPreference(userID int) (*models.UserPreference, error) {
+	pref, ok := f.prefs[userID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &pref, nil
+}
+
+func (f *fakeUserStore) UpsertPreference(userID int, req models.SetPreferenceRequest) (*models.UserPreference, error) {
+	pref := models.UserPreference{
+		UserID:            userID,
+		PreferredGenres:   req.PreferredGenres,
+		DislikedGenres:    req.DislikedGenres,
+		PreferredLanguage: req.PreferredLanguage,
+		HasPreferences:    true,
+	}
+	if req.MinRating != nil {
+		pref.MinRating = *req.MinRating
+	}
+	f.prefs[userID] = pref
+	return &pref, nil
+}
+
+// PatchPreference overwrites only the fields present in req, as the
+// repository's upsert does.
+func (f *fakeUserStore) PatchPreference(userID int, req models.PatchPreferenceRequest) (*models.UserPreference, error) {
+	f.patches = append(f.patches, req)
+	pref, ok := f.prefs[userID]
+	if !ok {
+		pref = models.UserPreference{UserID: userID, PreferredGenres: []string{}, DislikedGenres: []string{}, PreferredLanguage: "en"}
+	}
+	if req.PreferredGenres != nil {
+		pref.PreferredGenres = *req.PreferredGenres
+	}
+	if req.DislikedGenres != nil {
+		pref.DislikedGenres = *req.DislikedGenres
+	}
+	if req.PreferredLanguage != nil {
+		pref.PreferredLanguage = *req.PreferredLanguage
+	}
+	if req.MinRating != nil {
+		pref.MinRating = *req.MinRating
+	}
+	pref.HasPreferences = true
+	f.prefs[userID] = pref
+	return &pref, nil
+}
+
+// withUser stores a user with the given preferences and returns its ID.
+func (f *fakeUserStore) withUser(pref models.UserPreference) int {
+	id := len(f.users) + 1
+	f.users[id] = &models.User{ID: id, Username: fmt.Sprintf("user%d", id), Email: fmt.Sprintf("user%d@example.com", id)}
+	pref.UserID = id
+	f.prefs[id] = pref
+	return id
+}
+
+func newTestService(store *fakeUserStore, maxGenres int) *UserService {
+	return NewUserService(store, cache.Noop{}, "", "", "", maxGenres, 0)
+}
+
+func TestCreateUserRejectsInvalidEmail(t *testing.T) {
+	svc := newTestService(newFakeUserStore(), 0)
+
+	for _, email := range []string{
+		"not-an-email",
+		"jane@",
+		"@example.com",
+		"Jane <jane@example.com>",
+		"jane@example.com ",
+	} {
+		_, err := svc.CreateUser(models.CreateUserRequest{Username: "jane", Email: email})
+		if !errors.Is(err, ErrInvalidUser) {
+			t.Errorf("email %q: got %v, want ErrInvalidUser", email, err)
+		}
+	}
+
+	if _, err := svc.CreateUser(models.CreateUserRequest{Username: "jane", Email: "jane@example.com"}); err != nil {
+		t.Errorf("valid email rejected: %v", err)
+	}
+}
+
+func TestCreateUserReportsDuplicateField(t *testing.T) {
+	store := newFakeUserStore()
+	svc := newTestService(store, 0)
+	if _, err := svc.CreateUser(models.CreateUserRequest{Username: "jane", Email: "jane@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name  string
+		req   models.CreateUserRequest
+		field string
+	}{
+		{"username", models.CreateUserRequest{Username: "jane", Email: "other@example.com"}, "username"},
+		{"email", models.CreateUserRequest{Username: "other", Email: "jane@example.com"}, "email"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := svc.CreateUser(tc.req)
+			if !errors.Is(err, ErrUserConflict) {
+				t.Fatalf("got %v, want ErrUserConflict", err)
+			}
+			if !strings.HasPrefix(err.Error(), tc.field+" ") {
+				t.Errorf("error %q does not name the %s", err, tc.field)
+			}
+		})
+	}
+	if len(store.users) != 1 {
+		t.Errorf("%d users stored, want 1", len(store.users))
+	}
+}
+
+func TestPatchPreferenceKeepsOmittedFields(t *testing.T) {
+	stored := models.UserPreference{
+		PreferredGenres:   []string{"Drama", "Comedy"},
+		DislikedGenres:    []string{"Horror"},
+		PreferredLanguage: "fr",
+		MinRating:         6.5,
+		HasPreferences:    true,
+	}
+	genres := []string{"Action"}
+	rating := 8.0
+
+	cases := []struct {
+		name string
+		req  models.PatchPreferenceRequest
+		want models.UserPreference
+	}{
+		{
+			name: "preferred_genres only",
+			req:  models.PatchPreferenceRequest{PreferredGenres: &genres},
+			want: models.UserPreference{
+				PreferredGenres:   []string{"Action"},
+				DislikedGenres:    []string{"Horror"},
+				PreferredLanguage: "fr",
+				MinRating:         6.5,
+			},
+		},
+		{
+			name: "min_rating only",
+			req:  models.PatchPreferenceRequest{MinRating: &rating},
+			want: models.UserPreference{
+				PreferredGenres:   []string{"Drama", "Comedy"},
+				DislikedGenres:    []string{"Horror"},
+				PreferredLanguage: "fr",
+				MinRating:         8,
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := newFakeUserStore()
+			id := store.withUser(stored)
+			svc := newTestService(store, 0)
+
+			got, err := svc.PatchPreference(id, tc.req)
+			if err != nil {
+				t.Fatalf("PatchPreference: %v", err)
+			}
+			if len(store.patches) != 1 || store.patches[0] != tc.req {
+				t.Errorf("store got patches %+v, want exactly the request", store.patches)
+			}
+			if !slices.Equal(got.PreferredGenres, tc.want.PreferredGenres) ||
+				!slices.Equal(got.DislikedGenres, tc.want.DislikedGenres) ||
+				got.PreferredLanguage != tc.want.PreferredLanguage ||
+				got.MinRating != tc.want.MinRating {
+				t.Errorf("got %+v, want %+v", *got, tc.want)
+			}
+		})
+	}
+}
+
+func TestValidateGenreOverlap(t *testing.T) {
+	cases := []struct {
+		name      string
+		preferred []string
+		disliked  []string
+		wantErr   bool
+	}{
+		{"disjoint", []string{"Action", "Drama"}, []string{"Horror"}, false},
+		{"empty", nil, nil, false},
+		{"same genre", []string{"Action", "Horror"}, []string{"Horror"}, true},
+		{"different case", []string{"horror"}, []string{"HORROR"}, true},
+		{"surrounding space", []string{" Horror "}, []string{"horror"}, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateGenreOverlap(tc.preferred, tc.disliked)
+			if tc.wantErr && !errors.Is(err, ErrInvalidPreference) {
+				t.Errorf("got %v, want ErrInvalidPreference", err)
+			}
+			if !tc.wantErr && err != nil {
+				t.Errorf("got %v, want nil", err)
+			}
+		})
+	}
+}
+
+func TestPatchPreferenceRejectsOverlapWithStoredGenres(t *testing.T) {
+	store := newFakeUserStore()
+	id := store.withUser(models.UserPreference{PreferredGenres: []string{}, DislikedGenres: []string{"Horror"}})
+	svc := newTestService(store, 0)
+
+	// Only preferred_genres is sent; it clashes with the stored dislikes
+	genres := []string{"horror"}
+	_, err := svc.PatchPreference(id, models.PatchPreferenceRequest{PreferredGenres: &genres})
+	if !errors.Is(err, ErrInvalidPreference) {
+		t.Fatalf("got %v, want ErrInvalidPreference", err)
+	}
+	if len(store.patches) != 0 {
+		t.Error("rejected patch reached the store")
+	}
+}
+
+func TestGenreCountLimit(t *testing.T) {
+	genres := func(n int) []string {
+		g := make([]string, n)
+		for i := range g {
+			g[i] = fmt.Sprintf("Genre %d", i)
+		}
+		return g
+	}
+	const limit = 3
+
+	cases := []struct {
+		name      string
+		maxGenres int
+		count     int
+		wantErr   bool
+	}{
+		{"at max", limit, limit, false},
+		{"over max", limit, limit + 1, true},
+		{"cap disabled", 0, 50, false},
+		{"negative cap disabled", -1, 50, false},
+	}
+	for _, tc := range cases {
+		for _, field := range []string{"preferred_genres", "disliked_genres"} {
+			t.Run(tc.name+"/"+field, func(t *testing.T) {
+				check := func(op string, err error) {
+					t.Helper()
+					if tc.wantErr {
+						if !errors.Is(err, ErrInvalidPreference) || !strings.Contains(err.Error(), field) {
+							t.Errorf("%s: got %v, want ErrInvalidPreference naming %s", op, err, field)
+						}
+						return
+					}
+					if err != nil {
+						t.Errorf("%s: got %v, want nil", op, err)
+					}
+				}
+
+				store := newFakeUserStore()
+				id := store.withUser(models.UserPreference{PreferredGenres: []string{}, DislikedGenres: []string{}})
+				svc := newTestService(store, tc.maxGenres)
+				list := genres(tc.count)
+
+				set := models.SetPreferenceRequest{}
+				patch := models.PatchPreferenceRequest{}
+				if field == "preferred_genres" {
+					set.PreferredGenres, patch.PreferredGenres = list, &list
+				} else {
+					set.DislikedGenres, patch.DislikedGenres = list, &list
+				}
+				_, err := svc.SetPreference(id, set)
+				check("SetPreference", err)
+				_, err = svc.PatchPreference(id, patch)
+				check("PatchPreference", err)
+			})
+		}
+	}
+}